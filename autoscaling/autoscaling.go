@@ -9,6 +9,7 @@
 package autoscaling
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/xml"
 	"fmt"
@@ -28,12 +29,33 @@ const debug = false
 type AutoScaling struct {
 	aws.Auth
 	aws.Region
+	// Config holds the HTTP settings used to send requests. If nil,
+	// aws.DefaultConfig is used.
+	Config  *aws.Config
+	ctx     context.Context
 	private byte // Reserve the right of using private data.
 }
 
 // New creates a new AutoScaling Client.
 func New(auth aws.Auth, region aws.Region) *AutoScaling {
-	return &AutoScaling{auth, region, 0}
+	return NewWithConfig(auth, region, nil)
+}
+
+// NewWithConfig creates a new AutoScaling Client that sends requests using
+// the HTTP settings in config.
+func NewWithConfig(auth aws.Auth, region aws.Region, config *aws.Config) *AutoScaling {
+	return &AutoScaling{Auth: auth, Region: region, Config: config}
+}
+
+// WithContext returns a shallow copy of as whose requests are made with
+// ctx, so that cancelling ctx or reaching its deadline aborts them.
+func (as *AutoScaling) WithContext(ctx context.Context) *AutoScaling {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c := *as
+	c.ctx = ctx
+	return &c
 }
 
 // ----------------------------------------------------------------------------
@@ -104,35 +126,75 @@ func (err *Error) Error() string {
 	return fmt.Sprintf("%s (%s)", err.Message, err.Code)
 }
 
+// errorCodes holds the error codes Auto Scaling uses for throttled
+// requests and transient failures, beyond those all services share.
+var errorCodes = aws.ErrorCodes{
+	Transient: []string{"ResourceContention"},
+}
+
+// Retryable reports whether the failed request may be sent again.
+func (err *Error) Retryable() bool {
+	return errorCodes.Retryable(err.StatusCode, err.Code)
+}
+
+// Throttled reports whether the request was rejected for exceeding a rate
+// limit.
+func (err *Error) Throttled() bool {
+	return errorCodes.Throttled(err.StatusCode, err.Code)
+}
+
+// The following methods implement aws.APIError.
+
+func (err *Error) Service() string      { return "autoscaling" }
+func (err *Error) HTTPStatus() int      { return err.StatusCode }
+func (err *Error) ErrorCode() string    { return err.Code }
+func (err *Error) ErrorMessage() string { return err.Message }
+func (err *Error) RequestID() string    { return err.RequestId }
+
 type xmlErrors struct {
 	RequestId string  `xml:"RequestId"`
 	Errors    []Error `xml:"Error"`
 }
 
+// query sends a request, retrying it as dictated by the Retryer in
+// as.Config, and decodes the response into resp.
 func (as *AutoScaling) query(params map[string]string, resp interface{}) error {
+	req := aws.NewRequest(as.ctx, as.Config, "autoscaling", params["Action"])
+	return req.Retry(func() error {
+		return as.queryOnce(req, params, resp)
+	})
+}
+
+func (as *AutoScaling) queryOnce(req *aws.Request, params map[string]string, resp interface{}) error {
 	params["Version"] = "2011-01-01"
 
+	endpoint, err := as.Config.Endpoint("autoscaling", as.Region)
+	if err != nil {
+		return err
+	}
 	data := strings.NewReader(prepareParams(params))
 
-	hreq, err := http.NewRequest("POST", as.Region.AutoScalingEndpoint+"/", data)
+	hreq, err := http.NewRequest("POST", endpoint+"/", data)
 	if err != nil {
 		return err
 	}
 
 	hreq.Header.Set("Content-Type", "application/x-www-form-urlencoded; param=value")
 
-	token := as.Auth.Token()
-	if token != "" {
-		hreq.Header.Set("X-Amz-Security-Token", token)
+	if err := req.Build(hreq); err != nil {
+		return err
 	}
 
 	signer := aws.NewV4Signer(as.Auth, "autoscaling", as.Region)
 	signer.Sign(hreq)
+	if err := req.Sign(); err != nil {
+		return err
+	}
 
 	if debug {
 		log.Printf("%v -> {\n", hreq)
 	}
-	r, err := http.DefaultClient.Do(hreq)
+	r, err := req.Send()
 
 	if err != nil {
 		log.Printf("Error calling Amazon %v", err)
@@ -147,10 +209,10 @@ func (as *AutoScaling) query(params map[string]string, resp interface{}) error {
 		log.Printf("%v\n}\n", string(dump))
 	}
 	if r.StatusCode != 200 {
-		return buildError(r)
+		return req.Unmarshal(resp, buildError(r))
 	}
 	err = xml.NewDecoder(r.Body).Decode(resp)
-	return err
+	return req.Unmarshal(resp, err)
 }
 
 func buildError(r *http.Response) error {
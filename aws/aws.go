@@ -10,6 +10,7 @@
 package aws
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
 	"errors"
@@ -19,6 +20,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -38,6 +40,10 @@ type ServiceInfo struct {
 
 // Region defines the URLs where AWS services may be accessed.
 //
+// Endpoints left empty are computed from the region's Name (see
+// Config.Endpoint), so Region{Name: "eu-central-1"} is enough to use a
+// region missing from Regions.
+//
 // See http://goo.gl/d8BP1 for more details.
 type Region struct {
 	Name                   string // the canonical name of this region.
@@ -94,6 +100,8 @@ type AWSService interface {
 type Service struct {
 	service ServiceInfo
 	signer  Signer
+	config  *Config
+	ctx     context.Context
 }
 
 // Create a base set of params for an action
@@ -105,6 +113,12 @@ func MakeParams(action string) map[string]string {
 
 // Create a new AWS server to handle making requests
 func NewService(auth Auth, service ServiceInfo) (s *Service, err error) {
+	return NewServiceWithConfig(auth, service, nil)
+}
+
+// Create a new AWS server that makes requests with the HTTP settings in
+// config. A nil config means DefaultConfig.
+func NewServiceWithConfig(auth Auth, service ServiceInfo, config *Config) (s *Service, err error) {
 	var signer Signer
 	if service.Signer == V2Signature {
 		signer, err = NewV2Signer(auth, service)
@@ -112,10 +126,24 @@ func NewService(auth Auth, service ServiceInfo) (s *Service, err error) {
 	if err != nil {
 		return
 	}
-	s = &Service{service: service, signer: signer}
+	s = &Service{service: service, signer: signer, config: config}
 	return
 }
 
+// WithContext returns a shallow copy of s whose queries are made with ctx,
+// so that cancelling ctx or reaching its deadline aborts them.
+func (s *Service) WithContext(ctx context.Context) *Service {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c := *s
+	c.ctx = ctx
+	return &c
+}
+
+// Query sends a request for the action in params, running the Build, Sign
+// and Send handlers of the service's Config, and returns the response
+// without looking at it. The request is not retried.
 func (s *Service) Query(method, path string, params map[string]string) (resp *http.Response, err error) {
 	params["Timestamp"] = time.Now().UTC().Format(time.RFC3339)
 	u, err := url.Parse(s.service.Endpoint)
@@ -124,15 +152,55 @@ func (s *Service) Query(method, path string, params map[string]string) (resp *ht
 	}
 	u.Path = path
 
+	var hreq *http.Request
+	switch method {
+	case "GET":
+		u.RawQuery = multimap(params).Encode()
+		hreq, err = http.NewRequest(method, u.String(), nil)
+	case "POST":
+		hreq, err = http.NewRequest(method, u.String(), nil)
+	default:
+		return
+	}
+	if err != nil {
+		return nil, err
+	}
+	if method == "POST" {
+		hreq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	name := u.Host
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	req := NewRequest(s.ctx, s.config, name, params["Action"])
+	if err := req.Build(hreq); err != nil {
+		return nil, err
+	}
+	if method == "GET" {
+		// Sign the query as left by the build handlers.
+		params = make(map[string]string)
+		for k, v := range hreq.URL.Query() {
+			params[k] = v[0]
+		}
+	}
 	s.signer.Sign(method, path, params)
+	encoded := multimap(params).Encode()
 	if method == "GET" {
-		u.RawQuery = multimap(params).Encode()
-		resp, err = http.Get(u.String())
-	} else if method == "POST" {
-		resp, err = http.PostForm(u.String(), multimap(params))
+		hreq.URL.RawQuery = encoded
+	} else {
+		hreq.Body = ioutil.NopCloser(strings.NewReader(encoded))
+		hreq.ContentLength = int64(len(encoded))
 	}
+	if err := req.Sign(); err != nil {
+		return nil, err
+	}
+	return req.Send()
+}
 
-	return
+// Retry calls f, retrying it as dictated by the Retryer of the service's
+// Config, and gives up once the service's context is done.
+func (s *Service) Retry(f func() error) error {
+	return s.config.Retry(s.ctx, f)
 }
 
 func (s *Service) BuildError(r *http.Response) error {
@@ -154,11 +222,16 @@ type ErrorResponse struct {
 }
 
 type Error struct {
-	StatusCode int
-	Type       string
-	Code       string
-	Message    string
-	RequestId  string
+	StatusCode  int
+	Type        string
+	Code        string
+	Message     string
+	RequestId   string
+	ServiceName string
+
+	// ErrorCodes, if set, holds the codes of the service beyond those
+	// all services share.
+	ErrorCodes *ErrorCodes `xml:"-"`
 }
 
 func (err *Error) Error() string {
@@ -167,23 +240,91 @@ func (err *Error) Error() string {
 	)
 }
 
+// Retryable reports whether the failed request may be sent again.
+func (err *Error) Retryable() bool {
+	return err.ErrorCodes.Retryable(err.StatusCode, err.Code)
+}
+
+// Throttled reports whether the request was rejected for exceeding a rate
+// limit.
+func (err *Error) Throttled() bool {
+	return err.ErrorCodes.Throttled(err.StatusCode, err.Code)
+}
+
+// The following methods implement APIError.
+
+func (err *Error) Service() string      { return err.ServiceName }
+func (err *Error) HTTPStatus() int      { return err.StatusCode }
+func (err *Error) ErrorCode() string    { return err.Code }
+func (err *Error) ErrorMessage() string { return err.Message }
+func (err *Error) RequestID() string    { return err.RequestId }
+
+// An APIError describes a request that an AWS service failed. The Error
+// types of all the service packages implement it, so that failures can be
+// classified without knowing which service they come from:
+//
+//	if e, ok := err.(aws.APIError); ok && e.ErrorCode() == "AccessDenied" {
+//		...
+//	}
+type APIError interface {
+	RetryableError
+
+	// Service returns the name of the service that failed the request,
+	// as used when signing requests ("s3", "dynamodb", ...).
+	Service() string
+
+	// HTTPStatus returns the HTTP status code of the response.
+	HTTPStatus() int
+
+	// ErrorCode returns the AWS error code ("NoSuchKey", ...).
+	ErrorCode() string
+
+	// ErrorMessage returns the human-oriented error message.
+	ErrorMessage() string
+
+	// RequestID returns the ID AWS assigned to the request, if known.
+	RequestID() string
+}
+
 type Auth struct {
 	AccessKey, SecretKey string
 	token                string
 	expiration           time.Time
+	credentials          *Credentials // non-nil if backed by refreshing credentials.
+}
+
+// Current returns the credentials that should be used to sign a request
+// made now. For an Auth obtained from Credentials.Auth these are fetched
+// from the underlying Credentials, refreshing them if needed; otherwise
+// a copy of a is returned.
+func (a *Auth) Current() (Auth, error) {
+	if a.credentials == nil {
+		return *a, nil
+	}
+	return a.credentials.Get()
 }
 
 func (a *Auth) Token() string {
+	if a.credentials != nil {
+		if cur, err := a.credentials.Get(); err == nil {
+			return cur.token
+		}
+	}
 	if a.token == "" {
 		return ""
 	}
-	if time.Since(a.expiration) >= -30*time.Second { //in an ideal world this should be zero assuming the instance is synching it's clock
+	if a.credentials == nil && !a.expiration.IsZero() && time.Since(a.expiration) >= -30*time.Second { //in an ideal world this should be zero assuming the instance is synching it's clock
 		*a, _ = GetAuth("", "", "", time.Time{})
 	}
 	return a.token
 }
 
 func (a *Auth) Expiration() time.Time {
+	if a.credentials != nil {
+		if cur, err := a.credentials.Get(); err == nil {
+			return cur.expiration
+		}
+	}
 	return a.expiration
 }
 
@@ -235,6 +376,9 @@ type credentials struct {
 	Expiration      string
 }
 
+// metaDataURL is the base URL of the EC2 instance metadata service.
+var metaDataURL = "http://169.254.169.254/latest/meta-data/"
+
 func GetMetaData(path string) (contents []byte, err error) {
 	c := http.Client{
 		Transport: &http.Transport{
@@ -250,7 +394,7 @@ func GetMetaData(path string) (contents []byte, err error) {
 		},
 	}
 
-	url := "http://169.254.169.254/latest/meta-data/" + path
+	url := metaDataURL + path
 
 	resp, err := c.Get(url)
 	if err != nil {
@@ -290,41 +434,32 @@ func getInstanceCredentials() (cred credentials, err error) {
 }
 
 // GetAuth creates an Auth based on either passed in credentials,
-// environment information or instance based role credentials.
+// environment information, the shared credentials file or instance based
+// role credentials. See DefaultChainProvider for the order in which the
+// sources are tried. Temporary credentials found in the chain, such as
+// those of an instance role, are refreshed automatically.
 func GetAuth(accessKey string, secretKey, token string, expiration time.Time) (auth Auth, err error) {
 	// First try passed in credentials
 	if accessKey != "" && secretKey != "" {
-		return Auth{accessKey, secretKey, token, expiration}, nil
+		return Auth{accessKey, secretKey, token, expiration, nil}, nil
 	}
-
-	// Next try to get auth from the environment
-	auth, err = EnvAuth()
-	if err == nil {
-		// Found auth, return
+	chain := DefaultChainProvider()
+	auth, err = chain.Retrieve()
+	if err != nil || auth.expiration.IsZero() {
 		return
 	}
-
-	// Next try getting auth from the instance role
-	cred, err := getInstanceCredentials()
-	if err == nil {
-		// Found auth, return
-		auth.AccessKey = cred.AccessKeyId
-		auth.SecretKey = cred.SecretAccessKey
-		auth.token = cred.Token
-		exptdate, err := time.Parse("2006-01-02T15:04:05Z", cred.Expiration)
-		if err != nil {
-			err = fmt.Errorf("Error Parseing expiration date: cred.Expiration :%s , error: %s \n", cred.Expiration, err)
-		}
-		auth.expiration = exptdate
-		return auth, err
-	}
-	err = errors.New("No valid AWS authentication found")
-	return auth, err
+	// Temporary credentials, such as those of an instance role, are
+	// refreshed before they expire.
+	creds := NewCredentials(chain.Active())
+	creds.auth = auth
+	creds.cached = true
+	auth.credentials = creds
+	return
 }
 
 // EnvAuth creates an Auth based on environment information.
 // The AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment
-// variables are used.
+// variables are used, along with AWS_SESSION_TOKEN if it is set.
 func EnvAuth() (auth Auth, err error) {
 	auth.AccessKey = os.Getenv("AWS_ACCESS_KEY_ID")
 	if auth.AccessKey == "" {
@@ -335,6 +470,7 @@ func EnvAuth() (auth Auth, err error) {
 	if auth.SecretKey == "" {
 		auth.SecretKey = os.Getenv("AWS_SECRET_KEY")
 	}
+	auth.token = os.Getenv("AWS_SESSION_TOKEN")
 	if auth.AccessKey == "" {
 		err = errors.New("AWS_ACCESS_KEY_ID or AWS_ACCESS_KEY not found in environment")
 	}
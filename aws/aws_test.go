@@ -1,8 +1,11 @@
 package aws_test
 
 import (
+	"context"
 	"github.com/hailocab/goamz/aws"
 	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
@@ -91,3 +94,20 @@ func (s *S) TestRegionsAreNamed(c *gocheck.C) {
 		c.Assert(n, gocheck.Equals, r.Name)
 	}
 }
+
+func (s *S) TestServiceQueryWithContext(c *gocheck.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-req.Context().Done()
+	}))
+	defer srv.Close()
+
+	auth := aws.Auth{AccessKey: "access", SecretKey: "secret"}
+	service, err := aws.NewService(auth, aws.ServiceInfo{Endpoint: srv.URL, Signer: aws.V2Signature})
+	c.Assert(err, gocheck.IsNil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = service.WithContext(ctx).Query("GET", "/", map[string]string{})
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(ctx.Err(), gocheck.Equals, context.DeadlineExceeded)
+}
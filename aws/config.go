@@ -0,0 +1,95 @@
+package aws
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Config holds the HTTP settings used by the goamz clients to talk to AWS.
+//
+// A single Config may be shared by any number of clients, which then share
+// its connection pool. A Config must not be modified once it has been used.
+type Config struct {
+	// HTTPClient is the client used to send requests. If it is nil, a
+	// client is built from the remaining settings.
+	HTTPClient *http.Client
+
+	// ConnectTimeout limits the time spent establishing a connection.
+	// Zero means no limit.
+	ConnectTimeout time.Duration
+
+	// RequestTimeout limits the time a request may take, including
+	// reading the response body. Zero means no limit.
+	RequestTimeout time.Duration
+
+	// Proxy returns the proxy to use for a given request. If nil,
+	// http.ProxyFromEnvironment is used.
+	Proxy func(*http.Request) (*url.URL, error)
+
+	// TLSClientConfig is the TLS configuration used for HTTPS
+	// connections. If nil, the default configuration is used.
+	TLSClientConfig *tls.Config
+
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept
+	// open to each host. If zero, http.DefaultMaxIdleConnsPerHost is used.
+	MaxIdleConnsPerHost int
+
+	// Retryer decides which failed requests are retried and when. If
+	// nil, DefaultConfig's Retryer is used, or a zero DefaultRetryer if
+	// that is nil too.
+	Retryer Retryer
+
+	// Handlers are run at each phase of every request made with the
+	// Config. See Request.
+	Handlers Handlers
+
+	// EndpointResolver, if not nil, decides the endpoints requests are
+	// sent to, overriding those set in the clients' Region. See Endpoint.
+	EndpointResolver EndpointResolver
+
+	once   sync.Once
+	client *http.Client
+}
+
+// DefaultConfig is used by clients that have no Config of their own.
+var DefaultConfig = &Config{}
+
+// Client returns the HTTP client to be used for requests made with c.
+// A nil Config behaves as DefaultConfig.
+func (c *Config) Client() *http.Client {
+	if c == nil {
+		c = DefaultConfig
+	}
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	c.once.Do(func() {
+		c.client = c.newClient()
+	})
+	return c.client
+}
+
+func (c *Config) newClient() *http.Client {
+	proxy := c.Proxy
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+	dialer := &net.Dialer{
+		Timeout:   c.ConnectTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:               proxy,
+			DialContext:         dialer.DialContext,
+			TLSClientConfig:     c.TLSClientConfig,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
+		},
+		Timeout: c.RequestTimeout,
+	}
+}
@@ -0,0 +1,27 @@
+package aws_test
+
+import (
+	"github.com/hailocab/goamz/aws"
+	"launchpad.net/gocheck"
+	"net/http"
+	"time"
+)
+
+func (s *S) TestConfigClientCached(c *gocheck.C) {
+	config := &aws.Config{RequestTimeout: time.Second}
+	client := config.Client()
+	c.Assert(client, gocheck.NotNil)
+	c.Assert(client.Timeout, gocheck.Equals, time.Second)
+	c.Assert(config.Client(), gocheck.Equals, client)
+}
+
+func (s *S) TestConfigHTTPClient(c *gocheck.C) {
+	client := &http.Client{}
+	config := &aws.Config{HTTPClient: client, RequestTimeout: time.Second}
+	c.Assert(config.Client(), gocheck.Equals, client)
+}
+
+func (s *S) TestConfigNil(c *gocheck.C) {
+	var config *aws.Config
+	c.Assert(config.Client(), gocheck.Equals, aws.DefaultConfig.Client())
+}
@@ -0,0 +1,313 @@
+package aws
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A CredentialsProvider is a source of AWS credentials.
+//
+// Retrieve is called every time credentials are needed, so implementations
+// that talk to remote services should be wrapped in something that caches
+// their result. Implementations must be safe for concurrent use.
+type CredentialsProvider interface {
+	Retrieve() (Auth, error)
+}
+
+// ProviderFunc adapts an ordinary function to the CredentialsProvider
+// interface, which makes it easy to plug custom credential sources into
+// a ChainProvider.
+type ProviderFunc func() (Auth, error)
+
+func (f ProviderFunc) Retrieve() (Auth, error) {
+	return f()
+}
+
+// StaticProvider returns a fixed set of credentials.
+type StaticProvider struct {
+	Auth Auth
+}
+
+func (p *StaticProvider) Retrieve() (Auth, error) {
+	if p.Auth.AccessKey == "" || p.Auth.SecretKey == "" {
+		return Auth{}, errors.New("static credentials are empty")
+	}
+	return p.Auth, nil
+}
+
+// EnvProvider retrieves credentials from the environment, as described
+// in EnvAuth.
+type EnvProvider struct{}
+
+func (p *EnvProvider) Retrieve() (Auth, error) {
+	return EnvAuth()
+}
+
+// SharedCredentialsProvider retrieves credentials from a profile in the
+// shared credentials file used by the AWS command line tools.
+type SharedCredentialsProvider struct {
+	// Filename is the path of the credentials file. If empty, the
+	// AWS_SHARED_CREDENTIALS_FILE environment variable is used, falling
+	// back to ~/.aws/credentials.
+	Filename string
+
+	// Profile is the name of the profile to read. If empty, the
+	// AWS_PROFILE environment variable is used, falling back to "default".
+	Profile string
+}
+
+func (p *SharedCredentialsProvider) filename() (string, error) {
+	if p.Filename != "" {
+		return p.Filename, nil
+	}
+	if name := os.Getenv("AWS_SHARED_CREDENTIALS_FILE"); name != "" {
+		return name, nil
+	}
+	home := os.Getenv("HOME")
+	if home == "" {
+		home = os.Getenv("USERPROFILE") // Windows
+	}
+	if home == "" {
+		return "", errors.New("cannot locate shared credentials file: home directory not set")
+	}
+	return filepath.Join(home, ".aws", "credentials"), nil
+}
+
+func (p *SharedCredentialsProvider) profile() string {
+	if p.Profile != "" {
+		return p.Profile
+	}
+	if profile := os.Getenv("AWS_PROFILE"); profile != "" {
+		return profile
+	}
+	return "default"
+}
+
+func (p *SharedCredentialsProvider) Retrieve() (auth Auth, err error) {
+	filename, err := p.filename()
+	if err != nil {
+		return
+	}
+	profile := p.profile()
+	values, err := readProfile(filename, profile)
+	if err != nil {
+		return
+	}
+	auth.AccessKey = values["aws_access_key_id"]
+	auth.SecretKey = values["aws_secret_access_key"]
+	auth.token = values["aws_session_token"]
+	if auth.AccessKey == "" || auth.SecretKey == "" {
+		err = fmt.Errorf("profile %q in %s has no aws_access_key_id or aws_secret_access_key", profile, filename)
+	}
+	return
+}
+
+// readProfile reads the key/value pairs of the named section of an
+// INI-style file such as ~/.aws/credentials.
+func readProfile(filename, profile string) (map[string]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var values map[string]string
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == profile && values == nil {
+				values = make(map[string]string)
+			}
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		if i := strings.Index(line, "="); i > 0 {
+			values[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if values == nil {
+		return nil, fmt.Errorf("profile %q not found in %s", profile, filename)
+	}
+	return values, nil
+}
+
+// InstanceMetadataProvider retrieves the credentials of the IAM role
+// attached to the running EC2 instance from the instance metadata service.
+type InstanceMetadataProvider struct{}
+
+func (p *InstanceMetadataProvider) Retrieve() (auth Auth, err error) {
+	cred, err := getInstanceCredentials()
+	if err != nil {
+		return
+	}
+	auth.AccessKey = cred.AccessKeyId
+	auth.SecretKey = cred.SecretAccessKey
+	auth.token = cred.Token
+	auth.expiration, err = time.Parse("2006-01-02T15:04:05Z", cred.Expiration)
+	if err != nil {
+		err = fmt.Errorf("Error Parseing expiration date: cred.Expiration :%s , error: %s \n", cred.Expiration, err)
+	}
+	return
+}
+
+// ChainProvider tries each of its providers in order and returns the
+// credentials of the first one that succeeds. The provider that supplied
+// the last successfully retrieved credentials is available via Active.
+type ChainProvider struct {
+	Providers []CredentialsProvider
+
+	mu     sync.Mutex
+	active CredentialsProvider
+}
+
+// NewChainProvider returns a ChainProvider that consults the given
+// providers in order.
+func NewChainProvider(providers ...CredentialsProvider) *ChainProvider {
+	return &ChainProvider{Providers: providers}
+}
+
+// DefaultChainProvider returns the chain used by GetAuth when no explicit
+// credentials are given: the environment, the shared credentials file and
+// finally the instance metadata service.
+func DefaultChainProvider() *ChainProvider {
+	return NewChainProvider(
+		&EnvProvider{},
+		&SharedCredentialsProvider{},
+		&InstanceMetadataProvider{},
+	)
+}
+
+func (c *ChainProvider) Retrieve() (Auth, error) {
+	var msgs []string
+	for _, p := range c.Providers {
+		auth, err := p.Retrieve()
+		if err == nil {
+			c.mu.Lock()
+			c.active = p
+			c.mu.Unlock()
+			return auth, nil
+		}
+		msgs = append(msgs, err.Error())
+	}
+	c.mu.Lock()
+	c.active = nil
+	c.mu.Unlock()
+	if len(msgs) == 0 {
+		return Auth{}, errors.New("No valid AWS authentication found")
+	}
+	return Auth{}, fmt.Errorf("No valid AWS authentication found: %s", strings.Join(msgs, "; "))
+}
+
+// Active returns the provider that supplied the credentials returned by
+// the last successful call to Retrieve, or nil if there was none.
+func (c *ChainProvider) Active() CredentialsProvider {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active
+}
+
+// DefaultExpiryWindow is how long before their expiration time Credentials
+// retrieves fresh temporary credentials.
+const DefaultExpiryWindow = 5 * time.Minute
+
+// Credentials caches the credentials returned by a provider and retrieves
+// new ones shortly before they expire, which makes it suitable for the
+// temporary credentials of instance roles and assumed roles. It is safe
+// for concurrent use.
+//
+// An Auth backed by Credentials (see the Auth method) can be handed to any
+// of the goamz clients, which will then sign every request with current
+// credentials.
+type Credentials struct {
+	// ExpiryWindow is how long before the expiration of the cached
+	// credentials new ones are retrieved. It defaults to DefaultExpiryWindow.
+	ExpiryWindow time.Duration
+
+	provider CredentialsProvider
+
+	mu     sync.Mutex
+	auth   Auth
+	cached bool
+}
+
+// NewCredentials returns Credentials that retrieve their values from provider.
+func NewCredentials(provider CredentialsProvider) *Credentials {
+	return &Credentials{ExpiryWindow: DefaultExpiryWindow, provider: provider}
+}
+
+// NewInstanceCredentials returns Credentials for the IAM role of the running
+// EC2 instance.
+func NewInstanceCredentials() *Credentials {
+	return NewCredentials(&InstanceMetadataProvider{})
+}
+
+// Get returns the cached credentials, retrieving new ones from the provider
+// first if there are none or they are about to expire. If retrieving fails
+// while the cached credentials are still valid, those are returned.
+func (c *Credentials) Get() (Auth, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cached && !c.expiring(c.ExpiryWindow) {
+		return c.auth, nil
+	}
+	auth, err := c.provider.Retrieve()
+	if err != nil {
+		if c.cached && !c.expiring(0) {
+			return c.auth, nil
+		}
+		return Auth{}, err
+	}
+	auth.credentials = nil
+	c.auth = auth
+	c.cached = true
+	return auth, nil
+}
+
+// Expire discards the cached credentials so that the next call to Get
+// retrieves new ones.
+func (c *Credentials) Expire() {
+	c.mu.Lock()
+	c.cached = false
+	c.mu.Unlock()
+}
+
+// IsExpired returns whether the next call to Get will retrieve new
+// credentials from the provider.
+func (c *Credentials) IsExpired() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.cached || c.expiring(c.ExpiryWindow)
+}
+
+func (c *Credentials) expiring(window time.Duration) bool {
+	exp := c.auth.expiration
+	return !exp.IsZero() && !time.Now().Add(window).Before(exp)
+}
+
+// Auth returns an Auth backed by c. Clients given such an Auth fetch
+// current credentials from c before signing each request.
+func (c *Credentials) Auth() (Auth, error) {
+	auth, err := c.Get()
+	if err != nil {
+		return Auth{}, err
+	}
+	auth.credentials = c
+	return auth, nil
+}
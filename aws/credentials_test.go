@@ -0,0 +1,277 @@
+package aws_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hailocab/goamz/aws"
+	"io/ioutil"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var sharedCredentials = `
+[default]
+aws_access_key_id = default-access
+aws_secret_access_key = default-secret
+
+# A profile using temporary credentials.
+[other]
+aws_access_key_id=other-access
+aws_secret_access_key=other-secret
+aws_session_token=other-token
+`
+
+func (s *S) writeSharedCredentials(c *gocheck.C) string {
+	filename := filepath.Join(c.MkDir(), "credentials")
+	err := ioutil.WriteFile(filename, []byte(sharedCredentials), 0600)
+	c.Assert(err, gocheck.IsNil)
+	return filename
+}
+
+func (s *S) TestStaticProvider(c *gocheck.C) {
+	p := &aws.StaticProvider{aws.Auth{AccessKey: "access", SecretKey: "secret"}}
+	auth, err := p.Retrieve()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth, gocheck.Equals, aws.Auth{AccessKey: "access", SecretKey: "secret"})
+
+	_, err = (&aws.StaticProvider{}).Retrieve()
+	c.Assert(err, gocheck.ErrorMatches, "static credentials are empty")
+}
+
+func (s *S) TestEnvProviderSessionToken(c *gocheck.C) {
+	os.Clearenv()
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	os.Setenv("AWS_ACCESS_KEY_ID", "access")
+	os.Setenv("AWS_SESSION_TOKEN", "token")
+	auth, err := (&aws.EnvProvider{}).Retrieve()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth.AccessKey, gocheck.Equals, "access")
+	c.Assert(auth.SecretKey, gocheck.Equals, "secret")
+	c.Assert(auth.Token(), gocheck.Equals, "token")
+}
+
+func (s *S) TestSharedCredentialsProvider(c *gocheck.C) {
+	os.Clearenv()
+	filename := s.writeSharedCredentials(c)
+
+	auth, err := (&aws.SharedCredentialsProvider{Filename: filename}).Retrieve()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth, gocheck.Equals, aws.Auth{AccessKey: "default-access", SecretKey: "default-secret"})
+
+	auth, err = (&aws.SharedCredentialsProvider{Filename: filename, Profile: "other"}).Retrieve()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth.AccessKey, gocheck.Equals, "other-access")
+	c.Assert(auth.SecretKey, gocheck.Equals, "other-secret")
+	c.Assert(auth.Token(), gocheck.Equals, "other-token")
+
+	_, err = (&aws.SharedCredentialsProvider{Filename: filename, Profile: "missing"}).Retrieve()
+	c.Assert(err, gocheck.ErrorMatches, `profile "missing" not found in .*`)
+}
+
+func (s *S) TestSharedCredentialsProviderEnv(c *gocheck.C) {
+	os.Clearenv()
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", s.writeSharedCredentials(c))
+	os.Setenv("AWS_PROFILE", "other")
+	auth, err := (&aws.SharedCredentialsProvider{}).Retrieve()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth.AccessKey, gocheck.Equals, "other-access")
+}
+
+func (s *S) TestSharedCredentialsProviderHome(c *gocheck.C) {
+	os.Clearenv()
+	home := c.MkDir()
+	os.Setenv("HOME", home)
+	err := os.Mkdir(filepath.Join(home, ".aws"), 0700)
+	c.Assert(err, gocheck.IsNil)
+	err = ioutil.WriteFile(filepath.Join(home, ".aws", "credentials"), []byte(sharedCredentials), 0600)
+	c.Assert(err, gocheck.IsNil)
+	auth, err := (&aws.SharedCredentialsProvider{}).Retrieve()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth.AccessKey, gocheck.Equals, "default-access")
+}
+
+// metaDataServer serves instance role credentials expiring at expiration,
+// counting in hits how many times they have been requested.
+func metaDataServer(expiration time.Time, hits *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/iam/security-credentials/":
+			fmt.Fprint(w, "role")
+		case "/iam/security-credentials/role":
+			*hits++
+			fmt.Fprintf(w, `{
+  "Code" : "Success",
+  "Type" : "AWS-HMAC",
+  "AccessKeyId" : "instance-access",
+  "SecretAccessKey" : "instance-secret",
+  "Token" : "instance-token",
+  "Expiration" : %q
+}`, expiration.UTC().Format("2006-01-02T15:04:05Z"))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+}
+
+func (s *S) TestInstanceMetadataProvider(c *gocheck.C) {
+	expiration := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	var hits int
+	srv := metaDataServer(expiration, &hits)
+	defer srv.Close()
+	aws.SetMetaDataURL(srv.URL + "/")
+	defer aws.SetMetaDataURL("")
+
+	auth, err := (&aws.InstanceMetadataProvider{}).Retrieve()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth.AccessKey, gocheck.Equals, "instance-access")
+	c.Assert(auth.SecretKey, gocheck.Equals, "instance-secret")
+	c.Assert(auth.Token(), gocheck.Equals, "instance-token")
+	c.Assert(auth.Expiration().Equal(expiration), gocheck.Equals, true)
+}
+
+func (s *S) TestChainProvider(c *gocheck.C) {
+	failing := aws.ProviderFunc(func() (aws.Auth, error) {
+		return aws.Auth{}, errors.New("no luck")
+	})
+	static := &aws.StaticProvider{aws.Auth{AccessKey: "access", SecretKey: "secret"}}
+	chain := aws.NewChainProvider(failing, static)
+	c.Assert(chain.Active(), gocheck.IsNil)
+
+	auth, err := chain.Retrieve()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth, gocheck.Equals, static.Auth)
+	c.Assert(chain.Active(), gocheck.Equals, static)
+}
+
+func (s *S) TestChainProviderNoCredentials(c *gocheck.C) {
+	os.Clearenv()
+	chain := aws.NewChainProvider(&aws.EnvProvider{}, &aws.StaticProvider{})
+	_, err := chain.Retrieve()
+	c.Assert(err, gocheck.ErrorMatches, "No valid AWS authentication found: AWS_SECRET_ACCESS_KEY .*; static credentials are empty")
+	c.Assert(chain.Active(), gocheck.IsNil)
+}
+
+func (s *S) TestGetAuthSharedCredentials(c *gocheck.C) {
+	os.Clearenv()
+	os.Setenv("AWS_SHARED_CREDENTIALS_FILE", s.writeSharedCredentials(c))
+	auth, err := aws.GetAuth("", "", "", time.Time{})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth, gocheck.Equals, aws.Auth{AccessKey: "default-access", SecretKey: "default-secret"})
+}
+
+// countingProvider hands out a new access key on every call to Retrieve,
+// each valid for the given duration.
+type countingProvider struct {
+	n     int
+	valid time.Duration
+	err   error
+}
+
+func (p *countingProvider) Retrieve() (aws.Auth, error) {
+	if p.err != nil {
+		return aws.Auth{}, p.err
+	}
+	p.n++
+	key := fmt.Sprintf("access-%d", p.n)
+	return *aws.NewAuth(key, "secret", "token", time.Now().Add(p.valid)), nil
+}
+
+func (s *S) TestCredentialsCaches(c *gocheck.C) {
+	p := &countingProvider{valid: time.Hour}
+	creds := aws.NewCredentials(p)
+	c.Assert(creds.IsExpired(), gocheck.Equals, true)
+	for i := 0; i < 3; i++ {
+		auth, err := creds.Get()
+		c.Assert(err, gocheck.IsNil)
+		c.Assert(auth.AccessKey, gocheck.Equals, "access-1")
+	}
+	c.Assert(creds.IsExpired(), gocheck.Equals, false)
+
+	creds.Expire()
+	c.Assert(creds.IsExpired(), gocheck.Equals, true)
+	auth, err := creds.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth.AccessKey, gocheck.Equals, "access-2")
+}
+
+func (s *S) TestCredentialsRefreshBeforeExpiry(c *gocheck.C) {
+	p := &countingProvider{valid: 2 * time.Minute}
+	creds := aws.NewCredentials(p)
+	auth, err := creds.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth.AccessKey, gocheck.Equals, "access-1")
+
+	// Within the default expiry window, so every call refreshes.
+	auth, err = creds.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth.AccessKey, gocheck.Equals, "access-2")
+
+	creds.ExpiryWindow = time.Minute
+	auth, err = creds.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth.AccessKey, gocheck.Equals, "access-2")
+}
+
+func (s *S) TestCredentialsRefreshFailure(c *gocheck.C) {
+	p := &countingProvider{valid: 2 * time.Minute}
+	creds := aws.NewCredentials(p)
+	_, err := creds.Get()
+	c.Assert(err, gocheck.IsNil)
+
+	// The cached credentials are still valid, so they're used.
+	p.err = errors.New("metadata service unavailable")
+	auth, err := creds.Get()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth.AccessKey, gocheck.Equals, "access-1")
+
+	creds.Expire()
+	_, err = creds.Get()
+	c.Assert(err, gocheck.ErrorMatches, "metadata service unavailable")
+}
+
+func (s *S) TestCredentialsAuth(c *gocheck.C) {
+	p := &countingProvider{valid: time.Hour}
+	creds := aws.NewCredentials(p)
+	auth, err := creds.Auth()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth.AccessKey, gocheck.Equals, "access-1")
+
+	creds.Expire()
+	cur, err := auth.Current()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(cur.AccessKey, gocheck.Equals, "access-2")
+	c.Assert(cur.Token(), gocheck.Equals, "token")
+	c.Assert(auth.Token(), gocheck.Equals, "token")
+	c.Assert(auth.Expiration(), gocheck.Equals, cur.Expiration())
+
+	static := aws.Auth{AccessKey: "access", SecretKey: "secret"}
+	cur, err = static.Current()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(cur, gocheck.Equals, static)
+}
+
+func (s *S) TestGetAuthInstanceRefreshes(c *gocheck.C) {
+	os.Clearenv()
+	expiration := time.Now().Add(time.Minute).UTC().Truncate(time.Second)
+	var hits int
+	srv := metaDataServer(expiration, &hits)
+	defer srv.Close()
+	aws.SetMetaDataURL(srv.URL + "/")
+	defer aws.SetMetaDataURL("")
+
+	auth, err := aws.GetAuth("", "", "", time.Time{})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(auth.AccessKey, gocheck.Equals, "instance-access")
+	c.Assert(hits, gocheck.Equals, 1)
+
+	// The credentials expire within the expiry window, so the metadata
+	// service is consulted again before the next request is signed.
+	cur, err := auth.Current()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(cur.Token(), gocheck.Equals, "instance-token")
+	c.Assert(hits, gocheck.Equals, 2)
+}
@@ -0,0 +1,193 @@
+package aws
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// An EndpointResolver computes the URL of the endpoint of a service in a
+// region. Services are identified by the name they are signed with, such
+// as "ec2", "s3", "elasticloadbalancing" or "monitoring".
+type EndpointResolver interface {
+	ResolveEndpoint(service, region string) (string, error)
+}
+
+// EndpointResolverFunc adapts an ordinary function to the EndpointResolver
+// interface.
+type EndpointResolverFunc func(service, region string) (string, error)
+
+func (f EndpointResolverFunc) ResolveEndpoint(service, region string) (string, error) {
+	return f(service, region)
+}
+
+// A Partition is a group of regions sharing a DNS suffix, such as the
+// standard AWS regions or the China regions.
+type Partition struct {
+	ID        string
+	DNSSuffix string
+
+	// Global maps the services that have a single endpoint for the whole
+	// partition to the host name of that endpoint.
+	Global map[string]string
+
+	regions *regexp.Regexp
+}
+
+// Partitions holds the partitions known to DefaultResolver, in the order
+// they are matched against region names.
+var Partitions = []*Partition{
+	{
+		ID:        "aws-cn",
+		DNSSuffix: "amazonaws.com.cn",
+		Global: map[string]string{
+			"iam":     "iam.cn-north-1.amazonaws.com.cn",
+			"route53": "route53.amazonaws.com.cn",
+		},
+		regions: regexp.MustCompile(`^cn-[a-z]+-\d+$`),
+	},
+	{
+		ID:        "aws-us-gov",
+		DNSSuffix: "amazonaws.com",
+		Global: map[string]string{
+			"iam":     "iam.us-gov.amazonaws.com",
+			"route53": "route53.us-gov.amazonaws.com",
+		},
+		regions: regexp.MustCompile(`^us-gov-[a-z]+-\d+$`),
+	},
+	{
+		ID:        "aws",
+		DNSSuffix: "amazonaws.com",
+		Global: map[string]string{
+			"iam":     "iam.amazonaws.com",
+			"route53": "route53.amazonaws.com",
+			"sts":     "sts.amazonaws.com",
+		},
+		regions: regexp.MustCompile(`^[a-z]{2}-[a-z]+-\d+$`),
+	},
+}
+
+// PartitionForRegion returns the partition region belongs to, or nil if
+// it matches none. The empty region belongs to the standard partition,
+// which is enough to resolve its global services.
+func PartitionForRegion(region string) *Partition {
+	for _, p := range Partitions {
+		if region == "" && p.ID == "aws" || p.regions.MatchString(region) {
+			return p
+		}
+	}
+	return nil
+}
+
+// DefaultResolver computes endpoints following the naming scheme of AWS:
+// service.region.suffix for regional services, and a fixed host for the
+// services that are global to a partition.
+type DefaultResolver struct {
+	// UseFIPS selects the FIPS 140-2 validated variant of endpoints.
+	UseFIPS bool
+
+	// UseDualStack selects endpoints reachable over both IPv4 and IPv6.
+	// It is ignored for global services.
+	UseDualStack bool
+
+	// Overrides maps a service, or a service and a region written as
+	// "service/region", to the URL to use for it. A service and region
+	// override takes precedence over a service override.
+	Overrides map[string]string
+}
+
+// DefaultEndpointResolver is used when neither the Config of a client nor
+// DefaultConfig has an EndpointResolver and the client's Region has no
+// endpoint for the service.
+var DefaultEndpointResolver EndpointResolver = &DefaultResolver{}
+
+func (r *DefaultResolver) ResolveEndpoint(service, region string) (string, error) {
+	if service == "" {
+		return "", fmt.Errorf("cannot resolve endpoint: no service given")
+	}
+	if u, ok := r.Overrides[service+"/"+region]; ok {
+		return u, nil
+	}
+	if u, ok := r.Overrides[service]; ok {
+		return u, nil
+	}
+	p := PartitionForRegion(region)
+	if p == nil {
+		return "", fmt.Errorf("cannot resolve %s endpoint: unknown region %q", service, region)
+	}
+	if host, ok := p.Global[service]; ok {
+		if r.UseFIPS {
+			host = strings.Replace(host, service+".", service+"-fips.", 1)
+		}
+		return "https://" + host, nil
+	}
+	if region == "" {
+		return "", fmt.Errorf("cannot resolve %s endpoint: no region given", service)
+	}
+	name := service
+	if r.UseFIPS {
+		name += "-fips"
+	}
+	if r.UseDualStack {
+		name += ".dualstack"
+	}
+	if region == "us-east-1" && !r.UseFIPS && !r.UseDualStack {
+		switch service {
+		case "s3", "sdb":
+			// These predate regional endpoints in us-east-1.
+			return "https://" + service + "." + p.DNSSuffix, nil
+		}
+	}
+	return "https://" + name + "." + region + "." + p.DNSSuffix, nil
+}
+
+// Endpoint returns the URL of the endpoint of service in region. If c or
+// DefaultConfig has an EndpointResolver, it decides. Otherwise the endpoint
+// set in region for the service is used, and if there is none, the one
+// computed by DefaultEndpointResolver. A nil Config behaves as
+// DefaultConfig.
+func (c *Config) Endpoint(service string, region Region) (string, error) {
+	if c == nil {
+		c = DefaultConfig
+	}
+	resolver := c.EndpointResolver
+	if resolver == nil {
+		resolver = DefaultConfig.EndpointResolver
+	}
+	if resolver != nil {
+		return resolver.ResolveEndpoint(service, region.Name)
+	}
+	if u := region.endpoint(service); u != "" {
+		return u, nil
+	}
+	return DefaultEndpointResolver.ResolveEndpoint(service, region.Name)
+}
+
+// endpoint returns the endpoint of service set in r, if any.
+func (r Region) endpoint(service string) string {
+	switch service {
+	case "ec2":
+		return r.EC2Endpoint
+	case "s3":
+		return r.S3Endpoint
+	case "sdb":
+		return r.SDBEndpoint
+	case "sns":
+		return r.SNSEndpoint
+	case "sqs":
+		return r.SQSEndpoint
+	case "iam":
+		return r.IAMEndpoint
+	case "elasticloadbalancing":
+		return r.ELBEndpoint
+	case "dynamodb":
+		return r.DynamoDBEndpoint
+	case "autoscaling":
+		return r.AutoScalingEndpoint
+	case "monitoring":
+		return r.CloudWatchServicepoint.Endpoint
+	case "sts":
+		return r.STSEndpoint
+	}
+	return ""
+}
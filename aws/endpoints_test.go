@@ -0,0 +1,72 @@
+package aws_test
+
+import (
+	"github.com/hailocab/goamz/aws"
+	"launchpad.net/gocheck"
+)
+
+func (s *S) TestDefaultResolver(c *gocheck.C) {
+	tests := []struct {
+		resolver        aws.DefaultResolver
+		service, region string
+		endpoint        string
+	}{
+		{aws.DefaultResolver{}, "ec2", "eu-central-1", "https://ec2.eu-central-1.amazonaws.com"},
+		{aws.DefaultResolver{}, "s3", "us-east-1", "https://s3.amazonaws.com"},
+		{aws.DefaultResolver{}, "s3", "ap-south-1", "https://s3.ap-south-1.amazonaws.com"},
+		{aws.DefaultResolver{}, "iam", "eu-west-1", "https://iam.amazonaws.com"},
+		{aws.DefaultResolver{}, "route53", "", "https://route53.amazonaws.com"},
+		{aws.DefaultResolver{}, "sts", "us-west-2", "https://sts.amazonaws.com"},
+		{aws.DefaultResolver{}, "ec2", "cn-north-1", "https://ec2.cn-north-1.amazonaws.com.cn"},
+		{aws.DefaultResolver{}, "iam", "cn-northwest-1", "https://iam.cn-north-1.amazonaws.com.cn"},
+		{aws.DefaultResolver{}, "sts", "us-gov-west-1", "https://sts.us-gov-west-1.amazonaws.com"},
+		{aws.DefaultResolver{}, "iam", "us-gov-east-1", "https://iam.us-gov.amazonaws.com"},
+		{aws.DefaultResolver{UseFIPS: true}, "dynamodb", "us-west-1", "https://dynamodb-fips.us-west-1.amazonaws.com"},
+		{aws.DefaultResolver{UseFIPS: true}, "iam", "us-east-1", "https://iam-fips.amazonaws.com"},
+		{aws.DefaultResolver{UseDualStack: true}, "s3", "us-east-1", "https://s3.dualstack.us-east-1.amazonaws.com"},
+		{aws.DefaultResolver{Overrides: map[string]string{"sqs": "http://localhost:9324"}}, "sqs", "eu-west-1", "http://localhost:9324"},
+		{aws.DefaultResolver{Overrides: map[string]string{"sqs": "http://a", "sqs/eu-west-1": "http://b"}}, "sqs", "eu-west-1", "http://b"},
+	}
+	for _, t := range tests {
+		endpoint, err := t.resolver.ResolveEndpoint(t.service, t.region)
+		c.Assert(err, gocheck.IsNil)
+		c.Check(endpoint, gocheck.Equals, t.endpoint, gocheck.Commentf("%s in %q", t.service, t.region))
+	}
+}
+
+func (s *S) TestDefaultResolverErrors(c *gocheck.C) {
+	r := &aws.DefaultResolver{}
+	_, err := r.ResolveEndpoint("ec2", "")
+	c.Assert(err, gocheck.ErrorMatches, "cannot resolve ec2 endpoint: no region given")
+	_, err = r.ResolveEndpoint("ec2", "moon-base-1a")
+	c.Assert(err, gocheck.ErrorMatches, `cannot resolve ec2 endpoint: unknown region "moon-base-1a"`)
+	_, err = r.ResolveEndpoint("", "us-east-1")
+	c.Assert(err, gocheck.ErrorMatches, "cannot resolve endpoint: no service given")
+}
+
+func (s *S) TestPartitionForRegion(c *gocheck.C) {
+	c.Assert(aws.PartitionForRegion("us-gov-west-1").ID, gocheck.Equals, "aws-us-gov")
+	c.Assert(aws.PartitionForRegion("cn-north-1").ID, gocheck.Equals, "aws-cn")
+	c.Assert(aws.PartitionForRegion("eu-west-3").ID, gocheck.Equals, "aws")
+	c.Assert(aws.PartitionForRegion("").ID, gocheck.Equals, "aws")
+	c.Assert(aws.PartitionForRegion("local"), gocheck.IsNil)
+}
+
+func (s *S) TestConfigEndpoint(c *gocheck.C) {
+	// Endpoints set in the region win over computed ones.
+	endpoint, err := (*aws.Config)(nil).Endpoint("ec2", aws.USWest)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(endpoint, gocheck.Equals, aws.USWest.EC2Endpoint)
+
+	endpoint, err = (&aws.Config{}).Endpoint("ec2", aws.Region{Name: "eu-central-1"})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(endpoint, gocheck.Equals, "https://ec2.eu-central-1.amazonaws.com")
+
+	// A resolver in the config wins over everything.
+	config := &aws.Config{EndpointResolver: aws.EndpointResolverFunc(func(service, region string) (string, error) {
+		return "http://localhost:4566/" + service + "/" + region, nil
+	})}
+	endpoint, err = config.Endpoint("ec2", aws.USWest)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(endpoint, gocheck.Equals, "http://localhost:4566/ec2/us-west-1")
+}
@@ -27,3 +27,16 @@ func (s *V4Signer) Signature(t time.Time, sts string) string {
 func (s *V4Signer) Authorization(header http.Header, t time.Time, signature string) string {
 	return s.authorization(header, t, signature)
 }
+
+// Instance metadata:
+// Exporting for testing
+
+var originalMetaDataURL = metaDataURL
+
+func SetMetaDataURL(u string) {
+	if u == "" {
+		metaDataURL = originalMetaDataURL
+	} else {
+		metaDataURL = u
+	}
+}
@@ -0,0 +1,228 @@
+package aws
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"time"
+)
+
+// A Request follows a call to an AWS service through its lifecycle, and
+// is what the handlers registered in a Config are given at each phase:
+//
+//	Build      the HTTP request has been built but not signed yet
+//	Sign       the HTTP request has been signed and is about to be sent
+//	Send       the HTTP response, or the error sending the request, is in
+//	Unmarshal  the response has been decoded into Data, or Error is set
+//	Retry      the attempt failed with Error and is about to be retried
+//
+// Every attempt goes through the Build, Sign, Send and Unmarshal phases
+// again. A handler that sets Error during the Build or Sign phase aborts
+// the attempt with that error.
+type Request struct {
+	// Service is the name of the service, as used when signing requests
+	// ("s3", "ec2", ...).
+	Service string
+
+	// Operation is the name of the action being performed
+	// ("DescribeInstances", "PUT", ...).
+	Operation string
+
+	// Context, if not nil, is attached to each HTTP request sent.
+	Context context.Context
+
+	// Attempt is the number of the current attempt, starting at 1.
+	Attempt int
+
+	HTTPRequest  *http.Request
+	HTTPResponse *http.Response
+
+	// Data is the value the response is decoded into, if any.
+	Data interface{}
+
+	// Error is the error the current attempt failed with, if any.
+	Error error
+
+	// RetryDelay is how long to wait before the next attempt. Retry
+	// handlers may change it.
+	RetryDelay time.Duration
+
+	// Retryer, if not nil, is used by Retry instead of the Retryer of
+	// the request's Config.
+	Retryer Retryer
+
+	config *Config
+}
+
+// NewRequest returns a Request for calling operation on service using
+// the HTTP client, retryer and handlers of config.
+func NewRequest(ctx context.Context, config *Config, service, operation string) *Request {
+	if config == nil {
+		config = DefaultConfig
+	}
+	return &Request{
+		Service:   service,
+		Operation: operation,
+		Context:   ctx,
+		Attempt:   1,
+		config:    config,
+	}
+}
+
+// Retry calls attempt, which should take the request through all of its
+// phases once, until it succeeds or the Retryer of the request's Config
+// gives up, running the Retry handlers before each new attempt.
+func (r *Request) Retry(attempt func() error) error {
+	retryer := r.Retryer
+	if retryer == nil {
+		retryer = r.config.retryer()
+	}
+	r.Attempt = 0
+	return retry(r.Context, retryer, func() error {
+		r.Attempt++
+		r.HTTPRequest, r.HTTPResponse, r.Error = nil, nil, nil
+		return attempt()
+	}, func(attempt int, err error) time.Duration {
+		r.Error = err
+		r.RetryDelay = retryer.RetryDelay(attempt, err)
+		r.config.Handlers.Retry.Run(r)
+		return r.RetryDelay
+	})
+}
+
+// Build runs the Build handlers for hreq, which becomes the request's
+// HTTPRequest, and returns the request's Error.
+func (r *Request) Build(hreq *http.Request) error {
+	r.HTTPRequest, r.HTTPResponse, r.Error = hreq, nil, nil
+	r.config.Handlers.Build.Run(r)
+	return r.Error
+}
+
+// Sign runs the Sign handlers and returns the request's Error.
+func (r *Request) Sign() error {
+	r.config.Handlers.Sign.Run(r)
+	return r.Error
+}
+
+// Send sends the request's HTTPRequest with the HTTP client of the
+// request's Config, runs the Send handlers and returns the resulting
+// HTTPResponse and Error. If the request's Context is done, its error is
+// returned rather than the one from the HTTP client.
+func (r *Request) Send() (*http.Response, error) {
+	hreq := r.HTTPRequest
+	if r.Context != nil {
+		hreq = hreq.WithContext(r.Context)
+	}
+	r.HTTPResponse, r.Error = r.config.Client().Do(hreq)
+	if r.Error != nil {
+		r.HTTPResponse = nil
+	}
+	if r.Error != nil && r.Context != nil && r.Context.Err() != nil {
+		r.Error = r.Context.Err()
+	}
+	r.config.Handlers.Send.Run(r)
+	return r.HTTPResponse, r.Error
+}
+
+// Unmarshal records that the response was decoded into data, with err
+// being the outcome of the attempt, runs the Unmarshal handlers and
+// returns the request's Error.
+func (r *Request) Unmarshal(data interface{}, err error) error {
+	r.Data, r.Error = data, err
+	r.config.Handlers.Unmarshal.Run(r)
+	return r.Error
+}
+
+// A NamedHandler is a handler that can be removed from a HandlerList by
+// its name.
+type NamedHandler struct {
+	Name string
+	Fn   func(*Request)
+}
+
+// A HandlerList is the list of handlers run for a phase of a request.
+type HandlerList struct {
+	list []NamedHandler
+}
+
+// PushBack appends an anonymous handler to l.
+func (l *HandlerList) PushBack(fn func(*Request)) {
+	l.list = append(l.list, NamedHandler{Fn: fn})
+}
+
+// PushBackNamed appends h to l.
+func (l *HandlerList) PushBackNamed(h NamedHandler) {
+	l.list = append(l.list, h)
+}
+
+// PushFront prepends an anonymous handler to l.
+func (l *HandlerList) PushFront(fn func(*Request)) {
+	l.PushFrontNamed(NamedHandler{Fn: fn})
+}
+
+// PushFrontNamed prepends h to l.
+func (l *HandlerList) PushFrontNamed(h NamedHandler) {
+	l.list = append([]NamedHandler{h}, l.list...)
+}
+
+// Remove removes all handlers named name from l.
+func (l *HandlerList) Remove(name string) {
+	list := l.list[:0]
+	for _, h := range l.list {
+		if h.Name != name {
+			list = append(list, h)
+		}
+	}
+	l.list = list
+}
+
+// Len returns the number of handlers in l.
+func (l *HandlerList) Len() int {
+	return len(l.list)
+}
+
+// Run calls the handlers in l in order.
+func (l *HandlerList) Run(r *Request) {
+	for _, h := range l.list {
+		h.Fn(r)
+	}
+}
+
+// Handlers holds the handlers run at each phase of a request. See Request
+// for a description of the phases.
+type Handlers struct {
+	Build     HandlerList
+	Sign      HandlerList
+	Send      HandlerList
+	Unmarshal HandlerList
+	Retry     HandlerList
+}
+
+// EnableLogging registers handlers that log every request sent, every
+// response received and every retry to logger.
+func (h *Handlers) EnableLogging(logger *log.Logger) {
+	h.Sign.PushBackNamed(NamedHandler{"aws.LogRequest", func(r *Request) {
+		dump, err := httputil.DumpRequestOut(r.HTTPRequest, true)
+		if err != nil {
+			logger.Printf("%s %s: cannot dump request: %v", r.Service, r.Operation, err)
+			return
+		}
+		logger.Printf("%s %s request (attempt %d):\n%s", r.Service, r.Operation, r.Attempt, dump)
+	}})
+	h.Send.PushBackNamed(NamedHandler{"aws.LogResponse", func(r *Request) {
+		if r.Error != nil {
+			logger.Printf("%s %s failed: %v", r.Service, r.Operation, r.Error)
+			return
+		}
+		dump, err := httputil.DumpResponse(r.HTTPResponse, true)
+		if err != nil {
+			logger.Printf("%s %s: cannot dump response: %v", r.Service, r.Operation, err)
+			return
+		}
+		logger.Printf("%s %s response:\n%s", r.Service, r.Operation, dump)
+	}})
+	h.Retry.PushBackNamed(NamedHandler{"aws.LogRetry", func(r *Request) {
+		logger.Printf("%s %s attempt %d failed, retrying in %v: %v", r.Service, r.Operation, r.Attempt, r.RetryDelay, r.Error)
+	}})
+}
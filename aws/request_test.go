@@ -0,0 +1,131 @@
+package aws_test
+
+import (
+	"bytes"
+	"github.com/hailocab/goamz/aws"
+	"launchpad.net/gocheck"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+func (s *S) TestHandlerList(c *gocheck.C) {
+	var calls []string
+	record := func(name string) func(*aws.Request) {
+		return func(*aws.Request) { calls = append(calls, name) }
+	}
+	var l aws.HandlerList
+	l.PushBack(record("b"))
+	l.PushBackNamed(aws.NamedHandler{"c", record("c")})
+	l.PushFrontNamed(aws.NamedHandler{"a", record("a")})
+	l.PushFront(record("first"))
+	c.Assert(l.Len(), gocheck.Equals, 4)
+
+	l.Run(&aws.Request{})
+	c.Assert(calls, gocheck.DeepEquals, []string{"first", "a", "b", "c"})
+
+	l.Remove("a")
+	l.Remove("c")
+	c.Assert(l.Len(), gocheck.Equals, 2)
+	calls = nil
+	l.Run(&aws.Request{})
+	c.Assert(calls, gocheck.DeepEquals, []string{"first", "b"})
+}
+
+func (s *S) TestRequestPhases(c *gocheck.C) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		hits++
+		c.Check(req.Header.Get("X-Test"), gocheck.Equals, "built")
+		if hits == 1 {
+			w.WriteHeader(503)
+		}
+	}))
+	defer srv.Close()
+
+	var phases []string
+	config := &aws.Config{Retryer: aws.DefaultRetryer{Attempts: 3, BaseDelay: time.Millisecond}}
+	config.Handlers.Build.PushBack(func(r *aws.Request) {
+		phases = append(phases, "build")
+		r.HTTPRequest.Header.Set("X-Test", "built")
+	})
+	config.Handlers.Sign.PushBack(func(r *aws.Request) { phases = append(phases, "sign") })
+	config.Handlers.Send.PushBack(func(r *aws.Request) { phases = append(phases, "send") })
+	config.Handlers.Unmarshal.PushBack(func(r *aws.Request) { phases = append(phases, "unmarshal") })
+	config.Handlers.Retry.PushBack(func(r *aws.Request) {
+		phases = append(phases, "retry")
+		c.Check(r.Attempt, gocheck.Equals, 1)
+		c.Check(r.Error, gocheck.ErrorMatches, ".*503 Service Unavailable")
+		r.RetryDelay = 0
+	})
+
+	req := aws.NewRequest(nil, config, "test", "Op")
+	err := req.Retry(func() error {
+		hreq, err := http.NewRequest("GET", srv.URL, nil)
+		c.Assert(err, gocheck.IsNil)
+		if err := req.Build(hreq); err != nil {
+			return err
+		}
+		if err := req.Sign(); err != nil {
+			return err
+		}
+		r, err := req.Send()
+		if err != nil {
+			return err
+		}
+		r.Body.Close()
+		if r.StatusCode != 200 {
+			return req.Unmarshal(nil, &aws.Error{StatusCode: r.StatusCode, Message: r.Status})
+		}
+		return req.Unmarshal(nil, nil)
+	})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(hits, gocheck.Equals, 2)
+	c.Assert(req.Attempt, gocheck.Equals, 2)
+	c.Assert(phases, gocheck.DeepEquals, []string{
+		"build", "sign", "send", "unmarshal", "retry",
+		"build", "sign", "send", "unmarshal",
+	})
+}
+
+func (s *S) TestRequestBuildError(c *gocheck.C) {
+	config := &aws.Config{}
+	config.Handlers.Build.PushBack(func(r *aws.Request) {
+		r.Error = &aws.Error{StatusCode: 400, Message: "rejected by handler"}
+	})
+	req := aws.NewRequest(nil, config, "test", "Op")
+	hreq, err := http.NewRequest("GET", "http://localhost/", nil)
+	c.Assert(err, gocheck.IsNil)
+	err = req.Build(hreq)
+	c.Assert(err, gocheck.ErrorMatches, ".*rejected by handler")
+}
+
+func (s *S) TestServiceQueryHandlers(c *gocheck.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.Check(req.URL.Query().Get("Extra"), gocheck.Equals, "value")
+		c.Check(req.URL.Query().Get("Signature"), gocheck.Not(gocheck.Equals), "")
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	config := &aws.Config{}
+	config.Handlers.Build.PushBack(func(r *aws.Request) {
+		c.Check(r.Operation, gocheck.Equals, "Describe")
+		q := r.HTTPRequest.URL.Query()
+		q.Set("Extra", "value")
+		r.HTTPRequest.URL.RawQuery = q.Encode()
+	})
+	config.Handlers.EnableLogging(log.New(&buf, "", 0))
+
+	auth := aws.Auth{AccessKey: "access", SecretKey: "secret"}
+	service, err := aws.NewServiceWithConfig(auth, aws.ServiceInfo{srv.URL, aws.V2Signature}, config)
+	c.Assert(err, gocheck.IsNil)
+	r, err := service.Query("GET", "/", aws.MakeParams("Describe"))
+	c.Assert(err, gocheck.IsNil)
+	r.Body.Close()
+	c.Assert(r.StatusCode, gocheck.Equals, 200)
+	c.Assert(strings.Contains(buf.String(), "Describe request (attempt 1)"), gocheck.Equals, true)
+	c.Assert(strings.Contains(buf.String(), "Describe response"), gocheck.Equals, true)
+}
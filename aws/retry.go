@@ -0,0 +1,237 @@
+package aws
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"time"
+)
+
+// A Retryer decides whether a failed request is sent again and how long
+// to wait before doing so. Implementations must be safe for concurrent use.
+type Retryer interface {
+	// MaxAttempts returns the maximum number of times a request is sent,
+	// including the first attempt.
+	MaxAttempts() int
+
+	// ShouldRetry reports whether a request that failed with err may be
+	// sent again.
+	ShouldRetry(err error) bool
+
+	// RetryDelay returns how long to wait before sending a request again
+	// after its attempt'th attempt failed with err.
+	RetryDelay(attempt int, err error) time.Duration
+}
+
+// Defaults used by DefaultRetryer for its zero fields.
+const (
+	DefaultMaxAttempts   = 4
+	DefaultBaseDelay     = 50 * time.Millisecond
+	DefaultThrottleDelay = 500 * time.Millisecond
+	DefaultMaxDelay      = 20 * time.Second
+)
+
+// DefaultRetryer retries requests that failed with an error IsRetryable
+// approves of, backing off exponentially with jitter between attempts.
+// Throttled requests back off from a larger base delay.
+type DefaultRetryer struct {
+	// Attempts is the maximum number of attempts per request. If zero,
+	// DefaultMaxAttempts is used. Set it to 1 to disable retries.
+	Attempts int
+
+	// BaseDelay is the delay the backoff starts from. If zero,
+	// DefaultBaseDelay is used.
+	BaseDelay time.Duration
+
+	// ThrottleDelay is the delay the backoff starts from when a request
+	// was throttled. If zero, DefaultThrottleDelay is used.
+	ThrottleDelay time.Duration
+
+	// MaxDelay limits the delay between attempts. If zero,
+	// DefaultMaxDelay is used.
+	MaxDelay time.Duration
+}
+
+func (r DefaultRetryer) MaxAttempts() int {
+	if r.Attempts == 0 {
+		return DefaultMaxAttempts
+	}
+	return r.Attempts
+}
+
+func (r DefaultRetryer) ShouldRetry(err error) bool {
+	return IsRetryable(err)
+}
+
+func (r DefaultRetryer) RetryDelay(attempt int, err error) time.Duration {
+	base, max := r.BaseDelay, r.MaxDelay
+	if IsThrottle(err) {
+		base = r.ThrottleDelay
+		if base == 0 {
+			base = DefaultThrottleDelay
+		}
+	} else if base == 0 {
+		base = DefaultBaseDelay
+	}
+	if max == 0 {
+		max = DefaultMaxDelay
+	}
+	delay := max
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt < 32 {
+		if d := base << uint(attempt-1); d > 0 && d < max {
+			delay = d
+		}
+	}
+	// Wait for a random time between half the delay and the full delay,
+	// so that clients failing together don't retry together.
+	half := int64(delay / 2)
+	if half <= 0 {
+		return delay
+	}
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// Retry calls f until it succeeds, fails with an error r won't retry or
+// has been called r.MaxAttempts() times, and returns the error of the last
+// call. If ctx is done while waiting between attempts, ctx.Err() is
+// returned instead. A nil ctx is never done.
+func Retry(ctx context.Context, r Retryer, f func() error) error {
+	return retry(ctx, r, f, func(attempt int, err error) time.Duration {
+		return r.RetryDelay(attempt, err)
+	})
+}
+
+// retry implements Retry, calling delay to learn how long to wait after
+// each failed attempt that is to be retried.
+func retry(ctx context.Context, r Retryer, f func() error, delay func(attempt int, err error) time.Duration) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt >= r.MaxAttempts() || !r.ShouldRetry(err) {
+			return err
+		}
+		t := time.NewTimer(delay(attempt, err))
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Retry calls f as described by the package-level Retry function, using
+// the Retryer in c. If c or its Retryer is nil, DefaultConfig's Retryer is
+// used, and if that is nil too, a zero DefaultRetryer.
+func (c *Config) Retry(ctx context.Context, f func() error) error {
+	return Retry(ctx, c.retryer(), f)
+}
+
+func (c *Config) retryer() Retryer {
+	if c != nil && c.Retryer != nil {
+		return c.Retryer
+	}
+	if DefaultConfig.Retryer != nil {
+		return DefaultConfig.Retryer
+	}
+	return DefaultRetryer{}
+}
+
+// A RetryableError knows whether the failure it describes is transient.
+// The Error types of the service packages implement it.
+type RetryableError interface {
+	error
+
+	// Retryable reports whether the failed request may be sent again.
+	Retryable() bool
+
+	// Throttled reports whether the request was rejected for exceeding
+	// a rate limit.
+	Throttled() bool
+}
+
+// IsRetryable reports whether a request that failed with err may be sent
+// again. Network failures, truncated responses and service errors that
+// report themselves as retryable are; cancellations are not.
+func IsRetryable(err error) bool {
+	if ue, ok := err.(*url.Error); ok {
+		err = ue.Err
+	}
+	switch err {
+	case nil, context.Canceled, context.DeadlineExceeded:
+		return false
+	case io.ErrUnexpectedEOF, io.EOF:
+		return true
+	}
+	switch e := err.(type) {
+	case RetryableError:
+		return e.Retryable()
+	case *net.DNSError:
+		return true
+	case *net.OpError:
+		switch e.Op {
+		case "dial", "read", "write":
+			return true
+		}
+	}
+	return false
+}
+
+// IsThrottle reports whether err is a service error caused by exceeding a
+// rate limit.
+func IsThrottle(err error) bool {
+	e, ok := err.(RetryableError)
+	return ok && e.Throttled()
+}
+
+// ErrorCodes holds the error codes with which a service rejects requests
+// that exceed a rate limit, and those it returns for transient failures,
+// beyond the codes all services share. Each service package declares its
+// own next to its Error type, which classifies itself with them.
+type ErrorCodes struct {
+	Throttle  []string
+	Transient []string
+}
+
+// commonErrorCodes holds the codes all services may return.
+// http://docs.aws.amazon.com/IAM/latest/APIReference/CommonErrors.html
+var commonErrorCodes = ErrorCodes{
+	Throttle:  []string{"Throttling", "ThrottlingException"},
+	Transient: []string{"InternalError", "InternalFailure", "ServiceUnavailable"},
+}
+
+// Retryable reports whether a request that failed with the given HTTP
+// status and error code may be sent again. c may be nil, for a service
+// that only returns the common codes.
+func (c *ErrorCodes) Retryable(status int, code string) bool {
+	return IsRetryableStatus(status) || c.Throttled(status, code) ||
+		hasCode(commonErrorCodes.Transient, code) || c != nil && hasCode(c.Transient, code)
+}
+
+// Throttled reports whether a request that failed with the given HTTP
+// status and error code was rejected for exceeding a rate limit.
+func (c *ErrorCodes) Throttled(status int, code string) bool {
+	return status == 429 || hasCode(commonErrorCodes.Throttle, code) || c != nil && hasCode(c.Throttle, code)
+}
+
+func hasCode(codes []string, code string) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// IsRetryableStatus reports whether a response with the given HTTP status
+// code indicates a transient failure.
+func IsRetryableStatus(status int) bool {
+	return status >= 500 || status == 429
+}
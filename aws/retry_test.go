@@ -0,0 +1,144 @@
+package aws_test
+
+import (
+	"context"
+	"errors"
+	"github.com/hailocab/goamz/aws"
+	"io"
+	"launchpad.net/gocheck"
+	"net"
+	"net/url"
+	"time"
+)
+
+// fastRetryer is a DefaultRetryer that doesn't keep the tests waiting.
+var fastRetryer = aws.DefaultRetryer{
+	Attempts:      3,
+	BaseDelay:     time.Millisecond,
+	ThrottleDelay: time.Millisecond,
+}
+
+func (s *S) TestRetrySucceeds(c *gocheck.C) {
+	calls := 0
+	err := aws.Retry(nil, fastRetryer, func() error {
+		calls++
+		if calls < 2 {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(calls, gocheck.Equals, 2)
+}
+
+func (s *S) TestRetryMaxAttempts(c *gocheck.C) {
+	calls := 0
+	err := aws.Retry(nil, fastRetryer, func() error {
+		calls++
+		return &aws.Error{StatusCode: 503, Code: "ServiceUnavailable"}
+	})
+	c.Assert(err, gocheck.ErrorMatches, ".*ServiceUnavailable.*")
+	c.Assert(calls, gocheck.Equals, 3)
+}
+
+func (s *S) TestRetryNotRetryable(c *gocheck.C) {
+	calls := 0
+	err := aws.Retry(nil, fastRetryer, func() error {
+		calls++
+		return &aws.Error{StatusCode: 400, Code: "ValidationError"}
+	})
+	c.Assert(err, gocheck.ErrorMatches, ".*ValidationError.*")
+	c.Assert(calls, gocheck.Equals, 1)
+}
+
+func (s *S) TestRetryContextDone(c *gocheck.C) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := aws.Retry(ctx, aws.DefaultRetryer{BaseDelay: time.Hour}, func() error {
+		calls++
+		cancel()
+		return io.EOF
+	})
+	c.Assert(err, gocheck.Equals, context.Canceled)
+	c.Assert(calls, gocheck.Equals, 1)
+}
+
+func (s *S) TestConfigRetryer(c *gocheck.C) {
+	calls := 0
+	config := &aws.Config{Retryer: aws.DefaultRetryer{Attempts: 1}}
+	err := config.Retry(nil, func() error {
+		calls++
+		return io.EOF
+	})
+	c.Assert(err, gocheck.Equals, io.EOF)
+	c.Assert(calls, gocheck.Equals, 1)
+}
+
+func (s *S) TestDefaultRetryerDelay(c *gocheck.C) {
+	r := aws.DefaultRetryer{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		for i := 0; i < 10; i++ {
+			d := r.RetryDelay(attempt+1, io.EOF)
+			c.Assert(d >= max/2 && d <= max, gocheck.Equals, true, gocheck.Commentf("attempt %d: %v", attempt+1, d))
+		}
+	}
+	c.Assert(r.RetryDelay(1000, io.EOF) <= time.Second, gocheck.Equals, true)
+
+	throttled := &aws.Error{StatusCode: 400, Code: "Throttling"}
+	d := r.RetryDelay(1, throttled)
+	c.Assert(d >= aws.DefaultThrottleDelay/2 && d <= aws.DefaultThrottleDelay, gocheck.Equals, true)
+}
+
+// route53Codes are service-specific codes, as route53 gives its errors.
+var route53Codes = &aws.ErrorCodes{Throttle: []string{"PriorRequestNotComplete"}}
+
+func (s *S) TestIsRetryable(c *gocheck.C) {
+	tests := []struct {
+		err       error
+		retryable bool
+	}{
+		{nil, false},
+		{errors.New("some error"), false},
+		{io.EOF, true},
+		{io.ErrUnexpectedEOF, true},
+		{context.Canceled, false},
+		{&url.Error{Op: "Get", URL: "http://x", Err: context.DeadlineExceeded}, false},
+		{&url.Error{Op: "Get", URL: "http://x", Err: &net.OpError{Op: "read", Err: errors.New("reset")}}, true},
+		{&net.OpError{Op: "dial", Err: errors.New("refused")}, true},
+		{&net.DNSError{Err: "no such host", Name: "x"}, true},
+		{&aws.Error{StatusCode: 500, Code: "InternalError"}, true},
+		{&aws.Error{StatusCode: 400, Code: "ThrottlingException"}, true},
+		{&aws.Error{StatusCode: 400, Code: "PriorRequestNotComplete"}, false},
+		{&aws.Error{StatusCode: 400, Code: "PriorRequestNotComplete", ErrorCodes: route53Codes}, true},
+		{&aws.Error{StatusCode: 429}, true},
+		{&aws.Error{StatusCode: 403, Code: "AccessDenied"}, false},
+	}
+	for _, t := range tests {
+		c.Check(aws.IsRetryable(t.err), gocheck.Equals, t.retryable, gocheck.Commentf("%#v", t.err))
+	}
+	c.Assert(aws.IsThrottle(&aws.Error{StatusCode: 400, Code: "Throttling"}), gocheck.Equals, true)
+	c.Assert(aws.IsThrottle(&aws.Error{StatusCode: 400, Code: "PriorRequestNotComplete", ErrorCodes: route53Codes}), gocheck.Equals, true)
+	c.Assert(aws.IsThrottle(&aws.Error{StatusCode: 400, Code: "PriorRequestNotComplete"}), gocheck.Equals, false)
+	c.Assert(aws.IsThrottle(&aws.Error{StatusCode: 500, Code: "InternalError"}), gocheck.Equals, false)
+	c.Assert(aws.IsThrottle(io.EOF), gocheck.Equals, false)
+}
+
+func (s *S) TestErrorIsAPIError(c *gocheck.C) {
+	var err error = &aws.Error{
+		StatusCode:  400,
+		Code:        "Throttling",
+		Message:     "Rate exceeded",
+		RequestId:   "req-id",
+		ServiceName: "monitoring",
+	}
+	apiErr, ok := err.(aws.APIError)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(apiErr.Service(), gocheck.Equals, "monitoring")
+	c.Assert(apiErr.HTTPStatus(), gocheck.Equals, 400)
+	c.Assert(apiErr.ErrorCode(), gocheck.Equals, "Throttling")
+	c.Assert(apiErr.ErrorMessage(), gocheck.Equals, "Rate exceeded")
+	c.Assert(apiErr.RequestID(), gocheck.Equals, "req-id")
+	c.Assert(apiErr.Retryable(), gocheck.Equals, true)
+	c.Assert(apiErr.Throttled(), gocheck.Equals, true)
+}
@@ -6,11 +6,13 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -32,9 +34,17 @@ func NewV2Signer(auth Auth, service ServiceInfo) (*V2Signer, error) {
 }
 
 func (s *V2Signer) Sign(method, path string, params map[string]string) {
-	params["AWSAccessKeyId"] = s.auth.AccessKey
+	auth := s.auth
+	if cur, err := s.auth.Current(); err == nil {
+		auth = cur
+	}
+	delete(params, "Signature") // Left over from a previous attempt.
+	params["AWSAccessKeyId"] = auth.AccessKey
 	params["SignatureVersion"] = "2"
 	params["SignatureMethod"] = "HmacSHA256"
+	if token := auth.Token(); token != "" {
+		params["SecurityToken"] = token
+	}
 
 	// AWS specifies that the parameters in a signed request must
 	// be provided in the natural order of the keys. This is distinct
@@ -50,7 +60,7 @@ func (s *V2Signer) Sign(method, path string, params map[string]string) {
 	}
 	joined := strings.Join(sarray, "&")
 	payload := method + "\n" + s.host + "\n" + path + "\n" + joined
-	hash := hmac.New(sha256.New, []byte(s.auth.SecretKey))
+	hash := hmac.New(sha256.New, []byte(auth.SecretKey))
 	hash.Write([]byte(payload))
 	signature := make([]byte, b64.EncodedLen(hash.Size()))
 	b64.Encode(signature, hash.Sum(nil))
@@ -86,8 +96,8 @@ func (s *Route53Signer) getCurrentDate() string {
 }
 
 // Creates the authorize signature based on the date stamp and secret key
-func (s *Route53Signer) getHeaderAuthorize(message string) string {
-	hmacSha256 := hmac.New(sha256.New, []byte(s.auth.SecretKey))
+func (s *Route53Signer) getHeaderAuthorize(auth Auth, message string) string {
+	hmacSha256 := hmac.New(sha256.New, []byte(auth.SecretKey))
 	hmacSha256.Write([]byte(message))
 	cryptedString := hmacSha256.Sum(nil)
 
@@ -97,12 +107,16 @@ func (s *Route53Signer) getHeaderAuthorize(message string) string {
 // Adds all the required headers for AWS Route53 API to the request
 // including the authorization
 func (s *Route53Signer) Sign(req *http.Request) {
+	auth := s.auth
+	if cur, err := s.auth.Current(); err == nil {
+		auth = cur
+	}
 	date := s.getCurrentDate()
 	authHeader := fmt.Sprintf("AWS3-HTTPS AWSAccessKeyId=%s,Algorithm=%s,Signature=%s",
-		s.auth.AccessKey, "HmacSHA256", s.getHeaderAuthorize(date))
+		auth.AccessKey, "HmacSHA256", s.getHeaderAuthorize(auth, date))
 
 	// Add token header in case we use temp credentials
-	token := s.auth.Token()
+	token := auth.Token()
 	if token != "" {
 		req.Header.Set("X-Amz-Security-Token", token)
 	}
@@ -139,20 +153,231 @@ the "host" header to be a signed header, therefor the Sign method will manually
 the request.Host.
 
 The signed request will include a new "Authorization" header indicating that the request has been signed.
+If the signer's credentials include a session token, it is sent in the "x-amz-security-token" header.
 
 Any changes to the request after signing the request will invalidate the signature.
 */
 func (s *V4Signer) Sign(req *http.Request) {
+	s.current().sign(req)
+}
+
+// current returns a signer using the current credentials of s.
+func (s *V4Signer) current() *V4Signer {
+	if cur, err := s.auth.Current(); err == nil {
+		return &V4Signer{auth: cur, serviceName: s.serviceName, region: s.region}
+	}
+	return s
+}
+
+func (s *V4Signer) sign(req *http.Request) (t time.Time, signature string) {
+	if token := s.auth.Token(); token != "" {
+		req.Header.Set("x-amz-security-token", token) // temporary credentials
+	}
 	req.Header.Set("host", req.Host)                  // host header must be included as a signed header
-	t := s.requestTime(req)                           // Get requst time
+	t = s.requestTime(req)                            // Get requst time
 	creq := s.canonicalRequest(req)                   // Build canonical request
 	sts := s.stringToSign(t, creq)                    // Build string to sign
-	signature := s.signature(t, sts)                  // Calculate the AWS Signature Version 4
+	signature = s.signature(t, sts)                   // Calculate the AWS Signature Version 4
 	auth := s.authorization(req.Header, t, signature) // Create Authorization header value
 	req.Header.Set("Authorization", auth)             // Add Authorization header to request
 	return
 }
 
+// StreamingPayload is the x-amz-content-sha256 header value of requests
+// whose body is sent in signed chunks.
+const StreamingPayload = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+
+/*
+SignStreaming signs a request like Sign, but rather than hashing its body up front, it arranges
+for the body to be sent in chunks of chunkSize bytes (at least 8KB), each signed as it is read,
+following the AWS Signature Version 4 streaming process used by S3. See
+https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html.
+
+length is the size of the body, which is replaced by one encoding the signed chunks, and whose
+size the request's ContentLength and "Content-Length" header are set to.
+*/
+func (s *V4Signer) SignStreaming(req *http.Request, length int64, chunkSize int) {
+	s = s.current()
+	req.Header.Set("x-amz-content-sha256", StreamingPayload)
+	req.Header.Set("x-amz-decoded-content-length", strconv.FormatInt(length, 10))
+	if enc := req.Header.Get("content-encoding"); enc == "" {
+		req.Header.Set("content-encoding", "aws-chunked")
+	} else if !strings.HasPrefix(enc, "aws-chunked") {
+		req.Header.Set("content-encoding", "aws-chunked,"+enc)
+	}
+	req.ContentLength = chunkedLength(length, chunkSize)
+	req.Header.Set("content-length", strconv.FormatInt(req.ContentLength, 10))
+	t, seed := s.sign(req)
+	body := req.Body
+	if body == nil {
+		body = ioutil.NopCloser(strings.NewReader(""))
+	}
+	req.Body = &chunkedBody{
+		signer:    s,
+		t:         t,
+		signature: seed,
+		body:      body,
+		buf:       make([]byte, chunkSize),
+	}
+}
+
+// chunkedLength returns the size of a body of length bytes once encoded
+// in signed chunks of chunkSize bytes.
+func chunkedLength(length int64, chunkSize int) int64 {
+	chunk := func(n int64) int64 {
+		// hex-size;chunk-signature=<64 hex digits>\r\n<data>\r\n
+		return int64(len(strconv.FormatInt(n, 16))) + 17 + 64 + 2 + n + 2
+	}
+	size := int64(chunkSize)
+	total := length/size*chunk(size) + chunk(0)
+	if rem := length % size; rem > 0 {
+		total += chunk(rem)
+	}
+	return total
+}
+
+// chunkedBody encodes the body it wraps in signed chunks, ending with an
+// empty chunk.
+type chunkedBody struct {
+	signer    *V4Signer
+	t         time.Time
+	signature string // of the previous chunk
+	body      io.ReadCloser
+	buf       []byte
+	out       []byte
+	done      bool
+}
+
+func (c *chunkedBody) Read(p []byte) (int, error) {
+	for len(c.out) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(c.body, c.buf)
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			if n == 0 {
+				c.done = true
+			}
+		default:
+			return 0, err
+		}
+		c.out = c.chunk(c.buf[:n])
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+func (c *chunkedBody) chunk(data []byte) []byte {
+	w := new(bytes.Buffer)
+	fmt.Fprint(w, "AWS4-HMAC-SHA256-PAYLOAD\n")
+	fmt.Fprintf(w, "%s\n", c.t.Format(ISO8601BasicFormat))
+	fmt.Fprintf(w, "%s\n", c.signer.credentialScope(c.t))
+	fmt.Fprintf(w, "%s\n", c.signature)
+	fmt.Fprintf(w, "%s\n", c.signer.hash(""))
+	fmt.Fprintf(w, "%x", sha256.Sum256(data))
+	c.signature = c.signer.signature(c.t, w.String())
+
+	out := new(bytes.Buffer)
+	fmt.Fprintf(out, "%x;chunk-signature=%s\r\n", len(data), c.signature)
+	out.Write(data)
+	out.WriteString("\r\n")
+	return out.Bytes()
+}
+
+func (c *chunkedBody) Close() error {
+	return c.body.Close()
+}
+
+// MaxPresignExpires is the longest time a presigned request may be valid.
+const MaxPresignExpires = 7 * 24 * time.Hour
+
+/*
+Presign signs a request according to the AWS Signature Version 4 Signing Process, using
+query string parameters rather than headers, so that its URL can be handed to a third party
+who may then send the request until expires has elapsed. See
+https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html.
+
+The signing time is taken from the "x-amz-date" or "date" header as in Sign, and the
+"x-amz-date" header is removed. All remaining headers, plus "host", are signed, so whoever
+sends the request must send them with the same values. S3 requests are signed with an
+unsigned payload, so a presigned PUT may carry any body.
+
+Any changes to the URL of the request after presigning it will invalidate the signature.
+*/
+func (s *V4Signer) Presign(req *http.Request, expires time.Duration) error {
+	if expires < time.Second || expires > MaxPresignExpires {
+		return fmt.Errorf("presigned request expiry must be between 1s and %v, not %v", MaxPresignExpires, expires)
+	}
+	s = s.current()
+	t := s.requestTime(req)
+	req.Header.Del("x-amz-date")
+	req.Header.Set("host", req.Host)
+
+	q := req.URL.Query()
+	for k := range q {
+		if strings.HasPrefix(strings.ToLower(k), "x-amz-") {
+			q.Del(k) // Left over from a previous signature.
+		}
+	}
+	q.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	q.Set("X-Amz-Credential", s.auth.AccessKey+"/"+s.credentialScope(t))
+	q.Set("X-Amz-Date", t.Format(ISO8601BasicFormat))
+	q.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	q.Set("X-Amz-SignedHeaders", s.signedHeaders(req.Header))
+	if token := s.auth.Token(); token != "" {
+		q.Set("X-Amz-Security-Token", token) // temporary credentials
+	}
+	req.URL.RawQuery = q.Encode()
+
+	payload := "UNSIGNED-PAYLOAD"
+	if s.serviceName != "s3" {
+		payload = s.payloadHash(req)
+	}
+	c := new(bytes.Buffer)
+	fmt.Fprintf(c, "%s\n", req.Method)
+	fmt.Fprintf(c, "%s\n", s.canonicalURI(req.URL))
+	fmt.Fprintf(c, "%s\n", s.canonicalQueryString(req.URL))
+	fmt.Fprintf(c, "%s\n\n", s.canonicalHeaders(req.Header))
+	fmt.Fprintf(c, "%s\n", s.signedHeaders(req.Header))
+	fmt.Fprintf(c, "%s", payload)
+
+	signature := s.signature(t, s.stringToSign(t, c.String()))
+	req.URL.RawQuery += "&X-Amz-Signature=" + signature
+	return nil
+}
+
+/*
+PostFields returns the form fields identifying the credentials of s and the signing time t,
+which a browser-based POST upload signed by s must carry, and which its policy must require
+with the same values. See
+https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-UsingHTTPPOST.html.
+
+The signature from SignPost must be made with the same credentials, so s should be created
+with fixed ones, such as those returned by Auth.Current.
+*/
+func (s *V4Signer) PostFields(t time.Time) map[string]string {
+	fields := map[string]string{
+		"x-amz-algorithm":  "AWS4-HMAC-SHA256",
+		"x-amz-credential": s.auth.AccessKey + "/" + s.credentialScope(t),
+		"x-amz-date":       t.Format(ISO8601BasicFormat),
+	}
+	if token := s.auth.Token(); token != "" {
+		fields["x-amz-security-token"] = token // temporary credentials
+	}
+	return fields
+}
+
+/*
+SignPost returns the signature of policy, the base64-encoded policy document of a
+browser-based POST upload signed at time t, to be sent in the "x-amz-signature" field.
+*/
+func (s *V4Signer) SignPost(t time.Time, policy string) string {
+	return s.signature(t, policy)
+}
+
 /*
 requestTime method will parse the time from the request "x-amz-date" or "date" headers.
 If the "x-amz-date" header is present, that will take priority over the "date" header.
@@ -215,6 +440,17 @@ func (s *V4Signer) canonicalRequest(req *http.Request) string {
 }
 
 func (s *V4Signer) canonicalURI(u *url.URL) string {
+	if s.serviceName == "s3" {
+		// S3 keys are used verbatim: neither normalized nor encoded twice.
+		segments := strings.Split(u.Path, "/")
+		for i, seg := range segments {
+			segments[i] = Encode(seg)
+		}
+		if canonicalPath := strings.Join(segments, "/"); canonicalPath != "" {
+			return canonicalPath
+		}
+		return "/"
+	}
 	canonicalPath := u.RequestURI()
 	if u.RawQuery != "" {
 		canonicalPath = canonicalPath[:len(canonicalPath)-len(u.RawQuery)-1]
@@ -229,6 +465,15 @@ func (s *V4Signer) canonicalURI(u *url.URL) string {
 
 func (s *V4Signer) canonicalQueryString(u *url.URL) string {
 	var a []string
+	if s.serviceName == "s3" {
+		for k, vs := range u.Query() {
+			for _, v := range vs {
+				a = append(a, Encode(k)+"="+Encode(v))
+			}
+		}
+		sort.Strings(a)
+		return strings.Join(a, "&")
+	}
 	for k, vs := range u.Query() {
 		k = url.QueryEscape(k)
 		for _, v := range vs {
@@ -245,16 +490,24 @@ func (s *V4Signer) canonicalQueryString(u *url.URL) string {
 }
 
 func (s *V4Signer) canonicalHeaders(h http.Header) string {
+	// Sort by name rather than by line, which would put a header before
+	// those whose name it extends, such as x-amz-copy-source-if-match
+	// before x-amz-copy-source.
 	i, a := 0, make([]string, len(h))
+	values := make(map[string]string, len(h))
 	for k, v := range h {
 		for j, w := range v {
 			v[j] = strings.Trim(w, " ")
 		}
 		sort.Strings(v)
-		a[i] = strings.ToLower(k) + ":" + strings.Join(v, ",")
+		a[i] = strings.ToLower(k)
+		values[a[i]] = strings.Join(v, ",")
 		i++
 	}
 	sort.Strings(a)
+	for i, k := range a {
+		a[i] = k + ":" + values[k]
+	}
 	return strings.Join(a, "\n")
 }
 
@@ -269,6 +522,10 @@ func (s *V4Signer) signedHeaders(h http.Header) string {
 }
 
 func (s *V4Signer) payloadHash(req *http.Request) string {
+	if h := req.Header.Get("x-amz-content-sha256"); h != "" {
+		// Already hashed, unsigned or streamed.
+		return h
+	}
 	var b []byte
 	if req.Body == nil {
 		b = []byte("")
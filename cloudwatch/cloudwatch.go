@@ -15,6 +15,7 @@
 package cloudwatch
 
 import (
+	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -29,6 +30,20 @@ type CloudWatch struct {
 	Service aws.AWSService
 }
 
+// WithContext returns a copy of c whose requests are made with ctx, so that
+// cancelling ctx or reaching its deadline aborts them. The context is only
+// honoured when Service is an *aws.Service.
+func (c *CloudWatch) WithContext(ctx context.Context) *CloudWatch {
+	if ctx == nil {
+		panic("nil context")
+	}
+	cw := *c
+	if s, ok := c.Service.(*aws.Service); ok {
+		cw.Service = s.WithContext(ctx)
+	}
+	return &cw
+}
+
 type Dimension struct {
 	Name  string
 	Value string
@@ -104,12 +119,6 @@ type ListMetricsRequest struct {
 	NextToken  string
 }
 
-var attempts = aws.AttemptStrategy{
-	Min:   5,
-	Total: 5 * time.Second,
-	Delay: 200 * time.Millisecond,
-}
-
 var validUnits = sets.SSet(
 	"Seconds",
 	"Microseconds",
@@ -149,7 +158,13 @@ var validMetricStatistics = sets.SSet(
 
 // Create a new CloudWatch object for a given namespace
 func NewCloudWatch(auth aws.Auth, region aws.ServiceInfo) (*CloudWatch, error) {
-	service, err := aws.NewService(auth, region)
+	return NewCloudWatchWithConfig(auth, region, nil)
+}
+
+// Create a new CloudWatch object that sends requests using the HTTP
+// settings in config
+func NewCloudWatchWithConfig(auth aws.Auth, region aws.ServiceInfo, config *aws.Config) (*CloudWatch, error) {
+	service, err := aws.NewServiceWithConfig(auth, region, config)
 	if err != nil {
 		return nil, err
 	}
@@ -158,10 +173,32 @@ func NewCloudWatch(auth aws.Auth, region aws.ServiceInfo) (*CloudWatch, error) {
 	}, nil
 }
 
+// Create a new CloudWatch object for region, sending requests to the
+// endpoint config resolves for the "monitoring" service
+func NewCloudWatchForRegion(auth aws.Auth, region aws.Region, config *aws.Config) (*CloudWatch, error) {
+	endpoint, err := config.Endpoint("monitoring", region)
+	if err != nil {
+		return nil, err
+	}
+	return NewCloudWatchWithConfig(auth, aws.ServiceInfo{Endpoint: endpoint, Signer: aws.V2Signature}, config)
+}
+
+// query sends a request, retrying it as dictated by the Retryer in the
+// service's Config when Service is an *aws.Service, and decodes the
+// response into resp.
 func (c *CloudWatch) query(method, path string, params map[string]string, resp interface{}) error {
 	// Add basic Cloudwatch param
 	params["Version"] = "2010-08-01"
 
+	if s, ok := c.Service.(*aws.Service); ok {
+		return s.Retry(func() error {
+			return c.queryOnce(method, path, params, resp)
+		})
+	}
+	return c.queryOnce(method, path, params, resp)
+}
+
+func (c *CloudWatch) queryOnce(method, path string, params map[string]string, resp interface{}) error {
 	r, err := c.Service.Query(method, path, params)
 	if err != nil {
 		return err
@@ -169,7 +206,11 @@ func (c *CloudWatch) query(method, path string, params map[string]string, resp i
 	defer r.Body.Close()
 
 	if r.StatusCode != 200 {
-		return c.Service.BuildError(r)
+		err = c.Service.BuildError(r)
+		if e, ok := err.(*aws.Error); ok {
+			e.ServiceName = "monitoring"
+		}
+		return err
 	}
 	err = xml.NewDecoder(r.Body).Decode(resp)
 	return err
@@ -0,0 +1,108 @@
+// The s3sync command synchronizes a local directory with a prefix of an
+// S3 bucket, in the direction its arguments give, using the s3sync
+// package:
+//
+//	s3sync /var/www s3://backups/www
+//	s3sync -delete s3://backups/www /var/www
+//
+// The first uploads the files under /var/www that are missing from, or
+// differ from, the keys under www/ in the backups bucket. The second
+// downloads those keys back, removing the files under /var/www that no
+// key matches. With -dryrun, the operations are only printed.
+//
+// Credentials are taken from the environment, the shared credentials
+// file or the instance role, as with aws.GetAuth. With -endpoint, such
+// as that of the s3test command, requests are sent there rather than to
+// the endpoint of the region.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/hailocab/goamz/aws"
+	"github.com/hailocab/goamz/s3"
+	"github.com/hailocab/goamz/s3/s3sync"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	region      = flag.String("region", "us-east-1", "region of the bucket")
+	endpoint    = flag.String("endpoint", "", "S3 endpoint to use instead of that of the region")
+	del         = flag.Bool("delete", false, "delete files or keys of the destination that the source does not have")
+	dryRun      = flag.Bool("dryrun", false, "print the operations without making them")
+	concurrency = flag.Int("n", s3sync.DefaultConcurrency, "number of files transferred at the same time")
+	acl         = flag.String("acl", string(s3.Private), "canned ACL of uploaded keys")
+	quiet       = flag.Bool("q", false, "do not print the operations made")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: s3sync [flags] dir s3://bucket/prefix\n")
+		fmt.Fprintf(os.Stderr, "       s3sync [flags] s3://bucket/prefix dir\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	src, dst := flag.Arg(0), flag.Arg(1)
+	upload := strings.HasPrefix(dst, "s3://")
+	if upload == strings.HasPrefix(src, "s3://") {
+		log.Fatal("exactly one of the arguments must be an s3:// URL")
+	}
+	url, dir := src, dst
+	if upload {
+		url, dir = dst, src
+	}
+	bucketName, prefix := url[len("s3://"):], ""
+	if i := strings.Index(bucketName, "/"); i >= 0 {
+		bucketName, prefix = bucketName[:i], bucketName[i+1:]
+	}
+	if bucketName == "" {
+		log.Fatalf("%s names no bucket", url)
+	}
+
+	r, ok := aws.Regions[*region]
+	if !ok {
+		log.Fatalf("unknown region %q", *region)
+	}
+	if *endpoint != "" {
+		r.S3Endpoint = *endpoint
+		r.S3BucketEndpoint = ""
+	}
+	auth, err := aws.GetAuth("", "", "", time.Time{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := s3sync.New(s3.New(auth, r).Bucket(bucketName))
+	s.Concurrency = *concurrency
+	s.Delete = *del
+	s.DryRun = *dryRun
+	s.Perm = s3.ACL(*acl)
+	if !*quiet || *dryRun {
+		s.Report = func(op s3sync.Op) {
+			if *dryRun {
+				fmt.Print("(dryrun) ")
+			}
+			fmt.Println(op)
+		}
+	}
+	if upload {
+		err = s.Upload(dir, prefix)
+	} else {
+		err = s.Download(prefix, dir)
+	}
+	if errs, ok := err.(s3sync.Errors); ok {
+		for _, e := range errs {
+			log.Print(e)
+		}
+		log.Fatalf("%d operations failed", len(errs))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,65 @@
+// The s3test command serves a fake S3 for development and integration
+// tests, using the s3test package:
+//
+//	s3test -addr localhost:4567 -dir /var/tmp/s3
+//
+// With -dir, buckets and objects are kept in that directory, and found
+// again the next time s3test is started with it. Otherwise they are held
+// in memory, and lost when s3test exits.
+//
+// With -auth, requests must be signed with the given credentials, and
+// anonymous ones are only allowed where ACLs grant all users access.
+// Otherwise signatures are not checked.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/hailocab/goamz/aws"
+	"github.com/hailocab/goamz/s3/s3test"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+var (
+	addr     = flag.String("addr", "localhost:4567", "address to listen on")
+	dir      = flag.String("dir", "", "directory to keep buckets and objects in, instead of memory")
+	conflict = flag.Bool("409", false, "respond 409 Conflict to PUT on an existing bucket, as all regions but us-east-1 do")
+	auth     = flag.String("auth", "", "`accesskey:secretkey` that requests must be signed with")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: s3test [flags]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	config := &s3test.Config{
+		Addr:            *addr,
+		Dir:             *dir,
+		Send409Conflict: *conflict,
+	}
+	if *auth != "" {
+		i := strings.Index(*auth, ":")
+		if i < 0 {
+			log.Fatalf("-auth %q is not of the form accesskey:secretkey", *auth)
+		}
+		config.Auth = []aws.Auth{{AccessKey: (*auth)[:i], SecretKey: (*auth)[i+1:]}}
+	}
+	srv, err := s3test.NewServer(config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("serving S3 at %s", srv.URL())
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	<-sig
+	srv.Quit()
+}
@@ -2,6 +2,7 @@ package dynamodb
 
 import simplejson "github.com/bitly/go-simplejson"
 import (
+	"context"
 	"errors"
 	"github.com/hailocab/goamz/aws"
 	"io/ioutil"
@@ -14,6 +15,22 @@ import (
 type Server struct {
 	Auth   aws.Auth
 	Region aws.Region
+
+	// Config holds the HTTP settings used to send requests. If nil,
+	// aws.DefaultConfig is used.
+	Config *aws.Config
+	ctx    context.Context
+}
+
+// WithContext returns a shallow copy of s whose requests are made with
+// ctx, so that cancelling ctx or reaching its deadline aborts them.
+func (s *Server) WithContext(ctx context.Context) *Server {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c := *s
+	c.ctx = ctx
+	return &c
 }
 
 /*
@@ -39,17 +56,46 @@ type Error struct {
 	Status     string
 	Code       string // Dynamodb error code ("MalformedQueryString", ...)
 	Message    string // The human-oriented error message
+	RequestId  string // ID of the failed request
 }
 
 func (e *Error) Error() string {
 	return e.Code + ": " + e.Message
 }
 
+// errorCodes holds the error codes DynamoDB uses for throttled requests and
+// transient failures, beyond those all services share.
+// http://docs.aws.amazon.com/amazondynamodb/latest/developerguide/Programming.Errors.html
+var errorCodes = aws.ErrorCodes{
+	Throttle:  []string{"ProvisionedThroughputExceededException", "RequestLimitExceeded"},
+	Transient: []string{"InternalServerError"},
+}
+
+// Retryable reports whether the failed request may be sent again.
+func (e *Error) Retryable() bool {
+	return errorCodes.Retryable(e.StatusCode, e.Code)
+}
+
+// Throttled reports whether the request was rejected for exceeding a rate
+// limit.
+func (e *Error) Throttled() bool {
+	return errorCodes.Throttled(e.StatusCode, e.Code)
+}
+
+// The following methods implement aws.APIError.
+
+func (e *Error) Service() string      { return "dynamodb" }
+func (e *Error) HTTPStatus() int      { return e.StatusCode }
+func (e *Error) ErrorCode() string    { return e.Code }
+func (e *Error) ErrorMessage() string { return e.Message }
+func (e *Error) RequestID() string    { return e.RequestId }
+
 func buildError(r *http.Response, jsonBody []byte) error {
 
 	ddbError := Error{
 		StatusCode: r.StatusCode,
 		Status:     r.Status,
+		RequestId:  r.Header.Get("X-Amzn-Requestid"),
 	}
 	// TODO return error if Unmarshal fails?
 
@@ -72,9 +118,25 @@ func buildError(r *http.Response, jsonBody []byte) error {
 	return &ddbError
 }
 
-func (s *Server) queryServer(target string, query *Query) ([]byte, error) {
+// queryServer sends a request to DynamoDB, retrying it as dictated by the
+// Retryer in s.Config, and returns the body of the response.
+func (s *Server) queryServer(target string, query *Query) (body []byte, err error) {
+	operation := target[strings.LastIndex(target, ".")+1:]
+	req := aws.NewRequest(s.ctx, s.Config, "dynamodb", operation)
+	err = req.Retry(func() error {
+		body, err = s.queryServerOnce(req, target, query)
+		return err
+	})
+	return
+}
+
+func (s *Server) queryServerOnce(req *aws.Request, target string, query *Query) ([]byte, error) {
+	endpoint, err := s.Config.Endpoint("dynamodb", s.Region)
+	if err != nil {
+		return nil, err
+	}
 	data := strings.NewReader(query.String())
-	hreq, err := http.NewRequest("POST", s.Region.DynamoDBEndpoint+"/", data)
+	hreq, err := http.NewRequest("POST", endpoint+"/", data)
 	if err != nil {
 		return nil, err
 	}
@@ -82,16 +144,17 @@ func (s *Server) queryServer(target string, query *Query) ([]byte, error) {
 	hreq.Header.Set("Content-Type", "application/x-amz-json-1.0")
 	hreq.Header.Set("X-Amz-Date", time.Now().UTC().Format(aws.ISO8601BasicFormat))
 	hreq.Header.Set("X-Amz-Target", target)
-
-	token := s.Auth.Token()
-	if token != "" {
-		hreq.Header.Set("X-Amz-Security-Token", token)
+	if err := req.Build(hreq); err != nil {
+		return nil, err
 	}
 
 	signer := aws.NewV4Signer(s.Auth, "dynamodb", s.Region)
 	signer.Sign(hreq)
+	if err := req.Sign(); err != nil {
+		return nil, err
+	}
 
-	resp, err := http.DefaultClient.Do(hreq)
+	resp, err := req.Send()
 
 	if err != nil {
 		log.Printf("Error calling Amazon")
@@ -110,9 +173,12 @@ func (s *Server) queryServer(target string, query *Query) ([]byte, error) {
 	// "A response code of 200 indicates the operation was successful."
 	if resp.StatusCode != 200 {
 		ddbErr := buildError(resp, body)
-		return nil, ddbErr
+		return nil, req.Unmarshal(nil, ddbErr)
 	}
 
+	if err := req.Unmarshal(body, nil); err != nil {
+		return nil, err
+	}
 	return body, nil
 }
 
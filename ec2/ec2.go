@@ -11,6 +11,7 @@
 package ec2
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/xml"
@@ -31,12 +32,33 @@ const debug = false
 type EC2 struct {
 	aws.Auth
 	aws.Region
+	// Config holds the HTTP settings used to send requests. If nil,
+	// aws.DefaultConfig is used.
+	Config  *aws.Config
+	ctx     context.Context
 	private byte // Reserve the right of using private data.
 }
 
 // New creates a new EC2.
 func New(auth aws.Auth, region aws.Region) *EC2 {
-	return &EC2{auth, region, 0}
+	return NewWithConfig(auth, region, nil)
+}
+
+// NewWithConfig creates a new EC2 that sends requests using the HTTP
+// settings in config.
+func NewWithConfig(auth aws.Auth, region aws.Region, config *aws.Config) *EC2 {
+	return &EC2{Auth: auth, Region: region, Config: config}
+}
+
+// WithContext returns a shallow copy of ec2 whose requests are made with
+// ctx, so that cancelling ctx or reaching its deadline aborts them.
+func (ec2 *EC2) WithContext(ctx context.Context) *EC2 {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c := *ec2
+	c.ctx = ctx
+	return &c
 }
 
 // ----------------------------------------------------------------------------
@@ -107,6 +129,33 @@ func (err *Error) Error() string {
 	return fmt.Sprintf("%s (%s)", err.Message, err.Code)
 }
 
+// errorCodes holds the error codes EC2 uses for throttled requests and
+// transient failures, beyond those all services share.
+// http://docs.aws.amazon.com/AWSEC2/latest/APIReference/errors-overview.html
+var errorCodes = aws.ErrorCodes{
+	Throttle:  []string{"RequestLimitExceeded"},
+	Transient: []string{"Unavailable"},
+}
+
+// Retryable reports whether the failed request may be sent again.
+func (err *Error) Retryable() bool {
+	return errorCodes.Retryable(err.StatusCode, err.Code)
+}
+
+// Throttled reports whether the request was rejected for exceeding a rate
+// limit.
+func (err *Error) Throttled() bool {
+	return errorCodes.Throttled(err.StatusCode, err.Code)
+}
+
+// The following methods implement aws.APIError.
+
+func (err *Error) Service() string      { return "ec2" }
+func (err *Error) HTTPStatus() int      { return err.StatusCode }
+func (err *Error) ErrorCode() string    { return err.Code }
+func (err *Error) ErrorMessage() string { return err.Message }
+func (err *Error) RequestID() string    { return err.RequestId }
+
 // For now a single error inst is being exposed. In the future it may be useful
 // to provide access to all of them, but rather than doing it as an array/slice,
 // use a *next pointer, so that it's backward compatible and it continues to be
@@ -118,22 +167,52 @@ type xmlErrors struct {
 
 var timeNow = time.Now
 
+// query sends a request, retrying it as dictated by the Retryer in
+// ec2.Config, and decodes the response into resp.
 func (ec2 *EC2) query(params map[string]string, resp interface{}) error {
+	req := aws.NewRequest(ec2.ctx, ec2.Config, "ec2", params["Action"])
+	return req.Retry(func() error {
+		return ec2.queryOnce(req, params, resp)
+	})
+}
+
+func (ec2 *EC2) queryOnce(req *aws.Request, params map[string]string, resp interface{}) error {
 	params["Version"] = "2013-10-15"
 	params["Timestamp"] = timeNow().In(time.UTC).Format(time.RFC3339)
-	endpoint, err := url.Parse(ec2.Region.EC2Endpoint)
+	ep, err := ec2.Config.Endpoint("ec2", ec2.Region)
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.Parse(ep)
 	if err != nil {
 		return err
 	}
 	if endpoint.Path == "" {
 		endpoint.Path = "/"
 	}
-	sign(ec2.Auth, "GET", endpoint.Path, params, endpoint.Host)
+	auth, err := ec2.Auth.Current()
+	if err != nil {
+		return err
+	}
 	endpoint.RawQuery = multimap(params).Encode()
+	hreq, err := http.NewRequest("GET", endpoint.String(), nil)
+	if err != nil {
+		return err
+	}
+	if err := req.Build(hreq); err != nil {
+		return err
+	}
+	// Sign the query as left by the build handlers.
+	signed := flatten(hreq.URL.Query())
+	sign(auth, "GET", hreq.URL.Path, signed, hreq.URL.Host)
+	hreq.URL.RawQuery = multimap(signed).Encode()
+	if err := req.Sign(); err != nil {
+		return err
+	}
 	if debug {
-		log.Printf("get { %v } -> {\n", endpoint.String())
+		log.Printf("get { %v } -> {\n", hreq.URL.String())
 	}
-	r, err := http.Get(endpoint.String())
+	r, err := req.Send()
 	if err != nil {
 		return err
 	}
@@ -145,10 +224,10 @@ func (ec2 *EC2) query(params map[string]string, resp interface{}) error {
 		log.Printf("%v\n}\n", string(dump))
 	}
 	if r.StatusCode != 200 {
-		return buildError(r)
+		return req.Unmarshal(resp, buildError(r))
 	}
 	err = xml.NewDecoder(r.Body).Decode(resp)
-	return err
+	return req.Unmarshal(resp, err)
 }
 
 func multimap(p map[string]string) url.Values {
@@ -159,6 +238,14 @@ func multimap(p map[string]string) url.Values {
 	return q
 }
 
+func flatten(q url.Values) map[string]string {
+	p := make(map[string]string, len(q))
+	for k, v := range q {
+		p[k] = v[0]
+	}
+	return p
+}
+
 func buildError(r *http.Response) error {
 	errors := xmlErrors{}
 	xml.NewDecoder(r.Body).Decode(&errors)
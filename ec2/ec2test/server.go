@@ -9,6 +9,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"github.com/hailocab/goamz/ec2"
+	"github.com/hailocab/goamz/testutil/faults"
 	"io"
 	"net"
 	"net/http"
@@ -46,6 +47,7 @@ type Server struct {
 	listener net.Listener
 	mu       sync.Mutex
 	reqs     []*Action
+	faults   *faults.Injector
 
 	instances            map[string]*Instance      // id -> instance
 	reservations         map[string]*reservation   // id -> reservation
@@ -262,11 +264,22 @@ func NewServer() (*Server, error) {
 	// we use HandlerFunc rather than *Server directly so that we
 	// can avoid exporting HandlerFunc from *Server.
 	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		srv.serveHTTP(w, req)
+		srv.mu.Lock()
+		inj := srv.faults
+		srv.mu.Unlock()
+		inj.Handler(http.HandlerFunc(srv.serveHTTP), writeFault).ServeHTTP(w, req)
 	}))
 	return srv, nil
 }
 
+// SetFaults makes the server inject the faults of inj into its
+// responses. If inj is nil, no faults are injected.
+func (srv *Server) SetFaults(inj *faults.Injector) {
+	srv.mu.Lock()
+	srv.faults = inj
+	srv.mu.Unlock()
+}
+
 // Quit closes down the server.
 func (srv *Server) Quit() {
 	srv.listener.Close()
@@ -358,6 +371,15 @@ func writeError(w http.ResponseWriter, err *ec2.Error) {
 	})
 }
 
+// writeFault writes an error injected by the faults of the server.
+func writeFault(w http.ResponseWriter, status int, code, message string) {
+	writeError(w, &ec2.Error{
+		StatusCode: status,
+		Code:       code,
+		Message:    message,
+	})
+}
+
 // xmlMarshal is the same as xml.Marshal except that
 // it panics on error. The marshalling should not fail,
 // but we want to know if it does.
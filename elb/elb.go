@@ -2,6 +2,7 @@
 package elb
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"github.com/hailocab/goamz/aws"
@@ -14,10 +15,31 @@ import (
 type ELB struct {
 	aws.Auth
 	aws.Region
+	// Config holds the HTTP settings used to send requests. If nil,
+	// aws.DefaultConfig is used.
+	Config *aws.Config
+	ctx    context.Context
 }
 
 func New(auth aws.Auth, region aws.Region) *ELB {
-	return &ELB{auth, region}
+	return NewWithConfig(auth, region, nil)
+}
+
+// NewWithConfig creates a new ELB that sends requests using the HTTP
+// settings in config.
+func NewWithConfig(auth aws.Auth, region aws.Region, config *aws.Config) *ELB {
+	return &ELB{Auth: auth, Region: region, Config: config}
+}
+
+// WithContext returns a shallow copy of elb whose requests are made with
+// ctx, so that cancelling ctx or reaching its deadline aborts them.
+func (elb *ELB) WithContext(ctx context.Context) *ELB {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c := *elb
+	c.ctx = ctx
+	return &c
 }
 
 // The CreateLoadBalancer type encapsulates options for the respective request in AWS.
@@ -268,27 +290,57 @@ func (elb *ELB) ConfigureHealthCheck(lbName string, healthCheck *HealthCheck) (*
 	return resp, nil
 }
 
+// query sends a request, retrying it as dictated by the Retryer in
+// elb.Config, and decodes the response into resp.
 func (elb *ELB) query(params map[string]string, resp interface{}) error {
+	req := aws.NewRequest(elb.ctx, elb.Config, "elasticloadbalancing", params["Action"])
+	return req.Retry(func() error {
+		return elb.queryOnce(req, params, resp)
+	})
+}
+
+func (elb *ELB) queryOnce(req *aws.Request, params map[string]string, resp interface{}) error {
 	params["Version"] = "2012-06-01"
 	params["Timestamp"] = time.Now().In(time.UTC).Format(time.RFC3339)
-	endpoint, err := url.Parse(elb.Region.ELBEndpoint)
+	ep, err := elb.Config.Endpoint("elasticloadbalancing", elb.Region)
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.Parse(ep)
 	if err != nil {
 		return err
 	}
 	if endpoint.Path == "" {
 		endpoint.Path = "/"
 	}
-	sign(elb.Auth, "GET", endpoint.Path, params, endpoint.Host)
+	auth, err := elb.Auth.Current()
+	if err != nil {
+		return err
+	}
 	endpoint.RawQuery = multimap(params).Encode()
-	r, err := http.Get(endpoint.String())
+	hreq, err := http.NewRequest("GET", endpoint.String(), nil)
+	if err != nil {
+		return err
+	}
+	if err := req.Build(hreq); err != nil {
+		return err
+	}
+	// Sign the query as left by the build handlers.
+	signed := flatten(hreq.URL.Query())
+	sign(auth, "GET", hreq.URL.Path, signed, hreq.URL.Host)
+	hreq.URL.RawQuery = multimap(signed).Encode()
+	if err := req.Sign(); err != nil {
+		return err
+	}
+	r, err := req.Send()
 	if err != nil {
 		return err
 	}
 	defer r.Body.Close()
 	if r.StatusCode != 200 {
-		return buildError(r)
+		return req.Unmarshal(resp, buildError(r))
 	}
-	return xml.NewDecoder(r.Body).Decode(resp)
+	return req.Unmarshal(resp, xml.NewDecoder(r.Body).Decode(resp))
 }
 
 // Error encapsulates an error returned by ELB.
@@ -299,6 +351,8 @@ type Error struct {
 	Code string
 	// The human-oriented error message
 	Message string
+	// ID of the failed request
+	RequestId string
 }
 
 func (err *Error) Error() string {
@@ -309,8 +363,32 @@ func (err *Error) Error() string {
 	return fmt.Sprintf("%s (%s)", err.Message, err.Code)
 }
 
+// errorCodes is nil: ELB only uses the error codes for throttled requests
+// and transient failures that all services share.
+var errorCodes *aws.ErrorCodes
+
+// Retryable reports whether the failed request may be sent again.
+func (err *Error) Retryable() bool {
+	return errorCodes.Retryable(err.StatusCode, err.Code)
+}
+
+// Throttled reports whether the request was rejected for exceeding a rate
+// limit.
+func (err *Error) Throttled() bool {
+	return errorCodes.Throttled(err.StatusCode, err.Code)
+}
+
+// The following methods implement aws.APIError.
+
+func (err *Error) Service() string      { return "elasticloadbalancing" }
+func (err *Error) HTTPStatus() int      { return err.StatusCode }
+func (err *Error) ErrorCode() string    { return err.Code }
+func (err *Error) ErrorMessage() string { return err.Message }
+func (err *Error) RequestID() string    { return err.RequestId }
+
 type xmlErrors struct {
-	Errors []Error `xml:"Error"`
+	RequestId string  `xml:"RequestId"`
+	Errors    []Error `xml:"Error"`
 }
 
 func buildError(r *http.Response) error {
@@ -322,6 +400,7 @@ func buildError(r *http.Response) error {
 	if len(errors.Errors) > 0 {
 		err = errors.Errors[0]
 	}
+	err.RequestId = errors.RequestId
 	err.StatusCode = r.StatusCode
 	if err.Message == "" {
 		err.Message = r.Status
@@ -337,6 +416,14 @@ func multimap(p map[string]string) url.Values {
 	return q
 }
 
+func flatten(q url.Values) map[string]string {
+	p := make(map[string]string, len(q))
+	for k, v := range q {
+		p[k] = v[0]
+	}
+	return p
+}
+
 func makeCreateParams(createLB *CreateLoadBalancer) map[string]string {
 	params := make(map[string]string)
 	params["LoadBalancerName"] = createLB.Name
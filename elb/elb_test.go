@@ -17,7 +17,10 @@ var _ = gocheck.Suite(&S{})
 func (s *S) SetUpSuite(c *gocheck.C) {
 	s.HTTPSuite.SetUpSuite(c)
 	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
-	s.elb = elb.New(auth, aws.Region{ELBEndpoint: testServer.URL})
+	// Some tests leave requests unanswered, which the test server
+	// turns into errors that would otherwise be retried.
+	config := &aws.Config{Retryer: aws.DefaultRetryer{Attempts: 1}}
+	s.elb = elb.NewWithConfig(auth, aws.Region{ELBEndpoint: testServer.URL}, config)
 }
 
 func (s *S) TestCreateLoadBalancer(c *gocheck.C) {
@@ -124,6 +127,60 @@ func (s *S) TestDeleteLoadBalancer(c *gocheck.C) {
 	c.Assert(resp.RequestId, gocheck.Equals, "8d7223db-49d7-11e2-bba9-35ba56032fe1")
 }
 
+func (s *S) TestRetryThrottled(c *gocheck.C) {
+	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
+	config := &aws.Config{Retryer: aws.DefaultRetryer{ThrottleDelay: time.Millisecond}}
+	e := elb.NewWithConfig(auth, aws.Region{ELBEndpoint: testServer.URL}, config)
+	testServer.PrepareResponse(400, nil, Throttling)
+	testServer.PrepareResponse(200, nil, DeleteLoadBalancer)
+	resp, err := e.DeleteLoadBalancer("testlb")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(resp.RequestId, gocheck.Equals, "8d7223db-49d7-11e2-bba9-35ba56032fe1")
+
+	testServer.WaitRequest()
+	req := testServer.WaitRequest()
+	// The retried request must be signed afresh.
+	params := make(map[string]string)
+	for k, v := range req.URL.Query() {
+		params[k] = v[0]
+	}
+	signature := params["Signature"]
+	delete(params, "Signature")
+	elb.Sign(auth, "GET", "/", params, req.Host)
+	c.Assert(signature, gocheck.Equals, params["Signature"])
+}
+
+func (s *S) TestNoRetryOnClientError(c *gocheck.C) {
+	testServer.PrepareResponse(400, nil, CreateLoadBalancerBadRequest)
+	_, err := s.elb.DeleteLoadBalancer("testlb")
+	c.Assert(err, gocheck.NotNil)
+	e, ok := err.(aws.APIError)
+	c.Assert(ok, gocheck.Equals, true)
+	c.Assert(e.Service(), gocheck.Equals, "elasticloadbalancing")
+	c.Assert(e.HTTPStatus(), gocheck.Equals, 400)
+	c.Assert(e.ErrorCode(), gocheck.Equals, "ValidationError")
+	c.Assert(e.RequestID(), gocheck.Equals, "159253fc-49dc-11e2-a47d-cde463c91a3c")
+	c.Assert(e.Retryable(), gocheck.Equals, false)
+	c.Assert(e.Throttled(), gocheck.Equals, false)
+	testServer.WaitRequest()
+}
+
+func (s *S) TestEndpointResolver(c *gocheck.C) {
+	config := &aws.Config{
+		Retryer: aws.DefaultRetryer{Attempts: 1},
+		EndpointResolver: &aws.DefaultResolver{
+			Overrides: map[string]string{"elasticloadbalancing/eu-central-1": testServer.URL},
+		},
+	}
+	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
+	e := elb.NewWithConfig(auth, aws.Region{Name: "eu-central-1"}, config)
+	testServer.PrepareResponse(200, nil, DeleteLoadBalancer)
+	_, err := e.DeleteLoadBalancer("testlb")
+	c.Assert(err, gocheck.IsNil)
+	values := testServer.WaitRequest().URL.Query()
+	c.Assert(values.Get("Action"), gocheck.Equals, "DeleteLoadBalancer")
+}
+
 func (s *S) TestRegisterInstancesWithLoadBalancer(c *gocheck.C) {
 	testServer.PrepareResponse(200, nil, RegisterInstancesWithLoadBalancer)
 	resp, err := s.elb.RegisterInstancesWithLoadBalancer([]string{"i-b44db8ca", "i-461ecf38"}, "testlb")
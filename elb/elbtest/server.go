@@ -7,6 +7,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"github.com/hailocab/goamz/elb"
+	"github.com/hailocab/goamz/testutil/faults"
 	"net"
 	"net/http"
 	"net/url"
@@ -27,6 +28,7 @@ type Server struct {
 	instances      []string
 	instanceStates map[string][]*elb.InstanceState
 	instCount      int
+	faults         *faults.Injector
 }
 
 // Starts and returns a new server
@@ -42,11 +44,22 @@ func NewServer() (*Server, error) {
 		instanceStates: make(map[string][]*elb.InstanceState),
 	}
 	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		srv.serveHTTP(w, req)
+		srv.mutex.Lock()
+		inj := srv.faults
+		srv.mutex.Unlock()
+		inj.Handler(http.HandlerFunc(srv.serveHTTP), srv.writeFault).ServeHTTP(w, req)
 	}))
 	return srv, nil
 }
 
+// SetFaults makes the server inject the faults of inj into its
+// responses. If inj is nil, no faults are injected.
+func (srv *Server) SetFaults(inj *faults.Injector) {
+	srv.mutex.Lock()
+	srv.faults = inj
+	srv.mutex.Unlock()
+}
+
 // Quit closes down the server.
 func (srv *Server) Quit() {
 	srv.listener.Close()
@@ -70,6 +83,15 @@ func (srv *Server) error(w http.ResponseWriter, err *elb.Error) {
 	}
 }
 
+// writeFault writes an error injected by the faults of the server.
+func (srv *Server) writeFault(w http.ResponseWriter, status int, code, message string) {
+	srv.error(w, &elb.Error{
+		StatusCode: status,
+		Code:       code,
+		Message:    message,
+	})
+}
+
 func (srv *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	req.ParseForm()
 	srv.mutex.Lock()
@@ -22,6 +22,17 @@ var CreateLoadBalancerBadRequest = `
 </ErrorResponse>
 `
 
+var Throttling = `
+<ErrorResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
+    <Error>
+        <Type>Sender</Type>
+        <Code>Throttling</Code>
+        <Message>Rate exceeded</Message>
+    </Error>
+    <RequestId>2b9d1e4a-49dc-11e2-a47d-cde463c91a3c</RequestId>
+</ErrorResponse>
+`
+
 var DeleteLoadBalancer = `
 <DeleteLoadBalancerResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2012-06-01/">
     <DeleteLoadBalancerResult/>
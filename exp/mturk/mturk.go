@@ -15,10 +15,8 @@ package mturk
 
 import (
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"github.com/hailocab/goamz/aws"
-	"net/http"
 	//"net/http/httputil"
 	"net/url"
 	"strconv"
@@ -29,10 +27,19 @@ import (
 type MTurk struct {
 	aws.Auth
 	URL *url.URL
+	// Config holds the HTTP settings used to send requests. If nil,
+	// aws.DefaultConfig is used.
+	Config *aws.Config
 }
 
 func New(auth aws.Auth, sandbox bool) *MTurk {
-	mt := &MTurk{Auth: auth}
+	return NewWithConfig(auth, sandbox, nil)
+}
+
+// NewWithConfig creates a new MTurk that sends requests using the HTTP
+// settings in config.
+func NewWithConfig(auth aws.Auth, sandbox bool, config *aws.Config) *MTurk {
+	mt := &MTurk{Auth: auth, Config: config}
 	var err error
 	if sandbox {
 		mt.URL, err = url.Parse("https://mechanicalturk.sandbox.amazonaws.com/")
@@ -60,6 +67,31 @@ func (err *Error) Error() string {
 	return err.Message
 }
 
+// errorCodes holds the error codes MTurk uses for throttled requests and
+// transient failures, beyond those all services share.
+var errorCodes = aws.ErrorCodes{
+	Throttle: []string{"AWS.ServiceUnavailable"},
+}
+
+// Retryable reports whether the failed request may be sent again.
+func (err *Error) Retryable() bool {
+	return errorCodes.Retryable(err.StatusCode, err.Code)
+}
+
+// Throttled reports whether the request was rejected for exceeding a rate
+// limit.
+func (err *Error) Throttled() bool {
+	return errorCodes.Throttled(err.StatusCode, err.Code)
+}
+
+// The following methods implement aws.APIError.
+
+func (err *Error) Service() string      { return "mturk" }
+func (err *Error) HTTPStatus() int      { return err.StatusCode }
+func (err *Error) ErrorCode() string    { return err.Code }
+func (err *Error) ErrorMessage() string { return err.Message }
+func (err *Error) RequestID() string    { return err.RequestId }
+
 // The request stanza included in several response types, for example
 // in a "CreateHITResponse".  http://goo.gl/qGeKf
 type xmlRequest struct {
@@ -328,7 +360,11 @@ func (mt *MTurk) query(params map[string]string, operation string, resp interfac
 	service := "AWSMechanicalTurkRequester"
 	timestamp := time.Now().UTC().Format("2006-01-02T15:04:05Z")
 
-	params["AWSAccessKeyId"] = mt.Auth.AccessKey
+	auth, err := mt.Auth.Current()
+	if err != nil {
+		return err
+	}
+	params["AWSAccessKeyId"] = auth.AccessKey
 	params["Service"] = service
 	params["Timestamp"] = timestamp
 	params["Operation"] = operation
@@ -336,16 +372,19 @@ func (mt *MTurk) query(params map[string]string, operation string, resp interfac
 	// make a copy
 	url := *mt.URL
 
-	sign(mt.Auth, service, operation, timestamp, params)
+	sign(auth, service, operation, timestamp, params)
 	url.RawQuery = multimap(params).Encode()
-	r, err := http.Get(url.String())
+	r, err := mt.Config.Client().Get(url.String())
 	if err != nil {
 		return err
 	}
 	//dump, _ := httputil.DumpResponse(r, true)
 	//println("DUMP:\n", string(dump))
 	if r.StatusCode != 200 {
-		return errors.New(fmt.Sprintf("%d: unexpected status code", r.StatusCode))
+		return &Error{
+			StatusCode: r.StatusCode,
+			Message:    fmt.Sprintf("%d: unexpected status code", r.StatusCode),
+		}
 	}
 	dec := xml.NewDecoder(r.Body)
 	err = dec.Decode(resp)
@@ -37,12 +37,21 @@ const debug = false
 type SDB struct {
 	aws.Auth
 	aws.Region
+	// Config holds the HTTP settings used to send requests. If nil,
+	// aws.DefaultConfig is used.
+	Config  *aws.Config
 	private byte // Reserve the right of using private data.
 }
 
 // New creates a new SDB.
 func New(auth aws.Auth, region aws.Region) *SDB {
-	return &SDB{auth, region, 0}
+	return NewWithConfig(auth, region, nil)
+}
+
+// NewWithConfig creates a new SDB that sends requests using the HTTP
+// settings in config.
+func NewWithConfig(auth aws.Auth, region aws.Region, config *aws.Config) *SDB {
+	return &SDB{Auth: auth, Region: region, Config: config}
 }
 
 // The Domain type represents a collection of items that are described
@@ -300,6 +309,31 @@ func (err *Error) Error() string {
 	return err.Message
 }
 
+// errorCodes holds the error codes SimpleDB uses for throttled requests and
+// transient failures, beyond those all services share.
+var errorCodes = aws.ErrorCodes{
+	Transient: []string{"RequestTimeout"},
+}
+
+// Retryable reports whether the failed request may be sent again.
+func (err *Error) Retryable() bool {
+	return errorCodes.Retryable(err.StatusCode, err.Code)
+}
+
+// Throttled reports whether the request was rejected for exceeding a rate
+// limit.
+func (err *Error) Throttled() bool {
+	return errorCodes.Throttled(err.StatusCode, err.Code)
+}
+
+// The following methods implement aws.APIError.
+
+func (err *Error) Service() string      { return "sdb" }
+func (err *Error) HTTPStatus() int      { return err.StatusCode }
+func (err *Error) ErrorCode() string    { return err.Code }
+func (err *Error) ErrorMessage() string { return err.Message }
+func (err *Error) RequestID() string    { return err.RequestId }
+
 // SimpleResp represents a response to an SDB request which on success
 // will return no other information besides ResponseMetadata.
 type SimpleResp struct {
@@ -363,8 +397,12 @@ func (sdb *SDB) query(domain *Domain, item *Item, params url.Values, headers htt
 	if err != nil {
 		return err
 	}
+	auth, err := sdb.Auth.Current()
+	if err != nil {
+		return err
+	}
 	headers["Host"] = []string{u.Host}
-	sign(sdb.Auth, method, path, params, headers)
+	sign(auth, method, path, params, headers)
 
 	u.Path = path
 	if len(params) > 0 {
@@ -375,7 +413,6 @@ func (sdb *SDB) query(domain *Domain, item *Item, params url.Values, headers htt
 		Method:     method,
 		ProtoMajor: 1,
 		ProtoMinor: 1,
-		Close:      true,
 		Header:     headers,
 	}
 
@@ -384,7 +421,7 @@ func (sdb *SDB) query(domain *Domain, item *Item, params url.Values, headers htt
 		delete(headers, "Content-Length")
 	}
 
-	r, err := http.DefaultClient.Do(&req)
+	r, err := sdb.Config.Client().Do(&req)
 	if err != nil {
 		return err
 	}
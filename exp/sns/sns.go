@@ -38,6 +38,9 @@ import (
 type SNS struct {
 	aws.Auth
 	aws.Region
+	// Config holds the HTTP settings used to send requests. If nil,
+	// aws.DefaultConfig is used.
+	Config  *aws.Config
 	private byte // Reserve the right of using private data.
 }
 
@@ -47,7 +50,13 @@ type Topic struct {
 }
 
 func New(auth aws.Auth, region aws.Region) *SNS {
-	return &SNS{auth, region, 0}
+	return NewWithConfig(auth, region, nil)
+}
+
+// NewWithConfig creates a new SNS that sends requests using the HTTP
+// settings in config.
+func NewWithConfig(auth aws.Auth, region aws.Region, config *aws.Config) *SNS {
+	return &SNS{Auth: auth, Region: region, Config: config}
 }
 
 type Message struct {
@@ -389,6 +398,31 @@ func (err *Error) Error() string {
 	return err.Message
 }
 
+// errorCodes holds the error codes SNS uses for throttled requests and
+// transient failures, beyond those all services share.
+var errorCodes = aws.ErrorCodes{
+	Throttle: []string{"Throttled", "KMSThrottling"},
+}
+
+// Retryable reports whether the failed request may be sent again.
+func (err *Error) Retryable() bool {
+	return errorCodes.Retryable(err.StatusCode, err.Code)
+}
+
+// Throttled reports whether the request was rejected for exceeding a rate
+// limit.
+func (err *Error) Throttled() bool {
+	return errorCodes.Throttled(err.StatusCode, err.Code)
+}
+
+// The following methods implement aws.APIError.
+
+func (err *Error) Service() string      { return "sns" }
+func (err *Error) HTTPStatus() int      { return err.StatusCode }
+func (err *Error) ErrorCode() string    { return err.Code }
+func (err *Error) ErrorMessage() string { return err.Message }
+func (err *Error) RequestID() string    { return err.RequestId }
+
 type xmlErrors struct {
 	RequestId string
 	Errors    []Error `xml:"Errors>Error"`
@@ -401,9 +435,13 @@ func (sns *SNS) query(topic *Topic, message *Message, params map[string]string,
 		return err
 	}
 
-	sign(sns.Auth, "GET", "/", params, u.Host)
+	auth, err := sns.Auth.Current()
+	if err != nil {
+		return err
+	}
+	sign(auth, "GET", "/", params, u.Host)
 	u.RawQuery = multimap(params).Encode()
-	r, err := http.Get(u.String())
+	r, err := sns.Config.Client().Get(u.String())
 	if err != nil {
 		return err
 	}
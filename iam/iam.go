@@ -3,8 +3,10 @@
 package iam
 
 import (
+	"context"
 	"encoding/xml"
 	"github.com/hailocab/goamz/aws"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -16,59 +18,136 @@ import (
 type IAM struct {
 	aws.Auth
 	aws.Region
+	// Config holds the HTTP settings used to send requests. If nil,
+	// aws.DefaultConfig is used.
+	Config *aws.Config
+	ctx    context.Context
 }
 
 // New creates a new IAM instance.
 func New(auth aws.Auth, region aws.Region) *IAM {
-	return &IAM{auth, region}
+	return NewWithConfig(auth, region, nil)
 }
 
+// NewWithConfig creates a new IAM instance that sends requests using the
+// HTTP settings in config.
+func NewWithConfig(auth aws.Auth, region aws.Region, config *aws.Config) *IAM {
+	return &IAM{Auth: auth, Region: region, Config: config}
+}
+
+// WithContext returns a shallow copy of iam whose requests are made with
+// ctx, so that cancelling ctx or reaching its deadline aborts them.
+func (iam *IAM) WithContext(ctx context.Context) *IAM {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c := *iam
+	c.ctx = ctx
+	return &c
+}
+
+// query sends a request, retrying it as dictated by the Retryer in
+// iam.Config, and decodes the response into resp.
 func (iam *IAM) query(params map[string]string, resp interface{}) error {
+	req := aws.NewRequest(iam.ctx, iam.Config, "iam", params["Action"])
+	return req.Retry(func() error {
+		return iam.queryOnce(req, params, resp)
+	})
+}
+
+func (iam *IAM) queryOnce(req *aws.Request, params map[string]string, resp interface{}) error {
 	params["Version"] = "2010-05-08"
 	params["Timestamp"] = time.Now().In(time.UTC).Format(time.RFC3339)
-	endpoint, err := url.Parse(iam.IAMEndpoint)
+	ep, err := iam.Config.Endpoint("iam", iam.Region)
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.Parse(ep)
+	if err != nil {
+		return err
+	}
+	auth, err := iam.Auth.Current()
 	if err != nil {
 		return err
 	}
-	sign(iam.Auth, "GET", "/", params, endpoint.Host)
 	endpoint.RawQuery = multimap(params).Encode()
-	r, err := http.Get(endpoint.String())
+	hreq, err := http.NewRequest("GET", endpoint.String(), nil)
+	if err != nil {
+		return err
+	}
+	if err := req.Build(hreq); err != nil {
+		return err
+	}
+	// Sign the query as left by the build handlers.
+	signed := flatten(hreq.URL.Query())
+	sign(auth, "GET", "/", signed, hreq.URL.Host)
+	hreq.URL.RawQuery = multimap(signed).Encode()
+	if err := req.Sign(); err != nil {
+		return err
+	}
+	r, err := req.Send()
 	if err != nil {
 		return err
 	}
 	defer r.Body.Close()
 	if r.StatusCode > 200 {
-		return buildError(r)
+		return req.Unmarshal(resp, buildError(r))
 	}
-	return xml.NewDecoder(r.Body).Decode(resp)
+	return req.Unmarshal(resp, xml.NewDecoder(r.Body).Decode(resp))
 }
 
+// postQuery sends a request, retrying it as dictated by the Retryer in
+// iam.Config, and decodes the response into resp.
 func (iam *IAM) postQuery(params map[string]string, resp interface{}) error {
-	endpoint, err := url.Parse(iam.IAMEndpoint)
+	req := aws.NewRequest(iam.ctx, iam.Config, "iam", params["Action"])
+	return req.Retry(func() error {
+		return iam.postQueryOnce(req, params, resp)
+	})
+}
+
+func (iam *IAM) postQueryOnce(req *aws.Request, params map[string]string, resp interface{}) error {
+	ep, err := iam.Config.Endpoint("iam", iam.Region)
+	if err != nil {
+		return err
+	}
+	endpoint, err := url.Parse(ep)
 	if err != nil {
 		return err
 	}
 	params["Version"] = "2010-05-08"
 	params["Timestamp"] = time.Now().In(time.UTC).Format(time.RFC3339)
-	sign(iam.Auth, "POST", "/", params, endpoint.Host)
-	encoded := multimap(params).Encode()
-	body := strings.NewReader(encoded)
-	req, err := http.NewRequest("POST", endpoint.String(), body)
+	auth, err := iam.Auth.Current()
+	if err != nil {
+		return err
+	}
+	hreq, err := http.NewRequest("POST", endpoint.String(), nil)
 	if err != nil {
 		return err
 	}
-	req.Header.Set("Host", endpoint.Host)
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Content-Length", strconv.Itoa(len(encoded)))
-	r, err := http.DefaultClient.Do(req)
+	hreq.Header.Set("Host", endpoint.Host)
+	hreq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := req.Build(hreq); err != nil {
+		return err
+	}
+	// The parameters go in the body, which is only set once they are
+	// signed.
+	sign(auth, "POST", "/", params, endpoint.Host)
+	encoded := multimap(params).Encode()
+	hreq.Body = ioutil.NopCloser(strings.NewReader(encoded))
+	hreq.ContentLength = int64(len(encoded))
+	hreq.Header.Set("Content-Length", strconv.Itoa(len(encoded)))
+	if err := req.Sign(); err != nil {
+		return err
+	}
+	r, err := req.Send()
 	if err != nil {
 		return err
 	}
 	defer r.Body.Close()
 	if r.StatusCode > 200 {
-		return buildError(r)
+		return req.Unmarshal(resp, buildError(r))
 	}
-	return xml.NewDecoder(r.Body).Decode(resp)
+	return req.Unmarshal(resp, xml.NewDecoder(r.Body).Decode(resp))
 }
 
 func buildError(r *http.Response) error {
@@ -80,6 +159,7 @@ func buildError(r *http.Response) error {
 	if len(errors.Errors) > 0 {
 		err = errors.Errors[0]
 	}
+	err.RequestId = errors.RequestId
 	err.StatusCode = r.StatusCode
 	if err.Message == "" {
 		err.Message = r.Status
@@ -95,6 +175,14 @@ func multimap(p map[string]string) url.Values {
 	return q
 }
 
+func flatten(q url.Values) map[string]string {
+	p := make(map[string]string, len(q))
+	for k, v := range q {
+		p[k] = v[0]
+	}
+	return p
+}
+
 // Response to a CreateUser request.
 //
 // See http://goo.gl/JS9Gz for more details.
@@ -405,7 +493,8 @@ type SimpleResp struct {
 }
 
 type xmlErrors struct {
-	Errors []Error `xml:"Error"`
+	RequestId string  `xml:"RequestId"`
+	Errors    []Error `xml:"Error"`
 }
 
 // Error encapsulates an IAM error.
@@ -418,6 +507,9 @@ type Error struct {
 
 	// Message explaining the error.
 	Message string
+
+	// ID of the failed request.
+	RequestId string
 }
 
 func (e *Error) Error() string {
@@ -430,3 +522,28 @@ func (e *Error) Error() string {
 	}
 	return prefix + e.Message
 }
+
+// errorCodes holds the error codes IAM uses for throttled requests and
+// transient failures, beyond those all services share.
+var errorCodes = aws.ErrorCodes{
+	Transient: []string{"ServiceFailure"},
+}
+
+// Retryable reports whether the failed request may be sent again.
+func (e *Error) Retryable() bool {
+	return errorCodes.Retryable(e.StatusCode, e.Code)
+}
+
+// Throttled reports whether the request was rejected for exceeding a rate
+// limit.
+func (e *Error) Throttled() bool {
+	return errorCodes.Throttled(e.StatusCode, e.Code)
+}
+
+// The following methods implement aws.APIError.
+
+func (e *Error) Service() string      { return "iam" }
+func (e *Error) HTTPStatus() int      { return e.StatusCode }
+func (e *Error) ErrorCode() string    { return e.Code }
+func (e *Error) ErrorMessage() string { return e.Message }
+func (e *Error) RequestID() string    { return e.RequestId }
@@ -4,7 +4,9 @@ import (
 	"github.com/hailocab/goamz/aws"
 	"github.com/hailocab/goamz/iam"
 	"github.com/hailocab/goamz/iam/iamtest"
+	"github.com/hailocab/goamz/testutil/faults"
 	"launchpad.net/gocheck"
+	"time"
 )
 
 // LocalServer represents a local ec2test fake server.
@@ -37,3 +39,20 @@ func (s *LocalServerSuite) SetUpSuite(c *gocheck.C) {
 	s.srv.SetUp(c)
 	s.ClientTests.iam = iam.New(s.srv.auth, s.srv.region)
 }
+
+func (s *LocalServerSuite) TestThrottling(c *gocheck.C) {
+	inj := faults.New(faults.Rule{Match: faults.Action("GetUser"), Nth: 1, StatusCode: 400, Code: "Throttling"})
+	s.srv.srv.SetFaults(inj)
+	defer s.srv.srv.SetFaults(nil)
+
+	client := iam.NewWithConfig(s.srv.auth, s.srv.region, &aws.Config{
+		Retryer: aws.DefaultRetryer{ThrottleDelay: time.Millisecond},
+	})
+	_, err := client.CreateUser("throttled", "/")
+	c.Assert(err, gocheck.IsNil)
+	defer client.DeleteUser("throttled")
+	resp, err := client.GetUser("throttled")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(resp.User.Name, gocheck.Equals, "throttled")
+	c.Assert(inj.Injected(), gocheck.Equals, 1)
+}
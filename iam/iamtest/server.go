@@ -8,6 +8,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"github.com/hailocab/goamz/iam"
+	"github.com/hailocab/goamz/testutil/faults"
 	"net"
 	"net/http"
 	"strings"
@@ -31,6 +32,7 @@ type Server struct {
 	accessKeys   []iam.AccessKey
 	userPolicies []iam.UserPolicy
 	mutex        sync.Mutex
+	faults       *faults.Injector
 }
 
 func NewServer() (*Server, error) {
@@ -43,11 +45,22 @@ func NewServer() (*Server, error) {
 		url:      "http://" + l.Addr().String(),
 	}
 	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		srv.serveHTTP(w, req)
+		srv.mutex.Lock()
+		inj := srv.faults
+		srv.mutex.Unlock()
+		inj.Handler(http.HandlerFunc(srv.serveHTTP), srv.writeFault).ServeHTTP(w, req)
 	}))
 	return srv, nil
 }
 
+// SetFaults makes the server inject the faults of inj into its
+// responses. If inj is nil, no faults are injected.
+func (srv *Server) SetFaults(inj *faults.Injector) {
+	srv.mutex.Lock()
+	srv.faults = inj
+	srv.mutex.Unlock()
+}
+
 // Quit closes down the server.
 func (srv *Server) Quit() error {
 	return srv.listener.Close()
@@ -71,6 +84,15 @@ func (srv *Server) error(w http.ResponseWriter, err *iam.Error) {
 	}
 }
 
+// writeFault writes an error injected by the faults of the server.
+func (srv *Server) writeFault(w http.ResponseWriter, status int, code, message string) {
+	srv.error(w, &iam.Error{
+		StatusCode: status,
+		Code:       code,
+		Message:    message,
+	})
+}
+
 func (srv *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	req.ParseForm()
 	srv.mutex.Lock()
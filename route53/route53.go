@@ -2,6 +2,7 @@ package route53
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"github.com/hailocab/goamz/aws"
@@ -14,21 +15,43 @@ type Route53 struct {
 	Endpoint string
 	Signer   *aws.Route53Signer
 	Service  *aws.Service
+	Config   *aws.Config
+	ctx      context.Context
 }
 
-const route53_host = "https://route53.amazonaws.com"
-
 // Factory for the route53 type
 func NewRoute53(auth aws.Auth) (*Route53, error) {
+	return NewRoute53WithConfig(auth, nil)
+}
+
+// Factory for a route53 type sending requests using the HTTP settings in
+// config, to the endpoint config resolves for the "route53" service
+func NewRoute53WithConfig(auth aws.Auth, config *aws.Config) (*Route53, error) {
 	signer := aws.NewRoute53Signer(auth)
+	host, err := config.Endpoint("route53", aws.Region{})
+	if err != nil {
+		return nil, err
+	}
 
 	return &Route53{
 		Auth:     auth,
 		Signer:   signer,
-		Endpoint: route53_host + "/2013-04-01/hostedzone",
+		Endpoint: host + "/2013-04-01/hostedzone",
+		Config:   config,
 	}, nil
 }
 
+// WithContext returns a shallow copy of r whose requests are made with
+// ctx, so that cancelling ctx or reaching its deadline aborts them.
+func (r *Route53) WithContext(ctx context.Context) *Route53 {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c := *r
+	c.ctx = ctx
+	return &c
+}
+
 // General Structs used in all types of requests
 type HostedZone struct {
 	XMLName                xml.Name `xml:"HostedZone"`
@@ -162,34 +185,61 @@ type DeleteHostedZoneResponse struct {
 // query sends the specified HTTP request to the path and signs the request
 // with the required authentication and headers based on the Auth.
 //
-// Automatically decodes the response into the the result interface
-func (r *Route53) query(method string, path string, body io.Reader, result interface{}) error {
+// Automatically decodes the response into the the result interface. Failed
+// requests are retried as dictated by the Retryer in r.Config.
+func (r *Route53) query(method string, path string, body []byte, result interface{}) error {
+	req := aws.NewRequest(r.ctx, r.Config, "route53", method)
+	return req.Retry(func() error {
+		return r.queryOnce(req, method, path, body, result)
+	})
+}
+
+// errorCodes holds the error codes Route 53 uses for throttled requests
+// and transient failures, beyond those all services share.
+// http://docs.aws.amazon.com/Route53/latest/APIReference/API_ChangeResourceRecordSets.html
+var errorCodes = aws.ErrorCodes{
+	Throttle: []string{"PriorRequestNotComplete"},
+}
+
+func (r *Route53) queryOnce(req *aws.Request, method string, path string, body []byte, result interface{}) error {
 	var err error
 
 	// Create the POST request and sign the headers
-	req, err := http.NewRequest(method, path, body)
-	r.Signer.Sign(req)
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	hreq, err := http.NewRequest(method, path, reader)
+	if err != nil {
+		return err
+	}
+	if err := req.Build(hreq); err != nil {
+		return err
+	}
+	r.Signer.Sign(hreq)
+	if err := req.Sign(); err != nil {
+		return err
+	}
 
 	// Send the request and capture the response
-	client := &http.Client{}
-	res, err := client.Do(req)
+	res, err := req.Send()
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
 
-	if method == "POST" {
-		defer req.Body.Close()
-	}
-
 	if res.StatusCode != 201 && res.StatusCode != 200 {
 		err = r.Service.BuildError(res)
-		return err
+		if e, ok := err.(*aws.Error); ok {
+			e.ServiceName = "route53"
+			e.ErrorCodes = &errorCodes
+		}
+		return req.Unmarshal(result, err)
 	}
 
 	err = xml.NewDecoder(res.Body).Decode(result)
 
-	return err
+	return req.Unmarshal(result, err)
 }
 
 // CreateHostedZone send a creation request to the AWS Route53 API
@@ -200,7 +250,7 @@ func (r *Route53) CreateHostedZone(hostedZoneReq *CreateHostedZoneRequest) (*Cre
 	}
 
 	result := new(CreateHostedZoneResponse)
-	err = r.query("POST", r.Endpoint, bytes.NewBuffer(xmlBytes), result)
+	err = r.query("POST", r.Endpoint, xmlBytes, result)
 
 	return result, err
 }
@@ -215,7 +265,7 @@ func (r *Route53) ChangeResourceRecordSet(req *ChangeResourceRecordSetsRequest,
 
 	result := new(ChangeResourceRecordSetsResponse)
 	path := fmt.Sprintf("%s/%s/rrset", r.Endpoint, zoneId)
-	err = r.query("POST", path, bytes.NewBuffer(xmlBytes), result)
+	err = r.query("POST", path, xmlBytes, result)
 
 	return result, err
 }
@@ -0,0 +1,177 @@
+package s3
+
+import (
+	"encoding/xml"
+	"net/url"
+)
+
+// The AccessControlPolicy type holds the owner of a bucket or object and
+// the permissions granted on it.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/dev/acl-overview.html
+// for details.
+type AccessControlPolicy struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ AccessControlPolicy"`
+	Owner   Owner
+	Grants  []Grant `xml:"AccessControlList>Grant"`
+}
+
+// A Permission is granted on a bucket or object by a Grant.
+type Permission string
+
+const (
+	PermFullControl = Permission("FULL_CONTROL")
+	PermRead        = Permission("READ")
+	PermWrite       = Permission("WRITE")
+	PermReadACP     = Permission("READ_ACP")
+	PermWriteACP    = Permission("WRITE_ACP")
+)
+
+// A Grant gives Permission to Grantee.
+type Grant struct {
+	Grantee    Grantee
+	Permission Permission
+}
+
+// Values for the Type field of Grantee.
+const (
+	GranteeCanonicalUser = "CanonicalUser"
+	GranteeEmail         = "AmazonCustomerByEmail"
+	GranteeGroup         = "Group"
+)
+
+// The predefined groups permissions may be granted to.
+const (
+	AllUsersGroup           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	AuthenticatedUsersGroup = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+	LogDeliveryGroup        = "http://acs.amazonaws.com/groups/s3/LogDelivery"
+)
+
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// A Grantee is who a permission is granted to: the AWS account with the
+// canonical user ID, the one with the email address EmailAddress, or the
+// group identified by URI, depending on Type.
+type Grantee struct {
+	Type         string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	ID           string `xml:",omitempty"`
+	DisplayName  string `xml:",omitempty"`
+	EmailAddress string `xml:",omitempty"`
+	URI          string `xml:",omitempty"`
+}
+
+// CanonicalUser returns the grantee for the AWS account with the given
+// canonical user ID.
+func CanonicalUser(id string) Grantee {
+	return Grantee{Type: GranteeCanonicalUser, ID: id}
+}
+
+// EmailUser returns the grantee for the AWS account with the given email
+// address.
+func EmailUser(email string) Grantee {
+	return Grantee{Type: GranteeEmail, EmailAddress: email}
+}
+
+// Group returns the grantee for the predefined group with the given URI,
+// such as AllUsersGroup.
+func Group(uri string) Grantee {
+	return Grantee{Type: GranteeGroup, URI: uri}
+}
+
+// MarshalXML writes the type of g as an xsi:type attribute with the
+// prefix S3 documents it with, which encoding/xml cannot produce by
+// itself.
+func (g Grantee) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = []xml.Attr{
+		{Name: xml.Name{Local: "xmlns:xsi"}, Value: xsiNamespace},
+		{Name: xml.Name{Local: "xsi:type"}, Value: g.Type},
+	}
+	return e.EncodeElement(struct {
+		ID           string `xml:",omitempty"`
+		DisplayName  string `xml:",omitempty"`
+		EmailAddress string `xml:",omitempty"`
+		URI          string `xml:",omitempty"`
+	}{g.ID, g.DisplayName, g.EmailAddress, g.URI}, start)
+}
+
+// sameAs reports whether g and other refer to the same grantee.
+// DisplayName is ignored, as S3 sets it itself.
+func (g Grantee) sameAs(other Grantee) bool {
+	if g.Type != other.Type {
+		return false
+	}
+	switch g.Type {
+	case GranteeCanonicalUser:
+		return g.ID == other.ID
+	case GranteeEmail:
+		return g.EmailAddress == other.EmailAddress
+	}
+	return g.URI == other.URI
+}
+
+// AddGrant grants perm to grantee, unless p already does.
+func (p *AccessControlPolicy) AddGrant(grantee Grantee, perm Permission) {
+	for _, g := range p.Grants {
+		if g.Permission == perm && g.Grantee.sameAs(grantee) {
+			return
+		}
+	}
+	p.Grants = append(p.Grants, Grant{grantee, perm})
+}
+
+// RemoveGrant removes the grant of perm to grantee from p. If perm is
+// empty, all the permissions granted to grantee are removed.
+func (p *AccessControlPolicy) RemoveGrant(grantee Grantee, perm Permission) {
+	grants := p.Grants[:0]
+	for _, g := range p.Grants {
+		if !g.Grantee.sameAs(grantee) || perm != "" && g.Permission != perm {
+			grants = append(grants, g)
+		}
+	}
+	p.Grants = grants
+}
+
+// GetBucketACL returns the access control policy of b.
+func (b *Bucket) GetBucketACL() (*AccessControlPolicy, error) {
+	var policy AccessControlPolicy
+	err := b.getBucketConfig("acl", func() interface{} {
+		policy = AccessControlPolicy{}
+		return &policy
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// PutBucketACL replaces the access control policy of b.
+func (b *Bucket) PutBucketACL(policy *AccessControlPolicy) error {
+	return b.putBucketConfig("acl", policy)
+}
+
+// GetACL returns the access control policy of the object at path.
+func (b *Bucket) GetACL(path string) (*AccessControlPolicy, error) {
+	req := &request{
+		bucket: b.Name,
+		path:   path,
+		params: url.Values{"acl": {""}},
+	}
+	var policy AccessControlPolicy
+	err := b.S3.retry(req, func() error {
+		policy = AccessControlPolicy{}
+		return b.S3.query(req, &policy)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// PutACL replaces the access control policy of the object at path.
+func (b *Bucket) PutACL(path string, policy *AccessControlPolicy) error {
+	doc, err := xml.Marshal(policy)
+	if err != nil {
+		return err
+	}
+	return b.putSubresource(path, "acl", append([]byte(xml.Header), doc...), "application/xml")
+}
@@ -0,0 +1,305 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+)
+
+// The LifecycleConfiguration type holds the rules that make S3 expire
+// objects, or move them to other storage classes, as they age.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/dev/object-lifecycle-mgmt.html
+// for details.
+type LifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LifecycleConfiguration"`
+	Rules   []LifecycleRule `xml:"Rule"`
+}
+
+// Values for the Status field of lifecycle rules.
+const (
+	RuleEnabled  = "Enabled"
+	RuleDisabled = "Disabled"
+)
+
+// A LifecycleRule applies to the objects whose key begins with Prefix.
+type LifecycleRule struct {
+	ID     string `xml:",omitempty"`
+	Prefix string
+	Status string
+
+	Expiration  *LifecycleExpiration  `xml:",omitempty"`
+	Transitions []LifecycleTransition `xml:"Transition,omitempty"`
+
+	// These apply to the versions of objects that have been replaced or
+	// deleted, in a versioned bucket.
+	NoncurrentVersionExpiration  *NoncurrentVersionExpiration  `xml:",omitempty"`
+	NoncurrentVersionTransitions []NoncurrentVersionTransition `xml:"NoncurrentVersionTransition,omitempty"`
+}
+
+// LifecycleExpiration says when objects expire: a number of days after
+// their creation, or at a date given in ISO 8601 format, which must be a
+// midnight UTC. ExpiredObjectDeleteMarker makes S3 remove delete markers
+// left with no versions behind them.
+type LifecycleExpiration struct {
+	Days                      int    `xml:",omitempty"`
+	Date                      string `xml:",omitempty"`
+	ExpiredObjectDeleteMarker bool   `xml:",omitempty"`
+}
+
+// LifecycleTransition says when objects move to StorageClass, such as
+// "STANDARD_IA" or "GLACIER", in the same way as LifecycleExpiration.
+type LifecycleTransition struct {
+	Days         int    `xml:",omitempty"`
+	Date         string `xml:",omitempty"`
+	StorageClass string
+}
+
+// NoncurrentVersionExpiration says how many days after becoming
+// noncurrent object versions expire.
+type NoncurrentVersionExpiration struct {
+	NoncurrentDays int
+}
+
+// NoncurrentVersionTransition says how many days after becoming
+// noncurrent object versions move to StorageClass.
+type NoncurrentVersionTransition struct {
+	NoncurrentDays int
+	StorageClass   string
+}
+
+// The CORSConfiguration type holds the rules deciding which cross-origin
+// requests browsers may make to a bucket.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/dev/cors.html for details.
+type CORSConfiguration struct {
+	XMLName xml.Name   `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CORSConfiguration"`
+	Rules   []CORSRule `xml:"CORSRule"`
+}
+
+// A CORSRule allows requests from AllowedOrigins with AllowedMethods.
+type CORSRule struct {
+	ID             string   `xml:",omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  int      `xml:",omitempty"`
+}
+
+// A Tag is a key and value pair attached to a bucket or object.
+type Tag struct {
+	Key   string
+	Value string
+}
+
+type tagging struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ Tagging"`
+	Tags    []Tag    `xml:"TagSet>Tag"`
+}
+
+// Values for the versioning status of buckets. A bucket that has never
+// had versioning enabled has no status.
+const (
+	VersioningEnabled   = "Enabled"
+	VersioningSuspended = "Suspended"
+)
+
+type versioningConfiguration struct {
+	XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ VersioningConfiguration"`
+	Status  string   `xml:",omitempty"`
+}
+
+// GetBucketLifecycle returns the lifecycle configuration of b. If b has
+// none, the error returned has the code "NoSuchLifecycleConfiguration".
+func (b *Bucket) GetBucketLifecycle() (*LifecycleConfiguration, error) {
+	var config LifecycleConfiguration
+	err := b.getBucketConfig("lifecycle", func() interface{} {
+		config = LifecycleConfiguration{}
+		return &config
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// PutBucketLifecycle replaces the lifecycle configuration of b.
+func (b *Bucket) PutBucketLifecycle(config *LifecycleConfiguration) error {
+	return b.putBucketConfig("lifecycle", config)
+}
+
+// DelBucketLifecycle removes the lifecycle configuration of b.
+func (b *Bucket) DelBucketLifecycle() error {
+	return b.delBucketConfig("lifecycle")
+}
+
+// GetBucketCORS returns the CORS configuration of b. If b has none, the
+// error returned has the code "NoSuchCORSConfiguration".
+func (b *Bucket) GetBucketCORS() (*CORSConfiguration, error) {
+	var config CORSConfiguration
+	err := b.getBucketConfig("cors", func() interface{} {
+		config = CORSConfiguration{}
+		return &config
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// PutBucketCORS replaces the CORS configuration of b.
+func (b *Bucket) PutBucketCORS(config *CORSConfiguration) error {
+	return b.putBucketConfig("cors", config)
+}
+
+// DelBucketCORS removes the CORS configuration of b.
+func (b *Bucket) DelBucketCORS() error {
+	return b.delBucketConfig("cors")
+}
+
+// GetBucketPolicy returns the policy of b, a JSON document. If b has
+// none, the error returned has the code "NoSuchBucketPolicy".
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/dev/using-iam-policies.html
+// for details on bucket policies.
+func (b *Bucket) GetBucketPolicy() ([]byte, error) {
+	req := &request{
+		bucket: b.Name,
+		path:   "/",
+		params: url.Values{"policy": {""}},
+	}
+	var policy []byte
+	err := b.S3.retry(req, func() error {
+		if err := b.S3.prepare(req); err != nil {
+			return err
+		}
+		resp, err := b.S3.run(req, nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		policy, err = ioutil.ReadAll(resp.Body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// PutBucketPolicy replaces the policy of b with policy, a JSON document.
+func (b *Bucket) PutBucketPolicy(policy []byte) error {
+	return b.putSubresource("/", "policy", policy, "application/json")
+}
+
+// DelBucketPolicy removes the policy of b.
+func (b *Bucket) DelBucketPolicy() error {
+	return b.delBucketConfig("policy")
+}
+
+// GetBucketTagging returns the tags attached to b. If b has none, the
+// error returned has the code "NoSuchTagSet".
+func (b *Bucket) GetBucketTagging() ([]Tag, error) {
+	var t tagging
+	err := b.getBucketConfig("tagging", func() interface{} {
+		t = tagging{}
+		return &t
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t.Tags, nil
+}
+
+// PutBucketTagging replaces the tags attached to b.
+func (b *Bucket) PutBucketTagging(tags []Tag) error {
+	return b.putBucketConfig("tagging", &tagging{Tags: tags})
+}
+
+// DelBucketTagging removes all the tags attached to b.
+func (b *Bucket) DelBucketTagging() error {
+	return b.delBucketConfig("tagging")
+}
+
+// GetBucketVersioning returns the versioning status of b: VersioningEnabled,
+// VersioningSuspended, or "" if versioning was never enabled for b.
+func (b *Bucket) GetBucketVersioning() (string, error) {
+	var config versioningConfiguration
+	err := b.getBucketConfig("versioning", func() interface{} {
+		config = versioningConfiguration{}
+		return &config
+	})
+	if err != nil {
+		return "", err
+	}
+	return config.Status, nil
+}
+
+// PutBucketVersioning sets the versioning status of b to status, which
+// must be VersioningEnabled or VersioningSuspended.
+func (b *Bucket) PutBucketVersioning(status string) error {
+	return b.putBucketConfig("versioning", &versioningConfiguration{Status: status})
+}
+
+// getBucketConfig decodes the bucket configuration document held by the
+// subresource sub of b into the value reset returns a pointer to. reset
+// is called before each attempt, as one cut short would leave the lists
+// of the document partly decoded.
+func (b *Bucket) getBucketConfig(sub string, reset func() interface{}) error {
+	req := &request{
+		bucket: b.Name,
+		path:   "/",
+		params: url.Values{sub: {""}},
+	}
+	return b.S3.retry(req, func() error {
+		return b.S3.query(req, reset())
+	})
+}
+
+// putBucketConfig replaces the bucket configuration document held by the
+// subresource sub of b with config, encoded as XML.
+func (b *Bucket) putBucketConfig(sub string, config interface{}) error {
+	doc, err := xml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return b.putSubresource("/", sub, append([]byte(xml.Header), doc...), "application/xml")
+}
+
+// putSubresource replaces the subresource sub of the object at path, or
+// of b itself if path is "/", with data.
+func (b *Bucket) putSubresource(path, sub string, data []byte, contType string) error {
+	sum := md5.Sum(data)
+	req := &request{
+		method: "PUT",
+		bucket: b.Name,
+		path:   path,
+		params: url.Values{sub: {""}},
+		headers: map[string][]string{
+			"Content-Length": {strconv.Itoa(len(data))},
+			"Content-Type":   {contType},
+			"Content-MD5":    {base64.StdEncoding.EncodeToString(sum[:])},
+		},
+	}
+	return b.S3.retry(req, func() error {
+		req.payload = bytes.NewReader(data)
+		return b.S3.query(req, nil)
+	})
+}
+
+func (b *Bucket) delBucketConfig(sub string) error {
+	req := &request{
+		method: "DELETE",
+		bucket: b.Name,
+		path:   "/",
+		params: url.Values{sub: {""}},
+	}
+	return b.S3.retry(req, func() error {
+		return b.S3.query(req, nil)
+	})
+}
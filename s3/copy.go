@@ -0,0 +1,315 @@
+package s3
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Values for the MetadataDirective field of CopyOptions.
+const (
+	CopyMetadata    = "COPY"
+	ReplaceMetadata = "REPLACE"
+)
+
+// MaxCopySize is the size of the largest object a single copy request can
+// copy. Copier copies larger objects in parts.
+const MaxCopySize = 5 << 30
+
+// CopyOptions holds the settings of server-side copies.
+type CopyOptions struct {
+	// Options holds the settings of the copy. Its metadata, such as
+	// Meta and ContentDisposition, only applies with ReplaceMetadata.
+	Options
+
+	// MetadataDirective is CopyMetadata, the default, for the copy to
+	// keep the metadata and content type of the source object, or
+	// ReplaceMetadata for it to get ContentType and the metadata in
+	// Options instead.
+	MetadataDirective string
+	ContentType       string
+
+	// SourceVersionId selects the version of the source object copied.
+	// If empty, the current version is.
+	SourceVersionId string
+
+	// Source holds the conditions under which the source object is
+	// copied, and the key needed to read it if it was stored with
+	// Options.SSECustomerKey.
+	Source GetOptions
+}
+
+// addHeaders adds the headers requesting o to headers.
+func (o CopyOptions) addHeaders(headers map[string][]string) {
+	o.Options.addHeaders(headers)
+	if o.MetadataDirective != "" {
+		headers["x-amz-metadata-directive"] = []string{o.MetadataDirective}
+	}
+	if o.ContentType != "" {
+		headers["Content-Type"] = []string{o.ContentType}
+	}
+	o.Source.addCopySourceHeaders(headers)
+}
+
+// addCopySourceHeaders adds the headers requesting o for the source
+// object of a copy to headers.
+func (o GetOptions) addCopySourceHeaders(headers map[string][]string) {
+	h := make(map[string][]string)
+	o.addHeaders(h)
+	for name, values := range h {
+		if strings.HasPrefix(name, "x-amz-") {
+			name = "x-amz-copy-source-" + strings.TrimPrefix(name, "x-amz-")
+		} else {
+			name = "x-amz-copy-source-" + strings.ToLower(name)
+		}
+		headers[name] = values
+	}
+}
+
+// copySource returns the value of the x-amz-copy-source header naming
+// the version versionId of source, given as "bucket/key".
+func copySource(source, versionId string) string {
+	s := (&url.URL{Path: "/" + strings.TrimPrefix(source, "/")}).EscapedPath()
+	if versionId != "" {
+		s += "?versionId=" + url.QueryEscape(versionId)
+	}
+	return s
+}
+
+// Copy copies source, given as "bucket/key", to path in b on the server
+// side. Objects larger than MaxCopySize must be copied with a Copier.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectCOPY.html
+// for details.
+func (b *Bucket) Copy(path, source string, perm ACL, options CopyOptions) (*CopyObjectResult, error) {
+	headers := map[string][]string{
+		"x-amz-acl":         {string(perm)},
+		"x-amz-copy-source": {copySource(source, options.SourceVersionId)},
+	}
+	options.addHeaders(headers)
+	return b.copy(path, headers)
+}
+
+func (b *Bucket) copy(path string, headers map[string][]string) (*CopyObjectResult, error) {
+	req := &request{
+		method:  "PUT",
+		bucket:  b.Name,
+		path:    path,
+		headers: headers,
+	}
+	var result CopyObjectResult
+	err := b.S3.retry(req, func() error {
+		return b.S3.queryCopy(req, &result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// queryCopy sends a copy request, which S3 may answer with status 200
+// and an error document when the copy fails after it started.
+func (s3 *S3) queryCopy(req *request, result *CopyObjectResult) error {
+	var resp struct {
+		XMLName      xml.Name
+		ETag         string
+		LastModified string
+		Code         string
+		Message      string
+		RequestId    string
+		HostId       string
+	}
+	if err := s3.query(req, &resp); err != nil {
+		return err
+	}
+	if resp.XMLName.Local == "Error" {
+		return &Error{
+			StatusCode: http.StatusOK,
+			Code:       resp.Code,
+			Message:    resp.Message,
+			BucketName: req.bucket,
+			RequestId:  resp.RequestId,
+			HostId:     resp.HostId,
+		}
+	}
+	result.ETag, result.LastModified = resp.ETag, resp.LastModified
+	return nil
+}
+
+// PutPartCopy sets part n of the multipart upload to bytes start to end,
+// inclusive, of source, given as "bucket/key". Only the SourceVersionId
+// and Source fields of options are used.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/API/mpUploadUploadPartCopy.html
+// for details.
+func (m *Multi) PutPartCopy(n int, source string, start, end int64, options CopyOptions) (Part, error) {
+	headers := map[string][]string{
+		"x-amz-copy-source":       {copySource(source, options.SourceVersionId)},
+		"x-amz-copy-source-range": {fmt.Sprintf("bytes=%d-%d", start, end)},
+	}
+	options.Source.addCopySourceHeaders(headers)
+	addSSECustomerHeaders(headers, m.SSECustomerKey)
+	req := &request{
+		method:  "PUT",
+		bucket:  m.Bucket.Name,
+		path:    m.Key,
+		headers: headers,
+		params: map[string][]string{
+			"uploadId":   {m.UploadId},
+			"partNumber": {strconv.Itoa(n)},
+		},
+	}
+	var result CopyObjectResult
+	err := m.Bucket.S3.retry(req, func() error {
+		return m.Bucket.S3.queryCopy(req, &result)
+	})
+	if err != nil {
+		return Part{}, err
+	}
+	return Part{n, result.ETag, end - start + 1}, nil
+}
+
+// Part sizes and concurrency used by Copier.
+const (
+	DefaultCopyPartSize    = 64 << 20
+	DefaultCopyConcurrency = 5
+)
+
+// A Copier copies objects of any size on the server side, using
+// concurrent UploadPartCopy requests for those larger than its part size.
+// It can also rename large objects, without their content passing through
+// the client.
+//
+// A Copier may be used for any number of copies at the same time.
+type Copier struct {
+	Bucket *Bucket
+
+	// PartSize is the size of the parts objects are copied in. It must
+	// be at least MinUploadPartSize, and is raised as needed for objects
+	// to fit in MaxUploadParts parts. If zero, DefaultCopyPartSize is
+	// used.
+	PartSize int64
+
+	// Concurrency is the number of parts copied at the same time. If
+	// zero, DefaultCopyConcurrency is used.
+	Concurrency int
+}
+
+// NewCopier returns a Copier for b with the default part size and
+// concurrency.
+func NewCopier(b *Bucket) *Copier {
+	return &Copier{Bucket: b}
+}
+
+// splitSource returns the bucket and key of source, given as "bucket/key".
+func (c *Copier) splitSource(source string) (*Bucket, string, error) {
+	src := strings.TrimPrefix(source, "/")
+	i := strings.Index(src, "/")
+	if i < 0 {
+		return nil, "", fmt.Errorf("copy source %q is not of the form bucket/key", source)
+	}
+	return c.Bucket.S3.Bucket(src[:i]), src[i+1:], nil
+}
+
+// CopyLarge copies source, given as "bucket/key", to path in b with a
+// Copier using the default part size and concurrency.
+func (b *Bucket) CopyLarge(path, source string, perm ACL, options CopyOptions) error {
+	return NewCopier(b).Copy(path, source, perm, options)
+}
+
+// Copy copies source, given as "bucket/key", to path in the copier's
+// bucket, as Bucket.Copy does. Objects larger than the part size are
+// copied in parts, each made on condition that the source still has the
+// ETag it had when the copy started. If a multipart copy fails, it is
+// aborted and the error returned is an *UploadError.
+func (c *Copier) Copy(path, source string, perm ACL, options CopyOptions) error {
+	partSize, concurrency := c.PartSize, c.Concurrency
+	if partSize == 0 {
+		partSize = DefaultCopyPartSize
+	}
+	if partSize < minUploadPartSize {
+		return fmt.Errorf("copy part size %d is below the minimum of %d bytes", partSize, minUploadPartSize)
+	}
+	if partSize > MaxCopySize {
+		return fmt.Errorf("copy part size %d is above the maximum of %d bytes", partSize, MaxCopySize)
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultCopyConcurrency
+	}
+
+	srcBucket, srcKey, err := c.splitSource(source)
+	if err != nil {
+		return err
+	}
+	info, err := srcBucket.stat(srcKey, options.SourceVersionId, options.Source)
+	if err != nil {
+		return err
+	}
+	if info.Size <= partSize {
+		_, err := c.Bucket.Copy(path, source, perm, options)
+		return err
+	}
+	if min := (info.Size + MaxUploadParts - 1) / MaxUploadParts; partSize < min {
+		partSize = min
+	}
+
+	// Parts are copied without their metadata, so the upload is given
+	// that of the source unless it is being replaced.
+	contType, opts := options.ContentType, options.Options
+	if options.MetadataDirective != ReplaceMetadata {
+		contType = info.ContentType
+		opts.Meta = info.Meta
+		opts.ContentEncoding = info.ContentEncoding
+		opts.ContentDisposition = info.ContentDisposition
+		opts.CacheControl = info.CacheControl
+		opts.Expires = info.Expires
+	}
+	m, err := c.Bucket.initMulti(path, contType, perm, opts)
+	if err != nil {
+		return err
+	}
+	partOptions := options
+	partOptions.Source.IfMatch = info.ETag
+
+	run := runParts(concurrency)
+	for n, start := 1, int64(0); start < info.Size; n, start = n+1, start+partSize {
+		n, start, end := n, start, start+partSize-1
+		if end >= info.Size {
+			end = info.Size - 1
+		}
+		put := func() (Part, error) { return m.PutPartCopy(n, source, start, end, partOptions) }
+		if !run.add(n, put) {
+			break
+		}
+	}
+	parts, failed := run.wait()
+
+	if len(failed) > 0 {
+		return abortUpload(m, nil, failed)
+	}
+	if err := m.Complete(parts); err != nil {
+		return abortUpload(m, err, nil)
+	}
+	return nil
+}
+
+// Rename moves source, given as "bucket/key", to path in the copier's
+// bucket: it copies it as Copy does and, only once the copy is complete,
+// deletes the source. Renaming a version other than the current one,
+// with options.SourceVersionId, is not supported.
+func (c *Copier) Rename(path, source string, perm ACL, options CopyOptions) error {
+	if options.SourceVersionId != "" {
+		return fmt.Errorf("cannot rename version %s of %q", options.SourceVersionId, source)
+	}
+	srcBucket, srcKey, err := c.splitSource(source)
+	if err != nil {
+		return err
+	}
+	if err := c.Copy(path, source, perm, options); err != nil {
+		return err
+	}
+	return srcBucket.Del(srcKey)
+}
@@ -0,0 +1,170 @@
+package s3_test
+
+import (
+	"github.com/hailocab/goamz/s3"
+	"launchpad.net/gocheck"
+	"strconv"
+	"time"
+)
+
+func (s *S) TestCopy(c *gocheck.C) {
+	testServer.Response(200, nil, CopyObjectResultDump)
+
+	options := s3.CopyOptions{
+		Options:           s3.Options{Meta: map[string][]string{"name": {"value"}}},
+		MetadataDirective: s3.ReplaceMetadata,
+		ContentType:       "text/plain",
+		SourceVersionId:   "3/L4kqtJlcpXroDTDmjVBH40Nrjfkd",
+		Source: s3.GetOptions{
+			IfMatch:           `"etag"`,
+			IfUnmodifiedSince: time.Date(2009, 10, 12, 17, 50, 0, 0, time.UTC),
+		},
+	}
+	result, err := s.s3.Bucket("bucket").Copy("new name", "source/old name", s3.Private, options)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(result, gocheck.DeepEquals, &s3.CopyObjectResult{
+		ETag:         `"9b2cf535f27731c974343645a3985328"`,
+		LastModified: "2009-10-28T22:32:00",
+	})
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, gocheck.Equals, "PUT")
+	c.Assert(req.URL.Path, gocheck.Equals, "/bucket/new name")
+	c.Assert(req.Header["X-Amz-Copy-Source"], gocheck.DeepEquals, []string{"/source/old%20name?versionId=3%2FL4kqtJlcpXroDTDmjVBH40Nrjfkd"})
+	c.Assert(req.Header["X-Amz-Metadata-Directive"], gocheck.DeepEquals, []string{"REPLACE"})
+	c.Assert(req.Header["Content-Type"], gocheck.DeepEquals, []string{"text/plain"})
+	c.Assert(req.Header["X-Amz-Meta-Name"], gocheck.DeepEquals, []string{"value"})
+	c.Assert(req.Header["X-Amz-Copy-Source-If-Match"], gocheck.DeepEquals, []string{`"etag"`})
+	c.Assert(req.Header["X-Amz-Copy-Source-If-Unmodified-Since"], gocheck.DeepEquals, []string{"Mon, 12 Oct 2009 17:50:00 GMT"})
+}
+
+func (s *S) TestCopyErrorAfterStart(c *gocheck.C) {
+	s.DisableRetries()
+	testServer.Response(200, nil, CopyInternalErrorDump)
+
+	_, err := s.s3.Bucket("bucket").Copy("new", "source/old", s3.Private, s3.CopyOptions{})
+	c.Assert(err, gocheck.FitsTypeOf, &s3.Error{})
+	c.Assert(err.(*s3.Error).Code, gocheck.Equals, "InternalError")
+	c.Assert(err.(*s3.Error).StatusCode, gocheck.Equals, 200)
+	c.Assert(err.(*s3.Error).RequestId, gocheck.Equals, "656c76696e6727732072657175657374")
+}
+
+func (s *S) TestCopierSinglePart(c *gocheck.C) {
+	testServer.Response(200, map[string]string{"Content-Length": "3"}, "")
+	testServer.Response(200, nil, CopyObjectResultDump)
+
+	err := s.s3.Bucket("bucket").CopyLarge("new", "source/old", s3.Private, s3.CopyOptions{})
+	c.Assert(err, gocheck.IsNil)
+
+	reqs := testServer.WaitRequests(2)
+	c.Assert(reqs[0].Method, gocheck.Equals, "HEAD")
+	c.Assert(reqs[0].URL.Path, gocheck.Equals, "/source/old")
+	c.Assert(reqs[1].Method, gocheck.Equals, "PUT")
+	c.Assert(reqs[1].Header["X-Amz-Copy-Source"], gocheck.DeepEquals, []string{"/source/old"})
+	c.Assert(reqs[1].Form["uploadId"], gocheck.IsNil)
+}
+
+func (s *S) TestCopierMultipart(c *gocheck.C) {
+	s3.SetMinUploadPartSize(5)
+	defer s3.SetMinUploadPartSize(0)
+
+	testServer.Response(200, map[string]string{
+		"Content-Length":  "12",
+		"Content-Type":    "text/plain",
+		"ETag":            `"source-etag"`,
+		"x-amz-meta-name": "value",
+	}, "")
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Responses(3, 200, nil, CopyPartResultDump)
+	testServer.Response(200, nil, "")
+
+	copier := &s3.Copier{Bucket: s.s3.Bucket("bucket"), PartSize: 5, Concurrency: 1}
+	options := s3.CopyOptions{SourceVersionId: "v1", Options: s3.Options{StorageClass: s3.StandardIAStorage}}
+	err := copier.Copy("new", "source/old", s3.Private, options)
+	c.Assert(err, gocheck.IsNil)
+
+	reqs := testServer.WaitRequests(6)
+	c.Assert(reqs[0].Method, gocheck.Equals, "HEAD")
+	c.Assert(reqs[0].Form["versionId"], gocheck.DeepEquals, []string{"v1"})
+
+	// The upload gets the metadata of the source.
+	c.Assert(reqs[1].Form["uploads"], gocheck.DeepEquals, []string{""})
+	c.Assert(reqs[1].Header["Content-Type"], gocheck.DeepEquals, []string{"text/plain"})
+	c.Assert(reqs[1].Header["X-Amz-Meta-Name"], gocheck.DeepEquals, []string{"value"})
+	c.Assert(reqs[1].Header["X-Amz-Storage-Class"], gocheck.DeepEquals, []string{"STANDARD_IA"})
+
+	ranges := []string{"bytes=0-4", "bytes=5-9", "bytes=10-11"}
+	for i, req := range reqs[2:5] {
+		c.Assert(req.Method, gocheck.Equals, "PUT")
+		c.Assert(req.URL.Path, gocheck.Equals, "/bucket/new")
+		c.Assert(req.Form.Get("partNumber"), gocheck.Equals, strconv.Itoa(i+1))
+		c.Assert(req.Header["X-Amz-Copy-Source"], gocheck.DeepEquals, []string{"/source/old?versionId=v1"})
+		c.Assert(req.Header["X-Amz-Copy-Source-Range"], gocheck.DeepEquals, []string{ranges[i]})
+		c.Assert(req.Header["X-Amz-Copy-Source-If-Match"], gocheck.DeepEquals, []string{`"source-etag"`})
+	}
+
+	c.Assert(reqs[5].Method, gocheck.Equals, "POST")
+	c.Assert(reqs[5].Form.Get("uploadId"), gocheck.Matches, "JNbR_.*")
+	c.Assert(readAll(reqs[5].Body), gocheck.Matches, `.*<PartNumber>3</PartNumber><ETag>&#34;9b2cf535f27731c974343645a3985328&#34;</ETag>.*`)
+}
+
+func (s *S) TestCopierPartFailure(c *gocheck.C) {
+	s.DisableRetries()
+	s3.SetMinUploadPartSize(5)
+	defer s3.SetMinUploadPartSize(0)
+
+	testServer.Response(200, map[string]string{"Content-Length": "10", "ETag": `"source-etag"`}, "")
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(200, nil, CopyPartResultDump)
+	testServer.Response(412, nil, PreconditionFailedDump)
+	testServer.Response(204, nil, "")
+
+	copier := &s3.Copier{Bucket: s.s3.Bucket("bucket"), PartSize: 5, Concurrency: 1}
+	err := copier.Copy("new", "source/old", s3.Private, s3.CopyOptions{})
+	c.Assert(err, gocheck.FitsTypeOf, &s3.UploadError{})
+	uerr := err.(*s3.UploadError)
+	c.Assert(uerr.Parts, gocheck.HasLen, 1)
+	c.Assert(uerr.Parts[0].N, gocheck.Equals, 2)
+	c.Assert(uerr.AbortErr, gocheck.IsNil)
+
+	reqs := testServer.WaitRequests(5)
+	c.Assert(reqs[4].Method, gocheck.Equals, "DELETE")
+}
+
+func (s *S) TestCopierPartSizeTooLarge(c *gocheck.C) {
+	copier := &s3.Copier{Bucket: s.s3.Bucket("bucket"), PartSize: s3.MaxCopySize + 1}
+	err := copier.Copy("new", "source/old", s3.Private, s3.CopyOptions{})
+	c.Assert(err, gocheck.ErrorMatches, "copy part size .* is above the maximum of .* bytes")
+}
+
+func (s *S) TestCopierRename(c *gocheck.C) {
+	testServer.Response(200, map[string]string{"Content-Length": "3"}, "")
+	testServer.Response(200, nil, CopyObjectResultDump)
+	testServer.Response(204, nil, "")
+
+	err := s3.NewCopier(s.s3.Bucket("bucket")).Rename("new", "source/old", s3.Private, s3.CopyOptions{})
+	c.Assert(err, gocheck.IsNil)
+
+	reqs := testServer.WaitRequests(3)
+	c.Assert(reqs[1].Method, gocheck.Equals, "PUT")
+	c.Assert(reqs[1].URL.Path, gocheck.Equals, "/bucket/new")
+	c.Assert(reqs[2].Method, gocheck.Equals, "DELETE")
+	c.Assert(reqs[2].URL.Path, gocheck.Equals, "/source/old")
+}
+
+func (s *S) TestCopierRenameCopyFailure(c *gocheck.C) {
+	s.DisableRetries()
+	testServer.Response(200, map[string]string{"Content-Length": "3"}, "")
+	testServer.Response(200, nil, CopyInternalErrorDump)
+
+	err := s3.NewCopier(s.s3.Bucket("bucket")).Rename("new", "source/old", s3.Private, s3.CopyOptions{})
+	c.Assert(err, gocheck.FitsTypeOf, &s3.Error{})
+
+	// The source is not deleted.
+	testServer.WaitRequests(2)
+	testServer.Response(204, nil, "")
+	err = s.s3.Bucket("bucket").Del("other")
+	c.Assert(err, gocheck.IsNil)
+	req := testServer.WaitRequest()
+	c.Assert(req.URL.Path, gocheck.Equals, "/bucket/other")
+}
@@ -0,0 +1,128 @@
+package s3
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Part sizes and concurrency used by Downloader.
+const (
+	DefaultDownloadPartSize    = 5 << 20
+	DefaultDownloadConcurrency = 5
+)
+
+// ErrObjectChanged is returned by Downloader.Download when the object
+// being downloaded is replaced before all of it has been read.
+var ErrObjectChanged = errors.New("object changed during download")
+
+// A Downloader fetches objects by issuing concurrent ranged GET requests,
+// each of which is retried on its own when it fails, including when the
+// connection drops while the range is being read.
+//
+// A Downloader may be used for any number of downloads at the same time.
+type Downloader struct {
+	Bucket *Bucket
+
+	// PartSize is the size of the ranges requested. If zero,
+	// DefaultDownloadPartSize is used.
+	PartSize int64
+
+	// Concurrency is the number of ranges downloaded at the same time.
+	// If zero, DefaultDownloadConcurrency is used.
+	Concurrency int
+}
+
+// NewDownloader returns a Downloader for b with the default part size and
+// concurrency.
+func NewDownloader(b *Bucket) *Downloader {
+	return &Downloader{Bucket: b}
+}
+
+// Download writes the object at path in the downloader's bucket to w and
+// returns its size. Ranges are written as they arrive, so in no
+// particular order. Every range is requested for the ETag the object had
+// when the download started; if it changes, ErrObjectChanged is returned.
+func (d *Downloader) Download(w io.WriterAt, path string) (int64, error) {
+	partSize, concurrency := d.PartSize, d.Concurrency
+	if partSize <= 0 {
+		partSize = DefaultDownloadPartSize
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultDownloadConcurrency
+	}
+
+	resp, err := d.Bucket.Head(path, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	size, etag := resp.ContentLength, resp.Header.Get("ETag")
+	if size < 0 {
+		return 0, errors.New("cannot download object of unknown size")
+	}
+
+	run := runParts(concurrency)
+	for n, offset := 1, int64(0); offset < size; n, offset = n+1, offset+partSize {
+		n, offset, length := n, offset, partSize
+		if offset+length > size {
+			length = size - offset
+		}
+		get := func() (Part, error) {
+			return Part{N: n, Size: length}, d.downloadRange(w, path, etag, offset, length)
+		}
+		if !run.add(n, get) {
+			break
+		}
+	}
+	// The first part to have failed is reported.
+	if _, failed := run.wait(); len(failed) > 0 {
+		return 0, failed[0].Err
+	}
+	return size, nil
+}
+
+// downloadRange copies length bytes of the object at path, starting at
+// offset, to the same offset in w.
+func (d *Downloader) downloadRange(w io.WriterAt, path, etag string, offset, length int64) error {
+	headers := map[string][]string{
+		"Range": {fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)},
+	}
+	if etag != "" {
+		headers["If-Match"] = []string{etag}
+	}
+	req := &request{
+		bucket:  d.Bucket.Name,
+		path:    path,
+		headers: headers,
+	}
+	buf := make([]byte, length)
+	err := d.Bucket.S3.retry(req, func() error {
+		if err := d.Bucket.S3.prepare(req); err != nil {
+			return err
+		}
+		resp, err := d.Bucket.S3.run(req, nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if got := resp.Header.Get("ETag"); etag != "" && got != "" && got != etag {
+			return ErrObjectChanged
+		}
+		if resp.StatusCode != 206 && (offset != 0 || resp.ContentLength != length) {
+			return fmt.Errorf("ranged GET of %q returned status %d", path, resp.StatusCode)
+		}
+		// A dropped connection shows up as io.ErrUnexpectedEOF, which
+		// gets the range retried.
+		_, err = io.ReadFull(resp.Body, buf)
+		return err
+	})
+	if err == ErrObjectChanged || hasCode(err, "PreconditionFailed") {
+		return ErrObjectChanged
+	}
+	if err != nil {
+		return fmt.Errorf("downloading bytes %d-%d of %q: %v", offset, offset+length-1, path, err)
+	}
+	_, err = w.WriteAt(buf, offset)
+	return err
+}
@@ -0,0 +1,87 @@
+package s3_test
+
+import (
+	"github.com/hailocab/goamz/s3"
+	"launchpad.net/gocheck"
+	"sync"
+)
+
+// bufferAt is an io.WriterAt that grows as needed.
+type bufferAt struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *bufferAt) WriteAt(p []byte, off int64) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if end := int(off) + len(p); end > len(b.buf) {
+		b.buf = append(b.buf, make([]byte, end-len(b.buf))...)
+	}
+	return copy(b.buf[off:], p), nil
+}
+
+var PreconditionFailedDump = `
+<?xml version="1.0" encoding="UTF-8"?>
+<Error>
+  <Code>PreconditionFailed</Code>
+  <Message>At least one of the preconditions you specified did not hold.</Message>
+  <Condition>If-Match</Condition>
+  <RequestId>3F1B667FAD71C3D8</RequestId>
+</Error>
+`
+
+func (s *S) TestDownload(c *gocheck.C) {
+	etag := map[string]string{"ETag": `"etag"`}
+	testServer.Response(200, map[string]string{"ETag": `"etag"`, "Content-Length": "13"}, "")
+	testServer.Response(206, etag, "part1")
+	testServer.Response(206, etag, "part2")
+	testServer.Response(206, etag, "par")
+
+	d := &s3.Downloader{Bucket: s.s3.Bucket("sample"), PartSize: 5, Concurrency: 1}
+	var w bufferAt
+	n, err := d.Download(&w, "multi")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, int64(13))
+	c.Assert(string(w.buf), gocheck.Equals, "part1part2par")
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, gocheck.Equals, "HEAD")
+	for _, r := range []string{"bytes=0-4", "bytes=5-9", "bytes=10-12"} {
+		req = testServer.WaitRequest()
+		c.Assert(req.Method, gocheck.Equals, "GET")
+		c.Assert(req.URL.Path, gocheck.Equals, "/sample/multi")
+		c.Assert(req.Header.Get("Range"), gocheck.Equals, r)
+		c.Assert(req.Header.Get("If-Match"), gocheck.Equals, `"etag"`)
+	}
+}
+
+func (s *S) TestDownloadRetriesTruncatedRange(c *gocheck.C) {
+	etag := map[string]string{"ETag": `"etag"`, "Content-Length": "5"}
+	testServer.Response(200, etag, "")
+	testServer.Response(206, etag, "pa")
+	testServer.Response(206, etag, "part1")
+
+	d := &s3.Downloader{Bucket: s.s3.Bucket("sample"), PartSize: 5}
+	var w bufferAt
+	n, err := d.Download(&w, "name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, int64(5))
+	c.Assert(string(w.buf), gocheck.Equals, "part1")
+
+	reqs := testServer.WaitRequests(3)
+	c.Assert(reqs[1].Header.Get("Range"), gocheck.Equals, "bytes=0-4")
+	c.Assert(reqs[2].Header.Get("Range"), gocheck.Equals, "bytes=0-4")
+}
+
+func (s *S) TestDownloadObjectChanged(c *gocheck.C) {
+	s.DisableRetries()
+	testServer.Response(200, map[string]string{"ETag": `"etag"`, "Content-Length": "10"}, "")
+	testServer.Response(206, map[string]string{"ETag": `"etag"`}, "part1")
+	testServer.Response(412, nil, PreconditionFailedDump)
+
+	d := &s3.Downloader{Bucket: s.s3.Bucket("sample"), PartSize: 5, Concurrency: 1}
+	_, err := d.Download(&bufferAt{}, "name")
+	c.Assert(err, gocheck.Equals, s3.ErrObjectChanged)
+	testServer.WaitRequests(3)
+}
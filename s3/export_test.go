@@ -4,13 +4,13 @@ import (
 	"github.com/hailocab/goamz/aws"
 )
 
-var originalStrategy = attempts
+var originalRetryer = retryer
 
-func SetAttemptStrategy(s *aws.AttemptStrategy) {
-	if s == nil {
-		attempts = originalStrategy
+func SetRetryer(r aws.Retryer) {
+	if r == nil {
+		retryer = originalRetryer
 	} else {
-		attempts = *s
+		retryer = r
 	}
 }
 
@@ -25,3 +25,15 @@ func SetListPartsMax(n int) {
 func SetListMultiMax(n int) {
 	listMultiMax = n
 }
+
+func SetMinUploadPartSize(n int64) {
+	if n == 0 {
+		minUploadPartSize = MinUploadPartSize
+	} else {
+		minUploadPartSize = n
+	}
+}
+
+func SetDelMultiMax(n int) {
+	delMultiMax = n
+}
@@ -0,0 +1,243 @@
+package s3
+
+// A ListIterator walks all the keys and common prefixes in a bucket that
+// match a prefix and delimiter, making as many List (or ListV2) requests
+// as needed. Keys and common prefixes are returned in alphabetical order:
+//
+//	it := s3.NewListIterator(b, "photos/", "/")
+//	for it.Next() {
+//		if prefix := it.CommonPrefix(); prefix != "" {
+//			// A "directory" within photos/.
+//		} else {
+//			key := it.Key()
+//			...
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type ListIterator struct {
+	// PageSize is the number of keys and common prefixes requested at a
+	// time. If zero, the S3 default of 1000 is used.
+	PageSize int
+
+	// V2 selects the ListObjectsV2 API, which pages through results with
+	// continuation tokens rather than markers.
+	V2 bool
+
+	// StartAfter, if set before the first call to Next, makes the listing
+	// start after that key.
+	StartAfter string
+
+	bucket   *Bucket
+	prefix   string
+	delim    string
+	keys     []Key
+	prefixes []string
+	key      *Key
+	common   string
+	marker   string // the marker, or continuation token, of the next page
+	started  bool
+	done     bool
+	err      error
+}
+
+// NewListIterator returns an iterator over the keys and common prefixes
+// in b that match prefix and delim, which work as in List.
+func NewListIterator(b *Bucket, prefix, delim string) *ListIterator {
+	return &ListIterator{bucket: b, prefix: prefix, delim: delim}
+}
+
+// Next advances the iterator to the next key or common prefix, and
+// reports whether there is one. It returns false when the listing is
+// exhausted or a request failed, which Err tells apart.
+func (it *ListIterator) Next() bool {
+	it.key, it.common = nil, ""
+	for len(it.keys) == 0 && len(it.prefixes) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		it.fetch()
+	}
+	if len(it.prefixes) == 0 || len(it.keys) > 0 && it.keys[0].Key < it.prefixes[0] {
+		it.key = &it.keys[0]
+		it.keys = it.keys[1:]
+	} else {
+		it.common = it.prefixes[0]
+		it.prefixes = it.prefixes[1:]
+	}
+	return true
+}
+
+func (it *ListIterator) fetch() {
+	if !it.started {
+		it.started = true
+		if !it.V2 {
+			it.marker = it.StartAfter
+		}
+	}
+	if it.V2 {
+		startAfter := ""
+		if it.marker == "" {
+			startAfter = it.StartAfter
+		}
+		resp, err := it.bucket.ListV2(it.prefix, it.delim, it.marker, startAfter, it.PageSize)
+		if err != nil {
+			it.err = err
+			return
+		}
+		it.keys, it.prefixes = resp.Contents, resp.CommonPrefixes
+		it.marker = resp.NextContinuationToken
+		it.done = !resp.IsTruncated || it.marker == ""
+		return
+	}
+	resp, err := it.bucket.List(it.prefix, it.delim, it.marker, it.PageSize)
+	if err != nil {
+		it.err = err
+		return
+	}
+	it.keys, it.prefixes = resp.Contents, resp.CommonPrefixes
+	it.done = !resp.IsTruncated
+	if !it.done {
+		it.marker = resp.NextMarker
+		if it.marker == "" {
+			it.marker = lastListed(resp.Contents, resp.CommonPrefixes)
+		}
+		// Don't ask for the same page again and again.
+		it.done = it.marker == ""
+	}
+}
+
+// lastListed returns the name of the last key or common prefix listed.
+func lastListed(keys []Key, prefixes []string) string {
+	last := ""
+	if len(keys) > 0 {
+		last = keys[len(keys)-1].Key
+	}
+	if len(prefixes) > 0 && prefixes[len(prefixes)-1] > last {
+		last = prefixes[len(prefixes)-1]
+	}
+	return last
+}
+
+// Key returns the key the iterator is at, or nil if it is at a common
+// prefix.
+func (it *ListIterator) Key() *Key {
+	return it.key
+}
+
+// CommonPrefix returns the common prefix the iterator is at, or "" if it
+// is at a key.
+func (it *ListIterator) CommonPrefix() string {
+	return it.common
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *ListIterator) Err() error {
+	return it.err
+}
+
+// A VersionIterator walks all the object versions, delete markers and
+// common prefixes in a bucket that match a prefix and delimiter, making
+// as many Versions requests as needed. It is used like a ListIterator.
+// The versions and delete markers of a key are returned from the newest.
+type VersionIterator struct {
+	// PageSize is the number of versions and common prefixes requested
+	// at a time. If zero, the S3 default of 1000 is used.
+	PageSize int
+
+	bucket          *Bucket
+	prefix          string
+	delim           string
+	versions        []Version
+	markers         []DeleteMarker
+	prefixes        []string
+	version         *Version
+	marker          *DeleteMarker
+	common          string
+	keyMarker       string
+	versionIdMarker string
+	done            bool
+	err             error
+}
+
+// NewVersionIterator returns an iterator over the object versions, delete
+// markers and common prefixes in b that match prefix and delim, which
+// work as in Versions.
+func NewVersionIterator(b *Bucket, prefix, delim string) *VersionIterator {
+	return &VersionIterator{bucket: b, prefix: prefix, delim: delim}
+}
+
+// Next advances the iterator to the next version, delete marker or common
+// prefix, and reports whether there is one. It returns false when the
+// listing is exhausted or a request failed, which Err tells apart.
+func (it *VersionIterator) Next() bool {
+	it.version, it.marker, it.common = nil, nil, ""
+	for len(it.versions) == 0 && len(it.markers) == 0 && len(it.prefixes) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		it.fetch()
+	}
+	// Responses hold versions and delete markers apart, so they are
+	// merged back in the order S3 lists them.
+	switch {
+	case len(it.markers) > 0 && (len(it.versions) == 0 || markerFirst(&it.markers[0], &it.versions[0])) &&
+		(len(it.prefixes) == 0 || it.markers[0].Key < it.prefixes[0]):
+		it.marker = &it.markers[0]
+		it.markers = it.markers[1:]
+	case len(it.versions) > 0 && (len(it.prefixes) == 0 || it.versions[0].Key < it.prefixes[0]):
+		it.version = &it.versions[0]
+		it.versions = it.versions[1:]
+	default:
+		it.common = it.prefixes[0]
+		it.prefixes = it.prefixes[1:]
+	}
+	return true
+}
+
+// markerFirst reports whether the delete marker m is listed before the
+// version v: by key, and then from the newest.
+func markerFirst(m *DeleteMarker, v *Version) bool {
+	switch {
+	case m.Key != v.Key:
+		return m.Key < v.Key
+	case m.IsLatest != v.IsLatest:
+		return m.IsLatest
+	}
+	return m.LastModified >= v.LastModified
+}
+
+func (it *VersionIterator) fetch() {
+	resp, err := it.bucket.Versions(it.prefix, it.delim, it.keyMarker, it.versionIdMarker, it.PageSize)
+	if err != nil {
+		it.err = err
+		return
+	}
+	it.versions, it.markers, it.prefixes = resp.Versions, resp.DeleteMarkers, resp.CommonPrefixes
+	it.keyMarker, it.versionIdMarker = resp.NextKeyMarker, resp.NextVersionIdMarker
+	it.done = !resp.IsTruncated || it.keyMarker == ""
+}
+
+// Version returns the version the iterator is at, or nil if it is at a
+// delete marker or common prefix.
+func (it *VersionIterator) Version() *Version {
+	return it.version
+}
+
+// DeleteMarker returns the delete marker the iterator is at, or nil if it
+// is at a version or common prefix.
+func (it *VersionIterator) DeleteMarker() *DeleteMarker {
+	return it.marker
+}
+
+// CommonPrefix returns the common prefix the iterator is at, or "" if it
+// is at a version or delete marker.
+func (it *VersionIterator) CommonPrefix() string {
+	return it.common
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *VersionIterator) Err() error {
+	return it.err
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/xml"
 	"errors"
 	"io"
+	"net/http"
 	"sort"
 	"strconv"
 )
@@ -23,6 +24,12 @@ type Multi struct {
 	Bucket   *Bucket
 	Key      string
 	UploadId string
+
+	// SSECustomerKey is the key the object being uploaded is encrypted
+	// with, from Options.SSECustomerKey, which S3 requires with every
+	// part. It must be set again on a Multi obtained with ResumeMulti or
+	// ListMulti.
+	SSECustomerKey []byte
 }
 
 // That's the default. Here just for testing.
@@ -55,17 +62,17 @@ func (b *Bucket) ListMulti(prefix, delim string) (multis []*Multi, prefixes []st
 		"prefix":      {prefix},
 		"delimiter":   {delim},
 	}
-	for attempt := attempts.Start(); attempt.Next(); {
+	for {
+		var resp listMultiResp
 		req := &request{
 			method: "GET",
 			bucket: b.Name,
 			params: params,
 		}
-		var resp listMultiResp
-		err := b.S3.query(req, &resp)
-		if shouldRetry(err) && attempt.HasNext() {
-			continue
-		}
+		err := b.S3.retry(req, func() error {
+			resp = listMultiResp{}
+			return b.S3.query(req, &resp)
+		})
 		if err != nil {
 			return nil, nil, err
 		}
@@ -80,25 +87,58 @@ func (b *Bucket) ListMulti(prefix, delim string) (multis []*Multi, prefixes []st
 		}
 		params["key-marker"] = []string{resp.NextKeyMarker}
 		params["upload-id-marker"] = []string{resp.NextUploadIdMarker}
-		attempt = attempts.Start() // Last request worked.
 	}
-	panic("unreachable")
 }
 
 // Multi returns a multipart upload handler for the provided key
 // inside b. If a multipart upload exists for key, it is returned,
 // otherwise a new multipart upload is initiated with contType and perm.
 func (b *Bucket) Multi(key, contType string, perm ACL) (*Multi, error) {
+	return b.multi(key, contType, perm, Options{})
+}
+
+func (b *Bucket) multi(key, contType string, perm ACL, options Options) (*Multi, error) {
 	multis, _, err := b.ListMulti(key, "")
 	if err != nil && !hasCode(err, "NoSuchUpload") {
 		return nil, err
 	}
 	for _, m := range multis {
 		if m.Key == key {
+			m.SSECustomerKey = options.SSECustomerKey
 			return m, nil
 		}
 	}
-	return b.InitMulti(key, contType, perm)
+	return b.initMulti(key, contType, perm, options)
+}
+
+// ResumeMulti returns a value for manipulating the unfinished multipart
+// upload of key identified by uploadId, as found in the UploadId field of
+// a Multi obtained earlier, possibly by another process. No request is
+// made, so the upload is not checked to exist.
+func (b *Bucket) ResumeMulti(key, uploadId string) *Multi {
+	return &Multi{Bucket: b, Key: key, UploadId: uploadId}
+}
+
+// PutResumable stores all of r at key via a multipart upload with parts
+// no larger than partSize bytes, which must be set to at least 5MB.
+// If an unfinished multipart upload of key exists, such as one left by
+// an earlier call that was interrupted, it is resumed: the parts it holds
+// are kept when their size and checksum match the content of r, and only
+// the others are sent before the upload is completed. contType, perm and
+// options only apply when a new upload is initiated.
+//
+// The upload is not aborted when PutResumable fails, so that calling it
+// again picks up where it stopped.
+func (b *Bucket) PutResumable(key string, r ReaderAtSeeker, partSize int64, contType string, perm ACL, options Options) error {
+	m, err := b.multi(key, contType, perm, options)
+	if err != nil {
+		return err
+	}
+	parts, err := m.PutAll(r, partSize)
+	if err != nil {
+		return err
+	}
+	return m.Complete(parts)
 }
 
 // InitMulti initializes a new multipart upload at the provided
@@ -106,11 +146,16 @@ func (b *Bucket) Multi(key, contType string, perm ACL) (*Multi, error) {
 //
 // See http://goo.gl/XP8kL for details.
 func (b *Bucket) InitMulti(key string, contType string, perm ACL) (*Multi, error) {
+	return b.initMulti(key, contType, perm, Options{})
+}
+
+func (b *Bucket) initMulti(key string, contType string, perm ACL, options Options) (*Multi, error) {
 	headers := map[string][]string{
 		"Content-Type":   {contType},
 		"Content-Length": {"0"},
 		"x-amz-acl":      {string(perm)},
 	}
+	options.addHeaders(headers)
 	params := map[string][]string{
 		"uploads": {""},
 	}
@@ -125,16 +170,13 @@ func (b *Bucket) InitMulti(key string, contType string, perm ACL) (*Multi, error
 	var resp struct {
 		UploadId string `xml:"UploadId"`
 	}
-	for attempt := attempts.Start(); attempt.Next(); {
-		err = b.S3.query(req, &resp)
-		if !shouldRetry(err) {
-			break
-		}
-	}
+	err = b.S3.retry(req, func() error {
+		return b.S3.query(req, &resp)
+	})
 	if err != nil {
 		return nil, err
 	}
-	return &Multi{Bucket: b, Key: key, UploadId: resp.UploadId}, nil
+	return &Multi{Bucket: b, Key: key, UploadId: resp.UploadId, SSECustomerKey: options.SSECustomerKey}, nil
 }
 
 // PutPart sends part n of the multipart upload, reading all the content from r.
@@ -154,41 +196,40 @@ func (m *Multi) putPart(n int, r io.ReadSeeker, partSize int64, md5b64 string) (
 		"Content-Length": {strconv.FormatInt(partSize, 10)},
 		"Content-MD5":    {md5b64},
 	}
+	addSSECustomerHeaders(headers, m.SSECustomerKey)
 	params := map[string][]string{
 		"uploadId":   {m.UploadId},
 		"partNumber": {strconv.FormatInt(int64(n), 10)},
 	}
-	for attempt := attempts.Start(); attempt.Next(); {
+	req := &request{
+		method:  "PUT",
+		bucket:  m.Bucket.Name,
+		path:    m.Key,
+		headers: headers,
+		params:  params,
+		payload: r,
+	}
+	var resp *http.Response
+	err := m.Bucket.S3.retry(req, func() error {
 		_, err := r.Seek(0, 0)
 		if err != nil {
-			return Part{}, err
-		}
-		req := &request{
-			method:  "PUT",
-			bucket:  m.Bucket.Name,
-			path:    m.Key,
-			headers: headers,
-			params:  params,
-			payload: r,
+			return err
 		}
 		err = m.Bucket.S3.prepare(req)
 		if err != nil {
-			return Part{}, err
-		}
-		resp, err := m.Bucket.S3.run(req, nil)
-		if shouldRetry(err) && attempt.HasNext() {
-			continue
-		}
-		if err != nil {
-			return Part{}, err
+			return err
 		}
-		etag := resp.Header.Get("ETag")
-		if etag == "" {
-			return Part{}, errors.New("part upload succeeded with no ETag")
-		}
-		return Part{n, etag, partSize}, nil
+		resp, err = m.Bucket.S3.run(req, nil)
+		return err
+	})
+	if err != nil {
+		return Part{}, err
+	}
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return Part{}, errors.New("part upload succeeded with no ETag")
 	}
-	panic("unreachable")
+	return Part{n, etag, partSize}, nil
 }
 
 func seekerInfo(r io.ReadSeeker) (size int64, md5hex string, md5b64 string, err error) {
@@ -238,18 +279,18 @@ func (m *Multi) ListParts() ([]Part, error) {
 		"max-parts": {strconv.FormatInt(int64(listPartsMax), 10)},
 	}
 	var parts partSlice
-	for attempt := attempts.Start(); attempt.Next(); {
+	for {
+		var resp listPartsResp
 		req := &request{
 			method: "GET",
 			bucket: m.Bucket.Name,
 			path:   m.Key,
 			params: params,
 		}
-		var resp listPartsResp
-		err := m.Bucket.S3.query(req, &resp)
-		if shouldRetry(err) && attempt.HasNext() {
-			continue
-		}
+		err := m.Bucket.S3.retry(req, func() error {
+			resp = listPartsResp{}
+			return m.Bucket.S3.query(req, &resp)
+		})
 		if err != nil {
 			return nil, err
 		}
@@ -259,9 +300,7 @@ func (m *Multi) ListParts() ([]Part, error) {
 			return parts, nil
 		}
 		params["part-number-marker"] = []string{resp.NextPartNumberMarker}
-		attempt = attempts.Start() // Last request worked.
 	}
-	panic("unreachable")
 }
 
 type ReaderAtSeeker interface {
@@ -356,21 +395,16 @@ func (m *Multi) Complete(parts []Part) error {
 	if err != nil {
 		return err
 	}
-	for attempt := attempts.Start(); attempt.Next(); {
-		req := &request{
-			method:  "POST",
-			bucket:  m.Bucket.Name,
-			path:    m.Key,
-			params:  params,
-			payload: bytes.NewReader(data),
-		}
-		err := m.Bucket.S3.query(req, nil)
-		if shouldRetry(err) && attempt.HasNext() {
-			continue
-		}
-		return err
+	req := &request{
+		method: "POST",
+		bucket: m.Bucket.Name,
+		path:   m.Key,
+		params: params,
 	}
-	panic("unreachable")
+	return m.Bucket.S3.retry(req, func() error {
+		req.payload = bytes.NewReader(data)
+		return m.Bucket.S3.query(req, nil)
+	})
 }
 
 // Abort deletes an unifinished multipart upload and any previously
@@ -392,18 +426,13 @@ func (m *Multi) Abort() error {
 	params := map[string][]string{
 		"uploadId": {m.UploadId},
 	}
-	for attempt := attempts.Start(); attempt.Next(); {
-		req := &request{
-			method: "DELETE",
-			bucket: m.Bucket.Name,
-			path:   m.Key,
-			params: params,
-		}
-		err := m.Bucket.S3.query(req, nil)
-		if shouldRetry(err) && attempt.HasNext() {
-			continue
-		}
-		return err
+	req := &request{
+		method: "DELETE",
+		bucket: m.Bucket.Name,
+		path:   m.Key,
+		params: params,
 	}
-	panic("unreachable")
+	return m.Bucket.S3.retry(req, func() error {
+		return m.Bucket.S3.query(req, nil)
+	})
 }
@@ -137,6 +137,19 @@ func (s *S) TestPutPart(c *gocheck.C) {
 	c.Assert(req.Header["Content-Md5"], gocheck.DeepEquals, []string{"JvkO/RDWFPEAJS/1bYja2A=="})
 }
 
+func (s *S) TestPutPartSSECustomerKey(c *gocheck.C) {
+	testServer.Response(200, map[string]string{"ETag": `"etag"`}, "")
+
+	multi := s.s3.Bucket("sample").ResumeMulti("multi", "upload-id")
+	multi.SSECustomerKey = []byte("0123456789abcdef0123456789abcdef")
+	_, err := multi.PutPart(1, strings.NewReader("<part 1>"))
+	c.Assert(err, gocheck.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Header["X-Amz-Server-Side-Encryption-Customer-Algorithm"], gocheck.DeepEquals, []string{"AES256"})
+	c.Assert(req.Header["X-Amz-Server-Side-Encryption-Customer-Key-Md5"], gocheck.DeepEquals, []string{"hRasmdxgYDKV3nvbahU1MA=="})
+}
+
 func readAll(r io.Reader) string {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
@@ -286,6 +299,70 @@ func (s *S) TestPutAllResume(c *gocheck.C) {
 	c.Assert(readAll(req.Body), gocheck.Equals, "partX")
 }
 
+func (s *S) TestPutResumable(c *gocheck.C) {
+	testServer.Response(200, nil, ListMultiResultDump)
+	testServer.Response(200, nil, ListPartsResultDump1)
+	testServer.Response(200, nil, ListPartsResultDump2)
+	testServer.Response(200, map[string]string{"ETag": `"etag2"`}, "")
+	testServer.Response(200, nil, "")
+
+	b := s.s3.Bucket("sample")
+
+	// "part1" and "part3" match the checksums of the parts already
+	// uploaded, so only "partX" is sent.
+	err := b.PutResumable("multi1", strings.NewReader("part1partXpart3"), 5, "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, gocheck.IsNil)
+
+	// Find the unfinished upload.
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, gocheck.Equals, "GET")
+	c.Assert(req.URL.Path, gocheck.Equals, "/sample/")
+	c.Assert(req.Form.Get("prefix"), gocheck.Equals, "multi1")
+
+	// List its parts, broken in two requests.
+	for i := 0; i < 2; i++ {
+		req = testServer.WaitRequest()
+		c.Assert(req.Method, gocheck.Equals, "GET")
+		c.Assert(req.URL.Path, gocheck.Equals, "/sample/multi1")
+		c.Assert(req.Form.Get("uploadId"), gocheck.Equals, "iUVug89pPvSswrikD")
+	}
+
+	req = testServer.WaitRequest()
+	c.Assert(req.Method, gocheck.Equals, "PUT")
+	c.Assert(req.Form.Get("uploadId"), gocheck.Equals, "iUVug89pPvSswrikD")
+	c.Assert(req.Form["partNumber"], gocheck.DeepEquals, []string{"2"})
+	c.Assert(readAll(req.Body), gocheck.Equals, "partX")
+
+	req = testServer.WaitRequest()
+	c.Assert(req.Method, gocheck.Equals, "POST")
+	c.Assert(req.Form.Get("uploadId"), gocheck.Equals, "iUVug89pPvSswrikD")
+	var payload struct {
+		Part []struct {
+			PartNumber int
+			ETag       string
+		}
+	}
+	err = xml.Unmarshal([]byte(readAll(req.Body)), &payload)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(payload.Part, gocheck.HasLen, 3)
+	c.Assert(payload.Part[0].ETag, gocheck.Equals, `"ffc88b4ca90a355f8ddba6b2c3b2af5c"`)
+	c.Assert(payload.Part[1].ETag, gocheck.Equals, `"etag2"`)
+	c.Assert(payload.Part[2].ETag, gocheck.Equals, `"49dcd91231f801159e893fb5c6674985"`)
+}
+
+func (s *S) TestResumeMulti(c *gocheck.C) {
+	testServer.Response(200, nil, ListPartsResultDump2)
+
+	multi := s.s3.Bucket("sample").ResumeMulti("multi", "upload-id")
+	parts, err := multi.ListParts()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(parts, gocheck.HasLen, 1)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.URL.Path, gocheck.Equals, "/sample/multi")
+	c.Assert(req.Form.Get("uploadId"), gocheck.Equals, "upload-id")
+}
+
 func (s *S) TestMultiComplete(c *gocheck.C) {
 	testServer.Response(200, nil, InitMultiResultDump)
 	// Note the 200 response. Completing will hold the connection on some
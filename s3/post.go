@@ -0,0 +1,175 @@
+package s3
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/hailocab/goamz/aws"
+	"strconv"
+	"time"
+)
+
+// A PostPolicy builds the policy and form fields of browser-based uploads
+// to a bucket, which POST an HTML form straight to S3. The policy lists
+// the conditions the form must meet, and is signed so that S3 can check
+// it was issued by the bucket owner:
+//
+//	p := s3.NewPostPolicy(b, time.Now().Add(time.Hour))
+//	p.SetKeyPrefix("uploads/")
+//	p.SetContentLengthRange(1, 10<<20)
+//	p.SetSuccessActionStatus(201)
+//	action, fields, err := p.Sign()
+//
+// The form is then sent to action with fields as hidden inputs, followed
+// by the file in an input named "file".
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/dev/UsingHTTPPOST.html
+// for details.
+type PostPolicy struct {
+	bucket     *Bucket
+	expires    time.Time
+	conditions []interface{}
+	fields     map[string]string
+}
+
+// NewPostPolicy returns a policy for uploads to b that expires at
+// expires.
+func NewPostPolicy(b *Bucket, expires time.Time) *PostPolicy {
+	return &PostPolicy{
+		bucket:  b,
+		expires: expires,
+		fields:  make(map[string]string),
+	}
+}
+
+// Equals requires the form field named field to be set to value, and
+// sets it in the fields Sign returns.
+func (p *PostPolicy) Equals(field, value string) {
+	p.conditions = append(p.conditions, []string{"eq", "$" + field, value})
+	p.fields[field] = value
+}
+
+// StartsWith requires the form field named field to start with prefix.
+// An empty prefix allows any value. The field is left for the form to
+// set.
+func (p *PostPolicy) StartsWith(field, prefix string) {
+	p.conditions = append(p.conditions, []string{"starts-with", "$" + field, prefix})
+}
+
+// SetKey requires the object uploaded to be stored at key.
+func (p *PostPolicy) SetKey(key string) {
+	p.Equals("key", key)
+}
+
+// SetKeyPrefix requires the key of the object uploaded to start with
+// prefix. The key field is set to prefix followed by the name of the file
+// uploaded, which the form may change.
+func (p *PostPolicy) SetKeyPrefix(prefix string) {
+	p.StartsWith("key", prefix)
+	p.fields["key"] = prefix + "${filename}"
+}
+
+// SetContentType requires the Content-Type of the object uploaded to be
+// contType.
+func (p *PostPolicy) SetContentType(contType string) {
+	p.Equals("Content-Type", contType)
+}
+
+// SetContentTypePrefix requires the Content-Type of the object uploaded,
+// which the form must set, to start with prefix, such as "image/".
+func (p *PostPolicy) SetContentTypePrefix(prefix string) {
+	p.StartsWith("Content-Type", prefix)
+}
+
+// SetContentLengthRange requires the size of the object uploaded to be
+// between min and max bytes, inclusive.
+func (p *PostPolicy) SetContentLengthRange(min, max int64) {
+	p.conditions = append(p.conditions, []interface{}{"content-length-range", min, max})
+}
+
+// SetACL requires the object uploaded to get the canned ACL perm.
+func (p *PostPolicy) SetACL(perm ACL) {
+	p.Equals("acl", string(perm))
+}
+
+// SetSuccessActionStatus sets the status S3 replies with to a successful
+// upload: 200 or 204, both with an empty body, or 201 with an XML
+// document describing the object.
+func (p *PostPolicy) SetSuccessActionStatus(status int) {
+	p.Equals("success_action_status", strconv.Itoa(status))
+}
+
+// SetSuccessActionRedirect makes S3 redirect browsers to url after a
+// successful upload.
+func (p *PostPolicy) SetSuccessActionRedirect(url string) {
+	p.Equals("success_action_redirect", url)
+}
+
+// SetMeta requires the object uploaded to have the metadata value under
+// name, as Options.Meta would set.
+func (p *PostPolicy) SetMeta(name, value string) {
+	p.Equals("x-amz-meta-"+name, value)
+}
+
+// Sign returns the URL the form must be sent to and the fields it must
+// hold: those set by the conditions of p, the policy and its signature.
+// The signature is made with signature version 4 in the regions where
+// requests are, or version 2 otherwise.
+func (p *PostPolicy) Sign() (action string, fields map[string]string, err error) {
+	b := p.bucket
+	req := &request{bucket: b.Name, path: "/"}
+	if err := b.S3.setup(req); err != nil {
+		return "", nil, err
+	}
+	u, err := req.url()
+	if err != nil {
+		return "", nil, err
+	}
+	auth, err := b.Auth.Current()
+	if err != nil {
+		return "", nil, err
+	}
+
+	fields = make(map[string]string)
+	for k, v := range p.fields {
+		fields[k] = v
+	}
+	v4 := b.S3.v4()
+	signer := aws.NewV4Signer(auth, "s3", b.Region)
+	t := time.Now().UTC()
+	if v4 {
+		for k, v := range signer.PostFields(t) {
+			fields[k] = v
+		}
+	} else {
+		fields["AWSAccessKeyId"] = auth.AccessKey
+		if token := auth.Token(); token != "" {
+			fields["x-amz-security-token"] = token
+		}
+	}
+
+	conditions := append([]interface{}{map[string]string{"bucket": b.Name}}, p.conditions...)
+	for _, k := range []string{"x-amz-algorithm", "x-amz-credential", "x-amz-date", "x-amz-security-token"} {
+		if v, ok := fields[k]; ok {
+			conditions = append(conditions, map[string]string{k: v})
+		}
+	}
+	doc, err := json.Marshal(map[string]interface{}{
+		"expiration": p.expires.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	policy := base64.StdEncoding.EncodeToString(doc)
+	fields["policy"] = policy
+	if v4 {
+		fields["x-amz-signature"] = signer.SignPost(t, policy)
+	} else {
+		mac := hmac.New(sha1.New, []byte(auth.SecretKey))
+		mac.Write([]byte(policy))
+		fields["signature"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	return u.String(), fields, nil
+}
@@ -0,0 +1,106 @@
+package s3_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/hailocab/goamz/aws"
+	"github.com/hailocab/goamz/s3"
+	"launchpad.net/gocheck"
+	"strings"
+	"time"
+)
+
+// decodePolicy returns the expiration and conditions of a POST policy.
+func decodePolicy(c *gocheck.C, policy string) (string, []interface{}) {
+	data, err := base64.StdEncoding.DecodeString(policy)
+	c.Assert(err, gocheck.IsNil)
+	var doc struct {
+		Expiration string
+		Conditions []interface{}
+	}
+	err = json.Unmarshal(data, &doc)
+	c.Assert(err, gocheck.IsNil)
+	return doc.Expiration, doc.Conditions
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func (s *S) TestPostPolicyV2(c *gocheck.C) {
+	p := s3.NewPostPolicy(s.s3.Bucket("bucket"), time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	p.SetKeyPrefix("uploads/")
+	p.SetContentTypePrefix("image/")
+	p.SetContentLengthRange(1, 1024)
+	p.SetACL(s3.PublicRead)
+	p.SetSuccessActionStatus(201)
+	p.SetMeta("uploader", "web")
+	action, fields, err := p.Sign()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(action, gocheck.Equals, testServer.URL+"/bucket/")
+
+	policy := fields["policy"]
+	mac := hmac.New(sha1.New, []byte("123"))
+	mac.Write([]byte(policy))
+	c.Assert(fields, gocheck.DeepEquals, map[string]string{
+		"AWSAccessKeyId":        "abc",
+		"key":                   "uploads/${filename}",
+		"acl":                   "public-read",
+		"success_action_status": "201",
+		"x-amz-meta-uploader":   "web",
+		"policy":                policy,
+		"signature":             base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+	})
+
+	expiration, conditions := decodePolicy(c, policy)
+	c.Assert(expiration, gocheck.Equals, "2020-01-02T03:04:05.000Z")
+	c.Assert(conditions, gocheck.DeepEquals, []interface{}{
+		map[string]interface{}{"bucket": "bucket"},
+		[]interface{}{"starts-with", "$key", "uploads/"},
+		[]interface{}{"starts-with", "$Content-Type", "image/"},
+		[]interface{}{"content-length-range", 1.0, 1024.0},
+		[]interface{}{"eq", "$acl", "public-read"},
+		[]interface{}{"eq", "$success_action_status", "201"},
+		[]interface{}{"eq", "$x-amz-meta-uploader", "web"},
+	})
+}
+
+func (s *S) TestPostPolicyV4(c *gocheck.C) {
+	auth := aws.Auth{AccessKey: "abc", SecretKey: "123"}
+	s3v4 := s3.New(auth, aws.Region{Name: "faux-region-1", S3Endpoint: testServer.URL})
+	s3v4.SignatureVersion = 4
+
+	p := s3.NewPostPolicy(s3v4.Bucket("bucket"), time.Now().Add(time.Hour))
+	p.SetKey("name")
+	action, fields, err := p.Sign()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(action, gocheck.Equals, testServer.URL+"/bucket/")
+
+	c.Assert(fields["key"], gocheck.Equals, "name")
+	c.Assert(fields["x-amz-algorithm"], gocheck.Equals, "AWS4-HMAC-SHA256")
+	c.Assert(fields["x-amz-date"], gocheck.Matches, `\d{8}T\d{6}Z`)
+	date := fields["x-amz-date"][:8]
+	c.Assert(fields["x-amz-credential"], gocheck.Equals, "abc/"+date+"/faux-region-1/s3/aws4_request")
+	c.Assert(fields["signature"], gocheck.Equals, "")
+
+	_, conditions := decodePolicy(c, fields["policy"])
+	c.Assert(conditions, gocheck.DeepEquals, []interface{}{
+		map[string]interface{}{"bucket": "bucket"},
+		[]interface{}{"eq", "$key", "name"},
+		map[string]interface{}{"x-amz-algorithm": "AWS4-HMAC-SHA256"},
+		map[string]interface{}{"x-amz-credential": fields["x-amz-credential"]},
+		map[string]interface{}{"x-amz-date": fields["x-amz-date"]},
+	})
+
+	key := hmacSHA256([]byte("AWS4123"), date)
+	for _, part := range strings.Split("faux-region-1/s3/aws4_request", "/") {
+		key = hmacSHA256(key, part)
+	}
+	c.Assert(fields["x-amz-signature"], gocheck.Equals, fmt.Sprintf("%x", hmacSHA256(key, fields["policy"])))
+}
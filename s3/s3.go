@@ -12,21 +12,25 @@ package s3
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
+	"crypto/md5"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"github.com/hailocab/goamz/aws"
 	"io"
 	"io/ioutil"
 	"log"
-	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -36,8 +40,24 @@ const debug = false
 type S3 struct {
 	aws.Auth
 	aws.Region
+
+	// Config holds the HTTP settings used to talk to S3. If nil,
+	// aws.DefaultConfig is used.
+	Config *aws.Config
+
+	// ConnectTimeout and ReadTimeout are honoured when Config is nil,
+	// and must be set before the first request is made.
 	ConnectTimeout time.Duration
 	ReadTimeout    time.Duration
+
+	// SignatureVersion selects how requests are signed: 2 or 4. If zero,
+	// version 4 is used, except in the regions that predate it, which
+	// are signed with version 2.
+	SignatureVersion int
+
+	timeoutsOnce   sync.Once
+	timeoutsConfig *aws.Config
+	ctx            context.Context
 	private        byte // Reserve the right of using private data.
 }
 
@@ -53,18 +73,141 @@ type Owner struct {
 	DisplayName string
 }
 
-// Fold options into an Options struct
-//
+// Options holds the metadata and encryption settings of objects being
+// stored.
 type Options struct {
-	SSE              bool
-	Meta             map[string][]string
-	ContentEncoding  string
-	CacheControl     string
-	RedirectLocation string
-	// What else?
-	// Content-Disposition string
-	//// The following become headers so they are []strings rather than strings... I think
-	// x-amz-storage-class []string
+	// SSE requests server-side encryption with keys managed by S3.
+	SSE bool
+
+	// SSEKMS requests server-side encryption with keys managed by KMS,
+	// using the key SSEKMSKeyId or, if that is empty, the default key of
+	// the account. Setting SSEKMSKeyId implies SSEKMS.
+	SSEKMS      bool
+	SSEKMSKeyId string
+
+	// SSECustomerKey, if set, is the 256-bit key the object is encrypted
+	// with on the server. S3 does not keep it, so the same key must be
+	// given to read the object back.
+	SSECustomerKey []byte
+
+	Meta               map[string][]string
+	ContentEncoding    string
+	ContentDisposition string
+	CacheControl       string
+	Expires            time.Time
+	StorageClass       StorageClass
+	RedirectLocation   string
+
+	// Tags are attached to the object.
+	Tags []Tag
+}
+
+// A StorageClass sets the durability, availability and cost of storing
+// an object.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/dev/storage-class-intro.html
+// for details.
+type StorageClass string
+
+const (
+	StandardStorage          = StorageClass("STANDARD")
+	StandardIAStorage        = StorageClass("STANDARD_IA")
+	ReducedRedundancyStorage = StorageClass("REDUCED_REDUNDANCY")
+	GlacierStorage           = StorageClass("GLACIER")
+)
+
+// addHeaders adds the headers requesting o to headers.
+func (o Options) addHeaders(headers map[string][]string) {
+	if o.SSE {
+		headers["x-amz-server-side-encryption"] = []string{"AES256"}
+	}
+	if o.SSEKMS || o.SSEKMSKeyId != "" {
+		headers["x-amz-server-side-encryption"] = []string{"aws:kms"}
+		if o.SSEKMSKeyId != "" {
+			headers["x-amz-server-side-encryption-aws-kms-key-id"] = []string{o.SSEKMSKeyId}
+		}
+	}
+	addSSECustomerHeaders(headers, o.SSECustomerKey)
+	if len(o.ContentEncoding) != 0 {
+		headers["Content-Encoding"] = []string{o.ContentEncoding}
+	}
+	if len(o.ContentDisposition) != 0 {
+		headers["Content-Disposition"] = []string{o.ContentDisposition}
+	}
+	if len(o.CacheControl) != 0 {
+		headers["Cache-Control"] = []string{o.CacheControl}
+	}
+	if !o.Expires.IsZero() {
+		headers["Expires"] = []string{o.Expires.UTC().Format(http.TimeFormat)}
+	}
+	if len(o.StorageClass) != 0 {
+		headers["x-amz-storage-class"] = []string{string(o.StorageClass)}
+	}
+	if len(o.RedirectLocation) != 0 {
+		headers["x-amz-website-redirect-location"] = []string{o.RedirectLocation}
+	}
+	if len(o.Tags) != 0 {
+		tags := make(url.Values)
+		for _, tag := range o.Tags {
+			tags.Add(tag.Key, tag.Value)
+		}
+		headers["x-amz-tagging"] = []string{tags.Encode()}
+	}
+	for k, v := range o.Meta {
+		headers["x-amz-meta-"+k] = v
+	}
+}
+
+// addSSECustomerHeaders adds the headers giving key, a key for
+// server-side encryption, to headers. Nothing is added if key is nil.
+func addSSECustomerHeaders(headers map[string][]string, key []byte) {
+	if key == nil {
+		return
+	}
+	sum := md5.Sum(key)
+	headers["x-amz-server-side-encryption-customer-algorithm"] = []string{"AES256"}
+	headers["x-amz-server-side-encryption-customer-key"] = []string{base64.StdEncoding.EncodeToString(key)}
+	headers["x-amz-server-side-encryption-customer-key-MD5"] = []string{base64.StdEncoding.EncodeToString(sum[:])}
+}
+
+// GetOptions holds the conditions under which objects are read, and the
+// key needed to read objects stored with Options.SSECustomerKey.
+//
+// When a condition does not hold, the error returned has the code
+// "PreconditionFailed" or, for IfNoneMatch and IfModifiedSince, makes
+// IsNotModified return true.
+type GetOptions struct {
+	// IfMatch and IfNoneMatch hold an ETag, as found in Key.ETag.
+	IfMatch           string
+	IfNoneMatch       string
+	IfModifiedSince   time.Time
+	IfUnmodifiedSince time.Time
+
+	SSECustomerKey []byte
+}
+
+// addHeaders adds the headers requesting o to headers.
+func (o GetOptions) addHeaders(headers map[string][]string) {
+	if o.IfMatch != "" {
+		headers["If-Match"] = []string{o.IfMatch}
+	}
+	if o.IfNoneMatch != "" {
+		headers["If-None-Match"] = []string{o.IfNoneMatch}
+	}
+	if !o.IfModifiedSince.IsZero() {
+		headers["If-Modified-Since"] = []string{o.IfModifiedSince.UTC().Format(http.TimeFormat)}
+	}
+	if !o.IfUnmodifiedSince.IsZero() {
+		headers["If-Unmodified-Since"] = []string{o.IfUnmodifiedSince.UTC().Format(http.TimeFormat)}
+	}
+	addSSECustomerHeaders(headers, o.SSECustomerKey)
+}
+
+// IsNotModified reports whether err was returned by a read whose
+// GetOptions.IfNoneMatch or IfModifiedSince condition did not hold.
+func IsNotModified(err error) bool {
+	s3err, ok := err.(*Error)
+	return ok && s3err.StatusCode == http.StatusNotModified
 }
 
 // CopyObjectResult is the output from a Copy request
@@ -73,15 +216,45 @@ type CopyObjectResult struct {
 	LastModified string
 }
 
-var attempts = aws.AttemptStrategy{
-	Min:   5,
-	Total: 5 * time.Second,
-	Delay: 200 * time.Millisecond,
+// retryer is used by S3 values whose Config has no Retryer. S3 is
+// eventually consistent, so it keeps trying for longer than the default.
+var retryer aws.Retryer = aws.DefaultRetryer{
+	Attempts:  5,
+	BaseDelay: 200 * time.Millisecond,
 }
 
 // New creates a new S3.
 func New(auth aws.Auth, region aws.Region) *S3 {
-	return &S3{auth, region, 0, 0, 0}
+	return NewWithConfig(auth, region, nil)
+}
+
+// NewWithConfig creates a new S3 that sends requests using the HTTP
+// settings in config.
+func NewWithConfig(auth aws.Auth, region aws.Region, config *aws.Config) *S3 {
+	return &S3{Auth: auth, Region: region, Config: config}
+}
+
+// WithContext returns a shallow copy of s3 whose requests are made with
+// ctx, so that cancelling ctx or reaching its deadline aborts them,
+// including any retries and the reading of response bodies.
+func (s3 *S3) WithContext(ctx context.Context) *S3 {
+	if ctx == nil {
+		panic("nil context")
+	}
+	return &S3{
+		Auth:             s3.Auth,
+		Region:           s3.Region,
+		Config:           s3.config(),
+		ConnectTimeout:   s3.ConnectTimeout,
+		ReadTimeout:      s3.ReadTimeout,
+		SignatureVersion: s3.SignatureVersion,
+		ctx:              ctx,
+	}
+}
+
+// WithContext returns a copy of b whose requests are made with ctx.
+func (b *Bucket) WithContext(ctx context.Context) *Bucket {
+	return &Bucket{b.S3.WithContext(ctx), b.Name}
 }
 
 // Bucket returns a Bucket with the given name.
@@ -102,7 +275,9 @@ var createBucketConfiguration = `<CreateBucketConfiguration xmlns="http://s3.ama
 // See http://goo.gl/bh9Kq for details.
 func (s3 *S3) locationConstraint() io.Reader {
 	constraint := ""
-	if s3.Region.S3LocationConstraint {
+	// Regions given by name alone need a constraint, except us-east-1.
+	computed := s3.Region.S3Endpoint == "" && s3.Region.Name != "" && s3.Region.Name != "us-east-1"
+	if s3.Region.S3LocationConstraint || computed {
 		constraint = fmt.Sprintf(createBucketConfiguration, s3.Region.Name)
 	}
 	return strings.NewReader(constraint)
@@ -146,13 +321,9 @@ func (b *Bucket) DelBucket() (err error) {
 		bucket: b.Name,
 		path:   "/",
 	}
-	for attempt := attempts.Start(); attempt.Next(); {
-		err = b.S3.query(req, nil)
-		if !shouldRetry(err) {
-			break
-		}
-	}
-	return err
+	return b.S3.retry(req, func() error {
+		return b.S3.query(req, nil)
+	})
 }
 
 // Get retrieves an object from an S3 bucket.
@@ -199,21 +370,17 @@ func (b *Bucket) GetResponseWithHeaders(path string, headers map[string][]string
 		path:    path,
 		headers: headers,
 	}
-	err = b.S3.prepare(req)
+	err = b.S3.retry(req, func() error {
+		if err := b.S3.prepare(req); err != nil {
+			return err
+		}
+		resp, err = b.S3.run(req, nil)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
-	for attempt := attempts.Start(); attempt.Next(); {
-		resp, err := b.S3.run(req, nil)
-		if shouldRetry(err) && attempt.HasNext() {
-			continue
-		}
-		if err != nil {
-			return nil, err
-		}
-		return resp, nil
-	}
-	panic("unreachable")
+	return resp, nil
 }
 
 // Exists checks whether or not an object exists on an S3 bucket using a HEAD request.
@@ -223,35 +390,29 @@ func (b *Bucket) Exists(path string) (exists bool, err error) {
 		bucket: b.Name,
 		path:   path,
 	}
-	err = b.S3.prepare(req)
-	if err != nil {
-		return
-	}
-	for attempt := attempts.Start(); attempt.Next(); {
-		resp, err := b.S3.run(req, nil)
-
-		if shouldRetry(err) && attempt.HasNext() {
-			continue
+	var resp *http.Response
+	err = b.S3.retry(req, func() error {
+		if err := b.S3.prepare(req); err != nil {
+			return err
 		}
-
-		if err != nil {
-			// We can treat a 403 or 404 as non existance
-			if (*err.(*Error)).StatusCode == 403 || (*err.(*Error)).StatusCode == 404 {
-				return false, nil
-			} else {
-				return false, err
-			}
+		resp, err = b.S3.run(req, nil)
+		return err
+	})
+	if err != nil {
+		// We can treat a 403 or 404 as non existance
+		if e, ok := err.(*Error); ok && (e.StatusCode == 403 || e.StatusCode == 404) {
+			return false, nil
 		}
+		return false, err
+	}
 
-		if resp.StatusCode/100 == 2 {
-			exists = true
-		}
-		if resp.Body != nil {
-			resp.Body.Close()
-		}
-		return exists, err
+	if resp.StatusCode/100 == 2 {
+		exists = true
+	}
+	if resp.Body != nil {
+		resp.Body.Close()
 	}
-	return false, fmt.Errorf("S3 Currently Unreachable")
+	return exists, nil
 }
 
 // Head HEADs an object in the S3 bucket, returns the response with
@@ -263,59 +424,146 @@ func (b *Bucket) Head(path string, headers map[string][]string) (*http.Response,
 		path:    path,
 		headers: headers,
 	}
-	err := b.S3.prepare(req)
+	var resp *http.Response
+	err := b.S3.retry(req, func() error {
+		err := b.S3.prepare(req)
+		if err != nil {
+			return err
+		}
+		resp, err = b.S3.run(req, nil)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
+	return resp, nil
+}
 
-	for attempt := attempts.Start(); attempt.Next(); {
-		resp, err := b.S3.run(req, nil)
-		if shouldRetry(err) && attempt.HasNext() {
-			continue
+// GetResponseWithOptions retrieves an object from an S3 bucket under the
+// conditions in options, returning the HTTP response.
+// It is the caller's responsibility to call Close on resp.Body when
+// finished reading.
+func (b *Bucket) GetResponseWithOptions(path string, options GetOptions) (resp *http.Response, err error) {
+	headers := make(map[string][]string)
+	options.addHeaders(headers)
+	return b.GetResponseWithHeaders(path, headers)
+}
+
+// ObjectInfo holds the metadata of an object.
+type ObjectInfo struct {
+	Key  string
+	Size int64
+	// ETag gives the hex-encoded MD5 sum of the contents of objects not
+	// uploaded in parts, surrounded with double-quotes.
+	ETag               string
+	LastModified       time.Time
+	ContentType        string
+	ContentEncoding    string
+	ContentDisposition string
+	CacheControl       string
+	Expires            time.Time // zero if unset or not a valid date
+	StorageClass       StorageClass
+	RedirectLocation   string
+	VersionId          string
+
+	// SSE is the server-side encryption algorithm, "AES256" or "aws:kms",
+	// and SSEKMSKeyId the KMS key used with the latter.
+	SSE         string
+	SSEKMSKeyId string
+
+	TagCount int
+
+	// Meta holds the user metadata, with lowercase names as given in
+	// Options.Meta.
+	Meta map[string][]string
+}
+
+// Stat returns the metadata of the object at path, read with a HEAD
+// request under the conditions in options.
+func (b *Bucket) Stat(path string, options GetOptions) (*ObjectInfo, error) {
+	return b.stat(path, "", options)
+}
+
+// stat is like Stat, for the version versionId of the object if it is
+// not empty.
+func (b *Bucket) stat(path, versionId string, options GetOptions) (*ObjectInfo, error) {
+	req := &request{
+		method:  "HEAD",
+		bucket:  b.Name,
+		path:    path,
+		headers: make(map[string][]string),
+	}
+	options.addHeaders(req.headers)
+	if versionId != "" {
+		req.params = url.Values{"versionId": {versionId}}
+	}
+	var resp *http.Response
+	err := b.S3.retry(req, func() error {
+		if err := b.S3.prepare(req); err != nil {
+			return err
 		}
-		if err != nil {
-			return nil, err
+		var err error
+		resp, err = b.S3.run(req, nil)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return newObjectInfo(path, resp), nil
+}
+
+func newObjectInfo(path string, resp *http.Response) *ObjectInfo {
+	h := resp.Header
+	info := &ObjectInfo{
+		Key:                path,
+		Size:               resp.ContentLength,
+		ETag:               h.Get("ETag"),
+		ContentType:        h.Get("Content-Type"),
+		ContentEncoding:    h.Get("Content-Encoding"),
+		ContentDisposition: h.Get("Content-Disposition"),
+		CacheControl:       h.Get("Cache-Control"),
+		StorageClass:       StorageClass(h.Get("x-amz-storage-class")),
+		RedirectLocation:   h.Get("x-amz-website-redirect-location"),
+		VersionId:          h.Get("x-amz-version-id"),
+		SSE:                h.Get("x-amz-server-side-encryption"),
+		SSEKMSKeyId:        h.Get("x-amz-server-side-encryption-aws-kms-key-id"),
+		Meta:               make(map[string][]string),
+	}
+	// S3 leaves out the storage class of STANDARD objects.
+	if info.StorageClass == "" {
+		info.StorageClass = StandardStorage
+	}
+	info.LastModified, _ = http.ParseTime(h.Get("Last-Modified"))
+	info.Expires, _ = http.ParseTime(h.Get("Expires"))
+	info.TagCount, _ = strconv.Atoi(h.Get("x-amz-tagging-count"))
+	for name, values := range h {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-amz-meta-") {
+			info.Meta[name[len("x-amz-meta-"):]] = values
 		}
-		return resp, err
 	}
-	return nil, fmt.Errorf("S3 Currently Unreachable")
+	return info
 }
 
 // Put inserts an object into the S3 bucket.
 //
 // See http://goo.gl/FEBPD for details.
 func (b *Bucket) Put(path string, data []byte, contType string, perm ACL, options Options) error {
-	body := bytes.NewBuffer(data)
+	body := bytes.NewReader(data)
 	return b.PutReader(path, body, int64(len(data)), contType, perm, options)
 }
 
-// PutCopy puts a copy of an object given by the key path into bucket b using b.Path as the target key
+// PutCopy puts a copy of an object given by the key path into bucket b using b.Path as the target key.
+// The source is sent as it is, so it must be escaped. See Copy for more
+// control over the copy.
 func (b *Bucket) PutCopy(path string, perm ACL, options Options, source string) (*CopyObjectResult, error) {
 	headers := map[string][]string{
 		"x-amz-acl":         {string(perm)},
 		"x-amz-copy-source": {source},
 	}
-	if options.SSE {
-		headers["x-amz-server-side-encryption"] = []string{"AES256"}
-	}
-	if len(options.ContentEncoding) != 0 {
-		headers["Content-Encoding"] = []string{options.ContentEncoding}
-	}
-	for k, v := range options.Meta {
-		headers["x-amz-meta-"+k] = v
-	}
-	req := &request{
-		method:  "PUT",
-		bucket:  b.Name,
-		path:    path,
-		headers: headers,
-	}
-	resp := &CopyObjectResult{}
-	err := b.S3.query(req, resp)
-	if err != nil {
-		return resp, err
-	}
-	return resp, nil
+	options.addHeaders(headers)
+	return b.copy(path, headers)
 }
 
 // PutReader inserts an object into the S3 bucket by consuming data
@@ -326,21 +574,7 @@ func (b *Bucket) PutReader(path string, r io.Reader, length int64, contType stri
 		"Content-Type":   {contType},
 		"x-amz-acl":      {string(perm)},
 	}
-	if options.SSE {
-		headers["x-amz-server-side-encryption"] = []string{"AES256"}
-	}
-	if len(options.ContentEncoding) != 0 {
-		headers["Content-Encoding"] = []string{options.ContentEncoding}
-	}
-	if len(options.CacheControl) != 0 {
-		headers["Cache-Control"] = []string{options.CacheControl}
-	}
-	if len(options.RedirectLocation) != 0 {
-		headers["x-amz-website-redirect-location"] = []string{options.RedirectLocation}
-	}
-	for k, v := range options.Meta {
-		headers["x-amz-meta-"+k] = v
-	}
+	options.addHeaders(headers)
 	req := &request{
 		method:  "PUT",
 		bucket:  b.Name,
@@ -406,13 +640,164 @@ func (b *Bucket) Del(path string) error {
 	return b.S3.query(req, nil)
 }
 
+// An ObjectId identifies an object, or one version of it, to DelMulti.
+type ObjectId struct {
+	Key       string
+	VersionId string `xml:",omitempty"`
+}
+
+// The DeleteResp type holds the results of a DelMulti operation.
+type DeleteResp struct {
+	// Deleted holds the objects that were deleted. It is empty in
+	// quiet mode.
+	Deleted []DeletedObject
+	Errors  []DeleteError `xml:"Error"`
+}
+
+// The DeletedObject type describes an object deleted by DelMulti. Deleting
+// an object without giving a version in a versioned bucket creates a
+// delete marker.
+type DeletedObject struct {
+	Key                   string
+	VersionId             string
+	DeleteMarker          bool
+	DeleteMarkerVersionId string
+}
+
+// DeleteError describes the failure to delete one of the objects given
+// to DelMulti.
+type DeleteError struct {
+	Key       string
+	VersionId string
+	Code      string
+	Message   string
+}
+
+func (e *DeleteError) Error() string {
+	return fmt.Sprintf("cannot delete %q: %s: %s", e.Key, e.Code, e.Message)
+}
+
+// DeleteErrors is returned by DelPrefix when some objects could not be
+// deleted.
+type DeleteErrors []DeleteError
+
+func (e DeleteErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e[0].Error(), len(e)-1)
+}
+
+type deleteRequest struct {
+	XMLName xml.Name   `xml:"Delete"`
+	Quiet   bool       `xml:",omitempty"`
+	Objects []ObjectId `xml:"Object"`
+}
+
+// That's the limit. Here just for testing.
+var delMultiMax = 1000
+
+// DelMulti removes the given objects from the S3 bucket, sending a
+// request for every 1000 of them. In quiet mode only the objects that
+// could not be deleted are reported.
+//
+// The error returned is only about failed requests; failures to delete
+// individual objects are found in the Errors field of the result.
+//
+// See http://docs.aws.amazon.com/AmazonS3/latest/API/multiobjectdeleteapi.html for details.
+func (b *Bucket) DelMulti(objects []ObjectId, quiet bool) (*DeleteResp, error) {
+	result := &DeleteResp{}
+	for len(objects) > 0 {
+		n := len(objects)
+		if n > delMultiMax {
+			n = delMultiMax
+		}
+		data, err := xml.Marshal(deleteRequest{Quiet: quiet, Objects: objects[:n]})
+		if err != nil {
+			return nil, err
+		}
+		objects = objects[n:]
+		sum := md5.Sum(data)
+		req := &request{
+			method: "POST",
+			bucket: b.Name,
+			path:   "/",
+			params: url.Values{"delete": {""}},
+			headers: map[string][]string{
+				"Content-Length": {strconv.Itoa(len(data))},
+				"Content-MD5":    {base64.StdEncoding.EncodeToString(sum[:])},
+			},
+		}
+		var resp DeleteResp
+		err = b.S3.retry(req, func() error {
+			req.payload = bytes.NewReader(data)
+			resp = DeleteResp{}
+			return b.S3.query(req, &resp)
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.Deleted = append(result.Deleted, resp.Deleted...)
+		result.Errors = append(result.Errors, resp.Errors...)
+	}
+	return result, nil
+}
+
+// DelPrefix removes all the objects whose key begins with prefix from
+// the S3 bucket, listing and deleting them a page at a time, and returns
+// how many were deleted. If some of them could not be deleted, the error
+// returned is a DeleteErrors.
+func (b *Bucket) DelPrefix(prefix string) (int, error) {
+	it := NewListIterator(b, prefix, "")
+	it.PageSize = delMultiMax
+	var (
+		batch   []ObjectId
+		deleted int
+		failed  DeleteErrors
+	)
+	flush := func() error {
+		resp, err := b.DelMulti(batch, true)
+		if err != nil {
+			return err
+		}
+		deleted += len(batch) - len(resp.Errors)
+		failed = append(failed, resp.Errors...)
+		batch = batch[:0]
+		return nil
+	}
+	for it.Next() {
+		batch = append(batch, ObjectId{Key: it.Key().Key})
+		if len(batch) == delMultiMax {
+			if err := flush(); err != nil {
+				return deleted, err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return deleted, err
+	}
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return deleted, err
+		}
+	}
+	if len(failed) > 0 {
+		return deleted, failed
+	}
+	return deleted, nil
+}
+
 // The ListResp type holds the results of a List bucket operation.
 type ListResp struct {
 	Name      string
 	Prefix    string
 	Delimiter string
 	Marker    string
-	MaxKeys   int
+	// NextMarker is the marker to continue a truncated listing from.
+	// S3 only sets it when a delimiter was given; otherwise the last
+	// key listed serves as the next marker.
+	NextMarker string
+	MaxKeys    int
 	// IsTruncated is true if the results have been truncated because
 	// there are more keys and prefixes than can fit in MaxKeys.
 	// N.B. this is the opposite sense to that documented (incorrectly) in
@@ -503,13 +888,66 @@ func (b *Bucket) List(prefix, delim, marker string, max int) (result *ListResp,
 		bucket: b.Name,
 		params: params,
 	}
-	result = &ListResp{}
-	for attempt := attempts.Start(); attempt.Next(); {
-		err = b.S3.query(req, result)
-		if !shouldRetry(err) {
-			break
-		}
+	err = b.S3.retry(req, func() error {
+		result = &ListResp{}
+		return b.S3.query(req, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// The ListV2Resp type holds the results of a ListV2 bucket operation.
+type ListV2Resp struct {
+	Name       string
+	Prefix     string
+	Delimiter  string
+	StartAfter string
+	MaxKeys    int
+	KeyCount   int
+	// ContinuationToken is the token the listing was continued from,
+	// and NextContinuationToken the one to continue a truncated
+	// listing from.
+	ContinuationToken     string
+	NextContinuationToken string
+	IsTruncated           bool
+	Contents              []Key
+	CommonPrefixes        []string `xml:">Prefix"`
+}
+
+// ListV2 returns information about objects in an S3 bucket using version
+// 2 of the API, which pages through results with opaque continuation
+// tokens rather than markers.
+//
+// The prefix, delim and max parameters work as in List. The listing
+// continues from token if it is not empty, and otherwise starts after
+// the key startAfter, if not empty.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectsV2.html for details.
+func (b *Bucket) ListV2(prefix, delim, token, startAfter string, max int) (result *ListV2Resp, err error) {
+	params := map[string][]string{
+		"list-type": {"2"},
+		"prefix":    {prefix},
+		"delimiter": {delim},
+	}
+	if token != "" {
+		params["continuation-token"] = []string{token}
+	}
+	if startAfter != "" {
+		params["start-after"] = []string{startAfter}
 	}
+	if max != 0 {
+		params["max-keys"] = []string{strconv.FormatInt(int64(max), 10)}
+	}
+	req := &request{
+		bucket: b.Name,
+		params: params,
+	}
+	err = b.S3.retry(req, func() error {
+		result = &ListV2Resp{}
+		return b.S3.query(req, result)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -522,11 +960,16 @@ type VersionsResp struct {
 	Prefix          string
 	KeyMarker       string
 	VersionIdMarker string
-	MaxKeys         int
-	Delimiter       string
-	IsTruncated     bool
-	Versions        []Version
-	CommonPrefixes  []string `xml:">Prefix"`
+	// NextKeyMarker and NextVersionIdMarker are the markers to continue
+	// a truncated listing from.
+	NextKeyMarker       string
+	NextVersionIdMarker string
+	MaxKeys             int
+	Delimiter           string
+	IsTruncated         bool
+	Versions            []Version      `xml:"Version"`
+	DeleteMarkers       []DeleteMarker `xml:"DeleteMarker"`
+	CommonPrefixes      []string       `xml:">Prefix"`
 }
 
 // The Version type represents an object version stored in an S3 bucket.
@@ -543,6 +986,22 @@ type Version struct {
 	StorageClass string
 }
 
+// A DeleteMarker is the version an object gets when it is deleted from a
+// versioned bucket without naming a version. It has no content, and hides
+// the earlier versions of the object while it is the latest.
+type DeleteMarker struct {
+	Key          string
+	VersionId    string
+	IsLatest     bool
+	LastModified string
+	Owner        Owner
+}
+
+// Versions returns information about the versions of objects in an S3
+// bucket. It works like List, except that listing starts after the
+// version versionIdMarker of the key keyMarker.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/API_ListObjectVersions.html for details.
 func (b *Bucket) Versions(prefix, delim, keyMarker string, versionIdMarker string, max int) (result *VersionsResp, err error) {
 	params := map[string][]string{
 		"versions":  {""},
@@ -564,13 +1023,10 @@ func (b *Bucket) Versions(prefix, delim, keyMarker string, versionIdMarker strin
 		bucket: b.Name,
 		params: params,
 	}
-	result = &VersionsResp{}
-	for attempt := attempts.Start(); attempt.Next(); {
-		err = b.S3.query(req, result)
-		if !shouldRetry(err) {
-			break
-		}
-	}
+	err = b.S3.retry(req, func() error {
+		result = &VersionsResp{}
+		return b.S3.query(req, result)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -599,6 +1055,9 @@ func (b *Bucket) URL(path string) string {
 
 // SignedURL returns a signed URL that allows anyone holding the URL
 // to retrieve the object at path. The signature is valid until expires.
+//
+// SignedURL uses signature version 2, which regions opened since 2014
+// reject; PresignedGetURL works in every region.
 func (b *Bucket) SignedURL(path string, expires time.Time) string {
 	return b.SignedURLWithParams(path, expires, url.Values{})
 }
@@ -613,6 +1072,7 @@ func (b *Bucket) SignedURLWithParams(path string, expires time.Time, params url.
 		bucket: b.Name,
 		path:   path,
 		params: params,
+		v2:     true,
 	}
 	err := b.S3.prepare(req)
 	if err != nil {
@@ -622,18 +1082,76 @@ func (b *Bucket) SignedURLWithParams(path string, expires time.Time, params url.
 	if err != nil {
 		panic(err)
 	}
-	if b.S3.Auth.Token() != "" {
-		return u.String() + "&x-amz-security-token=" + url.QueryEscape(req.headers["X-Amz-Security-Token"][0])
+	if token, ok := req.headers["X-Amz-Security-Token"]; ok {
+		return u.String() + "&x-amz-security-token=" + url.QueryEscape(token[0])
 	}
 	return u.String()
 }
 
+// PresignedURL returns a URL that allows anyone holding it to send a
+// request with the given method for the object at path, signed with
+// signature version 4 and valid for expires (at most a week). The params
+// are added to the URL and the headers must be sent along with it.
+//
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-query-string-auth.html for details.
+func (b *Bucket) PresignedURL(method, path string, expires time.Duration, params url.Values, headers http.Header) (string, error) {
+	if b.Region.Name == "" {
+		return "", errors.New("cannot presign S3 URL: region has no name")
+	}
+	req := &request{
+		method:  method,
+		bucket:  b.Name,
+		path:    path,
+		params:  params,
+		headers: headers,
+	}
+	if err := b.S3.setup(req); err != nil {
+		return "", err
+	}
+	u, err := req.url()
+	if err != nil {
+		return "", err
+	}
+	hreq := &http.Request{
+		Method: req.method,
+		URL:    u,
+		Host:   u.Host,
+		Header: req.headers,
+	}
+	signer := aws.NewV4Signer(b.Auth, "s3", b.Region)
+	if err := signer.Presign(hreq, expires); err != nil {
+		return "", err
+	}
+	return hreq.URL.String(), nil
+}
+
+// PresignedGetURL returns a URL that allows anyone holding it to retrieve
+// the object at path for the duration of expires.
+func (b *Bucket) PresignedGetURL(path string, expires time.Duration) (string, error) {
+	return b.PresignedURL("GET", path, expires, nil, nil)
+}
+
+// PresignedPutURL returns a URL that allows anyone holding it to upload
+// the object at path with a PUT request for the duration of expires. The
+// request must have a Content-Type header set to contType, if not empty.
+func (b *Bucket) PresignedPutURL(path, contType string, expires time.Duration) (string, error) {
+	headers := make(http.Header)
+	if contType != "" {
+		headers.Set("Content-Type", contType)
+	}
+	return b.PresignedURL("PUT", path, expires, nil, headers)
+}
+
 // PostFormArgs returns the action and input fields needed to allow anonymous
 // uploads to a bucket within the expiration limit
+//
+// PostFormArgs only signs with signature version 2; see PostPolicy for
+// more conditions and version 4.
 func (b *Bucket) PostFormArgs(path string, expires time.Time, redirect string) (action string, fields map[string]string) {
+	auth, _ := b.Auth.Current()
 	conditions := make([]string, 0)
 	fields = map[string]string{
-		"AWSAccessKeyId": b.Auth.AccessKey,
+		"AWSAccessKeyId": auth.AccessKey,
 		"key":            path,
 	}
 
@@ -650,11 +1168,12 @@ func (b *Bucket) PostFormArgs(path string, expires time.Time, redirect string) (
 	policy64 := base64.StdEncoding.EncodeToString([]byte(policy))
 	fields["policy"] = policy64
 
-	signer := hmac.New(sha1.New, []byte(b.Auth.SecretKey))
+	signer := hmac.New(sha1.New, []byte(auth.SecretKey))
 	signer.Write([]byte(policy64))
 	fields["signature"] = base64.StdEncoding.EncodeToString(signer.Sum(nil))
 
-	action = fmt.Sprintf("%s/%s/", b.S3.Region.S3Endpoint, b.Name)
+	endpoint, _ := b.S3.config().Endpoint("s3", b.S3.Region)
+	action = fmt.Sprintf("%s/%s/", endpoint, b.Name)
 	return
 }
 
@@ -665,8 +1184,13 @@ type request struct {
 	params   url.Values
 	headers  http.Header
 	baseurl  string
-	payload  io.Reader
+	signpath string
+	length   int64     // of the payload, or -1 if unknown
+	payload  io.Reader // as given
+	body     io.Reader // as sent, once signed
 	prepared bool
+	hooks    *aws.Request
+	v2       bool // signed with version 2 whatever the region.
 }
 
 func (req *request) url() (*url.URL, error) {
@@ -683,6 +1207,7 @@ func (req *request) url() (*url.URL, error) {
 // If resp is not nil, the XML data contained in the response
 // body will be unmarshalled on it.
 func (s3 *S3) query(req *request, resp interface{}) error {
+	s3.hooks(req)
 	err := s3.prepare(req)
 	if err != nil {
 		return err
@@ -696,9 +1221,167 @@ func (s3 *S3) query(req *request, resp interface{}) error {
 
 // prepare sets up req to be delivered to S3.
 func (s3 *S3) prepare(req *request) error {
-	var signpath = req.path
+	if err := s3.setup(req); err != nil {
+		return err
+	}
 
+	// Always sign again as it's not clear how far the
+	// server has handled a previous attempt.
+	u, err := url.Parse(req.baseurl)
+	if err != nil {
+		return fmt.Errorf("bad S3 endpoint URL %q: %v", req.baseurl, err)
+	}
+	auth, err := s3.Auth.Current()
+	if err != nil {
+		return err
+	}
+	reqSignpathSpaceFix := (&url.URL{Path: req.signpath}).String()
+	v4 := s3.v4() && !req.v2
+	req.body = req.payload
+	req.headers["Host"] = []string{u.Host}
+	if req.length >= 0 {
+		req.headers["Content-Length"] = []string{strconv.FormatInt(req.length, 10)}
+	}
+	if v4 {
+		// Drop what signing a previous attempt added.
+		for _, h := range []string{"Authorization", "Date", "X-Amz-Date", "X-Amz-Content-Sha256", "X-Amz-Decoded-Content-Length", "X-Amz-Security-Token"} {
+			delete(req.headers, h)
+		}
+		if enc := req.headers["Content-Encoding"]; len(enc) > 0 && strings.HasPrefix(enc[0], "aws-chunked") {
+			if enc[0] = strings.TrimPrefix(strings.TrimPrefix(enc[0], "aws-chunked"), ","); enc[0] == "" {
+				delete(req.headers, "Content-Encoding")
+			}
+		}
+		req.headers["X-Amz-Date"] = []string{time.Now().UTC().Format(aws.ISO8601BasicFormat)}
+	} else {
+		req.headers["Date"] = []string{time.Now().In(time.UTC).Format(time.RFC1123)}
+		if token := auth.Token(); token != "" {
+			req.headers["X-Amz-Security-Token"] = []string{token}
+		}
+	}
+	if req.hooks != nil {
+		// Let the build handlers see the request before it's signed.
+		u, err := req.url()
+		if err != nil {
+			return err
+		}
+		hreq := &http.Request{
+			URL:        u,
+			Method:     req.method,
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     req.headers,
+			Host:       u.Host,
+		}
+		if err := req.hooks.Build(hreq); err != nil {
+			return err
+		}
+		req.params = hreq.URL.Query()
+		req.headers = hreq.Header
+	}
+	if v4 {
+		return s3.signV4(req)
+	}
+	sign(auth, req.method, reqSignpathSpaceFix, req.params, req.headers)
+	return nil
+}
+
+// Payloads larger than streamingThreshold, or that can't be read twice,
+// are sent in signed chunks of streamingChunkSize bytes when signing with
+// signature version 4, rather than being hashed before being sent.
+var (
+	streamingThreshold int64 = 1 << 20
+	streamingChunkSize       = 64 << 10
+)
+
+// signV4 signs req with signature version 4.
+func (s3 *S3) signV4(req *request) error {
+	u, err := req.url()
+	if err != nil {
+		return err
+	}
+	hreq := &http.Request{
+		URL:    u,
+		Method: req.method,
+		Header: req.headers,
+		Host:   u.Host,
+	}
+	signer := aws.NewV4Signer(s3.Auth, "s3", s3.Region)
+	seeker, seekable := req.payload.(io.ReadSeeker)
+	switch {
+	case req.payload == nil:
+		req.headers["X-Amz-Content-Sha256"] = []string{emptyPayloadHash}
+	case req.length >= 0 && (!seekable || req.length > streamingThreshold):
+		hreq.Body = ioutil.NopCloser(req.payload)
+		signer.SignStreaming(hreq, req.length, streamingChunkSize)
+		req.body = hreq.Body
+		return nil
+	case seekable:
+		hash, err := payloadHash(seeker)
+		if err != nil {
+			return err
+		}
+		req.headers["X-Amz-Content-Sha256"] = []string{hash}
+	default:
+		// Without a length the payload can only be sent unsigned.
+		req.headers["X-Amz-Content-Sha256"] = []string{"UNSIGNED-PAYLOAD"}
+	}
+	signer.Sign(hreq)
+	return nil
+}
+
+var emptyPayloadHash = fmt.Sprintf("%x", sha256.Sum256(nil))
+
+// payloadHash returns the hex encoded SHA256 hash of the rest of r,
+// leaving r where it was.
+func payloadHash(r io.ReadSeeker) (string, error) {
+	pos, err := r.Seek(0, 1)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	if _, err := r.Seek(pos, 0); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// The regions that predate signature version 4, and are still signed
+// with version 2 unless S3.SignatureVersion says otherwise.
+var v2Regions = map[string]bool{
+	"us-east-1":      true,
+	"us-west-1":      true,
+	"us-west-2":      true,
+	"us-gov-west-1":  true,
+	"eu-west-1":      true,
+	"ap-southeast-1": true,
+	"ap-southeast-2": true,
+	"ap-northeast-1": true,
+	"sa-east-1":      true,
+}
+
+// v4 reports whether requests are signed with signature version 4.
+func (s3 *S3) v4() bool {
+	switch s3.SignatureVersion {
+	case 2:
+		return false
+	case 4:
+		return true
+	}
+	return s3.Region.Name != "" && !v2Regions[s3.Region.Name]
+}
+
+// setup fills in the defaults of req and works out where it is to be
+// sent, the first time it's called for req.
+func (s3 *S3) setup(req *request) error {
 	if !req.prepared {
+		endpoint, err := s3.config().Endpoint("s3", s3.Region)
+		if err != nil {
+			return err
+		}
 		req.prepared = true
 		if req.method == "" {
 			req.method = "GET"
@@ -714,15 +1397,19 @@ func (s3 *S3) prepare(req *request) error {
 		}
 		req.params = params
 		req.headers = headers
+		req.length = -1
+		if v, ok := headers["Content-Length"]; ok {
+			req.length, _ = strconv.ParseInt(v[0], 10, 64)
+		}
 		if !strings.HasPrefix(req.path, "/") {
 			req.path = "/" + req.path
 		}
-		signpath = req.path
+		req.signpath = req.path
 		if req.bucket != "" {
 			req.baseurl = s3.Region.S3BucketEndpoint
 			if req.baseurl == "" {
 				// Use the path method to address the bucket.
-				req.baseurl = s3.Region.S3Endpoint
+				req.baseurl = endpoint
 				req.path = "/" + req.bucket + req.path
 			} else {
 				// Just in case, prevent injection.
@@ -731,23 +1418,9 @@ func (s3 *S3) prepare(req *request) error {
 				}
 				req.baseurl = strings.Replace(req.baseurl, "${bucket}", req.bucket, -1)
 			}
-			signpath = "/" + req.bucket + signpath
+			req.signpath = "/" + req.bucket + req.signpath
 		}
 	}
-
-	// Always sign again as it's not clear how far the
-	// server has handled a previous attempt.
-	u, err := url.Parse(req.baseurl)
-	if err != nil {
-		return fmt.Errorf("bad S3 endpoint URL %q: %v", req.baseurl, err)
-	}
-	reqSignpathSpaceFix := (&url.URL{Path: signpath}).String()
-	req.headers["Host"] = []string{u.Host}
-	req.headers["Date"] = []string{time.Now().In(time.UTC).Format(time.RFC1123)}
-	if s3.Auth.Token() != "" {
-		req.headers["X-Amz-Security-Token"] = []string{s3.Auth.Token()}
-	}
-	sign(s3.Auth, req.method, reqSignpathSpaceFix, req.params, req.headers)
 	return nil
 }
 
@@ -769,39 +1442,24 @@ func (s3 *S3) run(req *request, resp interface{}) (*http.Response, error) {
 		Method:     req.method,
 		ProtoMajor: 1,
 		ProtoMinor: 1,
-		Close:      true,
 		Header:     req.headers,
 	}
 
 	if v, ok := req.headers["Content-Length"]; ok {
 		hreq.ContentLength, _ = strconv.ParseInt(v[0], 10, 64)
-		delete(req.headers, "Content-Length")
 	}
-	if req.payload != nil {
-		hreq.Body = ioutil.NopCloser(req.payload)
+	// An empty body would be sent chunked, without the Content-Length
+	// that was signed.
+	if req.body != nil && (hreq.ContentLength != 0 || req.length < 0) {
+		hreq.Body = ioutil.NopCloser(req.body)
 	}
 
-	c := http.Client{
-		Transport: &http.Transport{
-			Dial: func(netw, addr string) (c net.Conn, err error) {
-				deadline := time.Now().Add(s3.ReadTimeout)
-				if s3.ConnectTimeout > 0 {
-					c, err = net.DialTimeout(netw, addr, s3.ConnectTimeout)
-				} else {
-					c, err = net.Dial(netw, addr)
-				}
-				if err != nil {
-					return
-				}
-				if s3.ReadTimeout > 0 {
-					err = c.SetDeadline(deadline)
-				}
-				return
-			},
-		},
+	hooks := s3.hooks(req)
+	hooks.HTTPRequest = &hreq
+	if err := hooks.Sign(); err != nil {
+		return nil, err
 	}
-
-	hresp, err := c.Do(&hreq)
+	hresp, err := hooks.Send()
 	if err != nil {
 		return nil, err
 	}
@@ -809,8 +1467,8 @@ func (s3 *S3) run(req *request, resp interface{}) (*http.Response, error) {
 		dump, _ := httputil.DumpResponse(hresp, true)
 		log.Printf("} -> %s\n", dump)
 	}
-	if hresp.StatusCode != 200 && hresp.StatusCode != 204 {
-		return nil, buildError(hresp)
+	if hresp.StatusCode != 200 && hresp.StatusCode != 204 && hresp.StatusCode != 206 {
+		return nil, hooks.Unmarshal(resp, buildError(hresp))
 	}
 	if resp != nil {
 		err = xml.NewDecoder(hresp.Body).Decode(resp)
@@ -821,7 +1479,35 @@ func (s3 *S3) run(req *request, resp interface{}) (*http.Response, error) {
 		}
 
 	}
-	return hresp, err
+	return hresp, hooks.Unmarshal(resp, err)
+}
+
+// hooks returns the aws.Request through which req runs the handlers of
+// s3's Config, creating it if needed.
+func (s3 *S3) hooks(req *request) *aws.Request {
+	if req.hooks == nil {
+		method := req.method
+		if method == "" {
+			method = "GET"
+		}
+		req.hooks = aws.NewRequest(s3.ctx, s3.config(), "s3", method)
+		req.hooks.Retryer = s3.retryer()
+	}
+	return req.hooks
+}
+
+// config returns the HTTP settings used to send requests to S3.
+func (s3 *S3) config() *aws.Config {
+	if s3.Config == nil && (s3.ConnectTimeout > 0 || s3.ReadTimeout > 0) {
+		s3.timeoutsOnce.Do(func() {
+			s3.timeoutsConfig = &aws.Config{
+				ConnectTimeout: s3.ConnectTimeout,
+				RequestTimeout: s3.ReadTimeout,
+			}
+		})
+		return s3.timeoutsConfig
+	}
+	return s3.Config
 }
 
 // Error represents an error in an operation with S3.
@@ -838,6 +1524,39 @@ func (e *Error) Error() string {
 	return e.Message
 }
 
+// errorCodes holds the error codes S3 uses for throttled requests and
+// transient failures, beyond those all services share.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/ErrorResponses.html
+var errorCodes = aws.ErrorCodes{
+	Throttle:  []string{"SlowDown"},
+	Transient: []string{"RequestTimeout"},
+}
+
+// Retryable reports whether the failed request may be sent again.
+func (e *Error) Retryable() bool {
+	switch e.Code {
+	case "NoSuchUpload", "NoSuchBucket":
+		// S3 is eventually consistent, so these may be seen
+		// shortly after creating an upload or bucket.
+		return true
+	}
+	return errorCodes.Retryable(e.StatusCode, e.Code)
+}
+
+// Throttled reports whether the request was rejected for exceeding a rate
+// limit.
+func (e *Error) Throttled() bool {
+	return errorCodes.Throttled(e.StatusCode, e.Code)
+}
+
+// The following methods implement aws.APIError.
+
+func (e *Error) Service() string      { return "s3" }
+func (e *Error) HTTPStatus() int      { return e.StatusCode }
+func (e *Error) ErrorCode() string    { return e.Code }
+func (e *Error) ErrorMessage() string { return e.Message }
+func (e *Error) RequestID() string    { return e.RequestId }
+
 func buildError(r *http.Response) error {
 	if debug {
 		log.Printf("got error (status code %v)", r.StatusCode)
@@ -858,35 +1577,30 @@ func buildError(r *http.Response) error {
 	if err.Message == "" {
 		err.Message = r.Status
 	}
+	if err.RequestId == "" {
+		// Responses to HEAD requests have no body.
+		err.RequestId = r.Header.Get("X-Amz-Request-Id")
+	}
 	if debug {
 		log.Printf("err: %#v\n", err)
 	}
 	return &err
 }
 
-func shouldRetry(err error) bool {
-	if err == nil {
-		return false
-	}
-	switch err {
-	case io.ErrUnexpectedEOF, io.EOF:
-		return true
-	}
-	switch e := err.(type) {
-	case *net.DNSError:
-		return true
-	case *net.OpError:
-		switch e.Op {
-		case "read", "write":
-			return true
-		}
-	case *Error:
-		switch e.Code {
-		case "InternalError", "NoSuchUpload", "NoSuchBucket":
-			return true
-		}
+// retry calls f, which should send req once, retrying it as dictated by
+// the Retryer in s3's Config, or by the package's S3-specific policy if
+// there is none.
+func (s3 *S3) retry(req *request, f func() error) error {
+	return s3.hooks(req).Retry(f)
+}
+
+func (s3 *S3) retryer() aws.Retryer {
+	if c := s3.config(); c != nil && c.Retryer != nil {
+		return c.Retryer
+	} else if aws.DefaultConfig.Retryer != nil {
+		return aws.DefaultConfig.Retryer
 	}
-	return false
+	return retryer
 }
 
 func hasCode(err error, code string) bool {
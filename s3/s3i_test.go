@@ -132,6 +132,17 @@ func killBucket(b *s3.Bucket) {
 			for _, m := range multis {
 				_ = m.Abort()
 			}
+			versions, err := b.Versions("", "", "", "", 1000)
+			if err == nil {
+				var ids []s3.ObjectId
+				for _, v := range versions.Versions {
+					ids = append(ids, s3.ObjectId{Key: v.Key, VersionId: v.VersionId})
+				}
+				for _, m := range versions.DeleteMarkers {
+					ids = append(ids, s3.ObjectId{Key: m.Key, VersionId: m.VersionId})
+				}
+				_, _ = b.DelMulti(ids, true)
+			}
 		}
 	}
 	message := "cannot delete test bucket"
@@ -220,6 +231,27 @@ func (s *ClientTests) TestBasicFunctionality(c *gocheck.C) {
 	c.Assert(err, gocheck.IsNil)
 }
 
+func (s *ClientTests) TestDownloader(c *gocheck.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, gocheck.IsNil)
+
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	err = b.Put("name", data, "application/octet-stream", s3.Private, s3.Options{})
+	c.Assert(err, gocheck.IsNil)
+	defer b.Del("name")
+
+	d := &s3.Downloader{Bucket: b, PartSize: 300, Concurrency: 3}
+	var w bufferAt
+	n, err := d.Download(&w, "name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, int64(len(data)))
+	c.Assert(w.buf, gocheck.DeepEquals, data)
+}
+
 func (s *ClientTests) TestGetNotFound(c *gocheck.C) {
 	b := s.s3.Bucket("goamz-" + s.s3.Auth.AccessKey)
 	data, err := b.Get("non-existent")
@@ -388,6 +420,494 @@ func (s *ClientTests) TestBucketList(c *gocheck.C) {
 	}
 }
 
+var listIteratorTests = []struct {
+	prefix, delim, startAfter string
+	expect                    []string
+}{
+	{"", "", "", objectNames},
+	{"", "", "photos/", objectNames[2:]},
+	{"", "/", "", []string{"index.html", "index2.html", "photos/", "test/"}},
+	{"", "/", "index2.html", []string{"photos/", "test/"}},
+	{"photos/2006/", "/", "", []string{"photos/2006/February/", "photos/2006/January/"}},
+	{"test/", "/", "", []string{"test/bar", "test/foo"}},
+}
+
+func (s *ClientTests) TestListIterator(c *gocheck.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, gocheck.IsNil)
+
+	for _, path := range objectNames {
+		err := b.Put(path, []byte(path), "text/plain", s3.Private, s3.Options{})
+		c.Assert(err, gocheck.IsNil)
+		defer b.Del(path)
+	}
+
+	for _, v2 := range []bool{false, true} {
+		for _, pageSize := range []int{0, 1, 3} {
+			for i, t := range listIteratorTests {
+				c.Logf("test %d, v2 %v, page size %d", i, v2, pageSize)
+				it := s3.NewListIterator(b, t.prefix, t.delim)
+				it.V2, it.PageSize, it.StartAfter = v2, pageSize, t.startAfter
+				var got []string
+				for it.Next() {
+					if key := it.Key(); key != nil {
+						c.Check(key.Size, gocheck.Equals, int64(len(key.Key)))
+						got = append(got, key.Key)
+					} else {
+						got = append(got, it.CommonPrefix())
+					}
+				}
+				c.Assert(it.Err(), gocheck.IsNil)
+				c.Check(got, gocheck.DeepEquals, t.expect)
+			}
+		}
+	}
+}
+
+func (s *ClientTests) TestDelMulti(c *gocheck.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, gocheck.IsNil)
+
+	for _, path := range objectNames {
+		err := b.Put(path, []byte(path), "text/plain", s3.Private, s3.Options{})
+		c.Assert(err, gocheck.IsNil)
+	}
+
+	resp, err := b.DelMulti([]s3.ObjectId{{Key: "index.html"}, {Key: "index2.html"}}, false)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(resp.Errors, gocheck.HasLen, 0)
+	c.Assert(resp.Deleted, gocheck.HasLen, 2)
+
+	s3.SetDelMultiMax(2)
+	defer s3.SetDelMultiMax(1000)
+	n, err := b.DelPrefix("photos/")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 4)
+
+	list, err := b.List("", "", "", 0)
+	c.Assert(err, gocheck.IsNil)
+	checkContents(c, list.Contents, map[string][]byte{
+		"test/bar": []byte("test/bar"),
+		"test/foo": []byte("test/foo"),
+	}, keys("test/bar", "test/foo"))
+
+	n, err = b.DelPrefix("")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(n, gocheck.Equals, 2)
+}
+
+func (s *ClientTests) TestBucketConfig(c *gocheck.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, gocheck.IsNil)
+
+	// Lifecycle.
+	_, err = b.GetBucketLifecycle()
+	c.Assert(err, gocheck.ErrorMatches, ".*lifecycle configuration does not exist")
+	lifecycle := &s3.LifecycleConfiguration{
+		Rules: []s3.LifecycleRule{{
+			ID:         "logs",
+			Prefix:     "logs/",
+			Status:     s3.RuleEnabled,
+			Expiration: &s3.LifecycleExpiration{Days: 365},
+			Transitions: []s3.LifecycleTransition{
+				{Days: 30, StorageClass: "STANDARD_IA"},
+				{Days: 90, StorageClass: "GLACIER"},
+			},
+		}, {
+			ID:                          "versions",
+			Status:                      s3.RuleDisabled,
+			NoncurrentVersionExpiration: &s3.NoncurrentVersionExpiration{NoncurrentDays: 7},
+			NoncurrentVersionTransitions: []s3.NoncurrentVersionTransition{
+				{NoncurrentDays: 1, StorageClass: "STANDARD_IA"},
+			},
+		}},
+	}
+	err = b.PutBucketLifecycle(lifecycle)
+	c.Assert(err, gocheck.IsNil)
+	gotLifecycle, err := b.GetBucketLifecycle()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(gotLifecycle.Rules, gocheck.DeepEquals, lifecycle.Rules)
+	err = b.DelBucketLifecycle()
+	c.Assert(err, gocheck.IsNil)
+	_, err = b.GetBucketLifecycle()
+	c.Assert(err, gocheck.NotNil)
+
+	// CORS.
+	cors := &s3.CORSConfiguration{
+		Rules: []s3.CORSRule{{
+			AllowedOrigins: []string{"http://www.example.com"},
+			AllowedMethods: []string{"GET", "PUT"},
+			AllowedHeaders: []string{"*"},
+			ExposeHeaders:  []string{"x-amz-request-id"},
+			MaxAgeSeconds:  3000,
+		}},
+	}
+	err = b.PutBucketCORS(cors)
+	c.Assert(err, gocheck.IsNil)
+	gotCORS, err := b.GetBucketCORS()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(gotCORS.Rules, gocheck.DeepEquals, cors.Rules)
+	err = b.DelBucketCORS()
+	c.Assert(err, gocheck.IsNil)
+	_, err = b.GetBucketCORS()
+	c.Assert(err, gocheck.ErrorMatches, ".*CORS configuration does not exist")
+
+	// Policy.
+	policy := `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":"*",` +
+		`"Action":"s3:DeleteObject","Resource":"arn:aws:s3:::` + b.Name + `/*"}]}`
+	err = b.PutBucketPolicy([]byte(policy))
+	c.Assert(err, gocheck.IsNil)
+	gotPolicy, err := b.GetBucketPolicy()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(gotPolicy), gocheck.Equals, policy)
+	err = b.DelBucketPolicy()
+	c.Assert(err, gocheck.IsNil)
+	_, err = b.GetBucketPolicy()
+	c.Assert(err, gocheck.ErrorMatches, ".*bucket policy does not exist")
+
+	// Tagging.
+	tags := []s3.Tag{{Key: "team", Value: "infra"}, {Key: "env", Value: "test"}}
+	err = b.PutBucketTagging(tags)
+	c.Assert(err, gocheck.IsNil)
+	gotTags, err := b.GetBucketTagging()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(gotTags, gocheck.DeepEquals, tags)
+	err = b.DelBucketTagging()
+	c.Assert(err, gocheck.IsNil)
+	_, err = b.GetBucketTagging()
+	c.Assert(err, gocheck.ErrorMatches, ".*TagSet does not exist")
+
+	// Versioning.
+	status, err := b.GetBucketVersioning()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(status, gocheck.Equals, "")
+	err = b.PutBucketVersioning(s3.VersioningEnabled)
+	c.Assert(err, gocheck.IsNil)
+	status, err = b.GetBucketVersioning()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(status, gocheck.Equals, s3.VersioningEnabled)
+	err = b.PutBucketVersioning(s3.VersioningSuspended)
+	c.Assert(err, gocheck.IsNil)
+	status, err = b.GetBucketVersioning()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(status, gocheck.Equals, s3.VersioningSuspended)
+}
+
+func (s *ClientTests) TestACL(c *gocheck.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.PublicRead)
+	c.Assert(err, gocheck.IsNil)
+
+	policy, err := b.GetBucketACL()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(policy.Owner.ID, gocheck.Not(gocheck.Equals), "")
+	c.Assert(policy.Grants, gocheck.HasLen, 2)
+	c.Assert(policy.Grants[1], gocheck.DeepEquals, s3.Grant{Grantee: s3.Group(s3.AllUsersGroup), Permission: s3.PermRead})
+
+	policy.RemoveGrant(s3.Group(s3.AllUsersGroup), "")
+	policy.AddGrant(s3.Group(s3.LogDeliveryGroup), s3.PermWrite)
+	err = b.PutBucketACL(policy)
+	c.Assert(err, gocheck.IsNil)
+	gotPolicy, err := b.GetBucketACL()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(gotPolicy, gocheck.DeepEquals, policy)
+
+	err = b.Put("name", []byte("content"), "text/plain", s3.AuthenticatedRead, s3.Options{})
+	c.Assert(err, gocheck.IsNil)
+	defer b.Del("name")
+	policy, err = b.GetACL("name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(policy.Grants, gocheck.HasLen, 2)
+	c.Assert(policy.Grants[1], gocheck.DeepEquals, s3.Grant{Grantee: s3.Group(s3.AuthenticatedUsersGroup), Permission: s3.PermRead})
+
+	policy.RemoveGrant(s3.Group(s3.AuthenticatedUsersGroup), s3.PermRead)
+	policy.AddGrant(s3.Group(s3.AllUsersGroup), s3.PermRead)
+	err = b.PutACL("name", policy)
+	c.Assert(err, gocheck.IsNil)
+	gotPolicy, err = b.GetACL("name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(gotPolicy, gocheck.DeepEquals, policy)
+
+	_, err = b.GetACL("missing")
+	c.Assert(err, gocheck.ErrorMatches, ".*key does not exist.*")
+}
+
+func (s *ClientTests) TestObjectOptions(c *gocheck.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, gocheck.IsNil)
+
+	options := s3.Options{
+		Meta:               map[string][]string{"name": {"value"}},
+		ContentDisposition: "attachment",
+		CacheControl:       "no-cache",
+		Expires:            time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		StorageClass:       s3.StandardIAStorage,
+		Tags:               []s3.Tag{{Key: "team", Value: "infra"}},
+	}
+	err = b.Put("name", []byte("content"), "text/plain", s3.Private, options)
+	c.Assert(err, gocheck.IsNil)
+	defer b.Del("name")
+
+	info, err := b.Stat("name", s3.GetOptions{})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(info.Size, gocheck.Equals, int64(7))
+	c.Assert(info.ETag, gocheck.Equals, etag([]byte("content")))
+	c.Assert(info.LastModified.IsZero(), gocheck.Equals, false)
+	c.Assert(info.ContentType, gocheck.Equals, "text/plain")
+	c.Assert(info.ContentDisposition, gocheck.Equals, "attachment")
+	c.Assert(info.CacheControl, gocheck.Equals, "no-cache")
+	c.Assert(info.Expires.Equal(options.Expires), gocheck.Equals, true)
+	c.Assert(info.StorageClass, gocheck.Equals, s3.StandardIAStorage)
+	c.Assert(info.TagCount, gocheck.Equals, 1)
+	c.Assert(info.Meta, gocheck.DeepEquals, map[string][]string{"name": {"value"}})
+
+	// Conditions.
+	_, err = b.Stat("name", s3.GetOptions{IfMatch: info.ETag})
+	c.Assert(err, gocheck.IsNil)
+	_, err = b.Stat("name", s3.GetOptions{IfMatch: `"other"`})
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.(*s3.Error).StatusCode, gocheck.Equals, 412)
+	_, err = b.Stat("name", s3.GetOptions{IfUnmodifiedSince: info.LastModified.Add(-time.Hour)})
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.(*s3.Error).StatusCode, gocheck.Equals, 412)
+	_, err = b.GetResponseWithOptions("name", s3.GetOptions{IfNoneMatch: info.ETag})
+	c.Assert(s3.IsNotModified(err), gocheck.Equals, true)
+	_, err = b.GetResponseWithOptions("name", s3.GetOptions{IfModifiedSince: info.LastModified})
+	c.Assert(s3.IsNotModified(err), gocheck.Equals, true)
+	resp, err := b.GetResponseWithOptions("name", s3.GetOptions{IfModifiedSince: info.LastModified.Add(-time.Hour)})
+	c.Assert(err, gocheck.IsNil)
+	resp.Body.Close()
+
+	// Customer-provided encryption keys.
+	key := []byte("0123456789abcdef0123456789abcdef")
+	err = b.Put("secret", []byte("content"), "text/plain", s3.Private, s3.Options{SSECustomerKey: key})
+	c.Assert(err, gocheck.IsNil)
+	defer b.Del("secret")
+	_, err = b.GetResponseWithOptions("secret", s3.GetOptions{})
+	c.Assert(err, gocheck.NotNil)
+	resp, err = b.GetResponseWithOptions("secret", s3.GetOptions{SSECustomerKey: key})
+	c.Assert(err, gocheck.IsNil)
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Equals, "content")
+}
+
+func (s *ClientTests) TestVersions(c *gocheck.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, gocheck.IsNil)
+	err = b.PutBucketVersioning(s3.VersioningEnabled)
+	c.Assert(err, gocheck.IsNil)
+
+	for _, content := range []string{"one", "two"} {
+		err := b.Put("name", []byte(content), "text/plain", s3.Private, s3.Options{})
+		c.Assert(err, gocheck.IsNil)
+	}
+	err = b.Put("other", []byte("other"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, gocheck.IsNil)
+	info, err := b.Stat("name", s3.GetOptions{})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(info.VersionId, gocheck.Not(gocheck.Equals), "")
+
+	// Deleting the object hides its versions behind a delete marker.
+	err = b.Del("name")
+	c.Assert(err, gocheck.IsNil)
+	_, err = b.Get("name")
+	c.Assert(err, gocheck.ErrorMatches, ".*key does not exist.*")
+
+	resp, err := b.Versions("", "", "", "", 0)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(resp.IsTruncated, gocheck.Equals, false)
+	c.Assert(resp.DeleteMarkers, gocheck.HasLen, 1)
+	marker := resp.DeleteMarkers[0]
+	c.Assert(marker.Key, gocheck.Equals, "name")
+	c.Assert(marker.IsLatest, gocheck.Equals, true)
+	c.Assert(resp.Versions, gocheck.HasLen, 3)
+	for i, expect := range []struct {
+		key, content string
+		latest       bool
+	}{
+		{"name", "two", false},
+		{"name", "one", false},
+		{"other", "other", true},
+	} {
+		v := resp.Versions[i]
+		c.Check(v.Key, gocheck.Equals, expect.key)
+		c.Check(v.ETag, gocheck.Equals, etag([]byte(expect.content)))
+		c.Check(v.Size, gocheck.Equals, int64(len(expect.content)))
+		c.Check(v.IsLatest, gocheck.Equals, expect.latest)
+	}
+	c.Assert(resp.Versions[0].VersionId, gocheck.Equals, info.VersionId)
+
+	// Earlier versions can still be copied.
+	_, err = b.Copy("restored", b.Name+"/name", s3.Private, s3.CopyOptions{SourceVersionId: resp.Versions[1].VersionId})
+	c.Assert(err, gocheck.IsNil)
+	data, err := b.Get("restored")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Equals, "one")
+
+	// Deleting the delete marker brings the latest version back.
+	dresp, err := b.DelMulti([]s3.ObjectId{{Key: "name", VersionId: marker.VersionId}}, false)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(dresp.Deleted, gocheck.DeepEquals, []s3.DeletedObject{{
+		Key:                   "name",
+		VersionId:             marker.VersionId,
+		DeleteMarker:          true,
+		DeleteMarkerVersionId: marker.VersionId,
+	}})
+	data, err = b.Get("name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Equals, "two")
+
+	// Listing in pages.
+	resp, err = b.Versions("", "", "", "", 2)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(resp.IsTruncated, gocheck.Equals, true)
+	c.Assert(resp.Versions, gocheck.HasLen, 2)
+	c.Assert(resp.NextKeyMarker, gocheck.Equals, "name")
+	c.Assert(resp.NextVersionIdMarker, gocheck.Equals, resp.Versions[1].VersionId)
+	resp, err = b.Versions("", "", resp.NextKeyMarker, resp.NextVersionIdMarker, 2)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(resp.IsTruncated, gocheck.Equals, false)
+	c.Assert(resp.Versions, gocheck.HasLen, 2)
+	c.Assert(resp.Versions[0].Key, gocheck.Equals, "other")
+	c.Assert(resp.Versions[1].Key, gocheck.Equals, "restored")
+}
+
+func (s *ClientTests) TestVersionIterator(c *gocheck.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, gocheck.IsNil)
+	err = b.PutBucketVersioning(s3.VersioningEnabled)
+	c.Assert(err, gocheck.IsNil)
+
+	for _, key := range []string{"a", "a", "b", "c"} {
+		err := b.Put(key, []byte("content"), "text/plain", s3.Private, s3.Options{})
+		c.Assert(err, gocheck.IsNil)
+	}
+	for _, key := range []string{"a", "b"} {
+		c.Assert(b.Del(key), gocheck.IsNil)
+	}
+
+	// Delete markers are listed along with the versions, in key order
+	// and from the newest, whatever the size of the pages.
+	for _, pageSize := range []int{0, 1, 2} {
+		it := s3.NewVersionIterator(b, "", "")
+		it.PageSize = pageSize
+		var got []string
+		for it.Next() {
+			if v := it.Version(); v != nil {
+				got = append(got, fmt.Sprintf("%s latest=%v", v.Key, v.IsLatest))
+			} else {
+				m := it.DeleteMarker()
+				c.Assert(m, gocheck.NotNil)
+				got = append(got, fmt.Sprintf("%s latest=%v deleted", m.Key, m.IsLatest))
+			}
+		}
+		c.Assert(it.Err(), gocheck.IsNil)
+		c.Assert(got, gocheck.DeepEquals, []string{
+			"a latest=true deleted",
+			"a latest=false",
+			"a latest=false",
+			"b latest=true deleted",
+			"b latest=false",
+			"c latest=true",
+		}, gocheck.Commentf("page size %d", pageSize))
+	}
+
+	// Deleting every version and delete marker listed leaves nothing
+	// behind.
+	var ids []s3.ObjectId
+	it := s3.NewVersionIterator(b, "", "")
+	for it.Next() {
+		if v := it.Version(); v != nil {
+			ids = append(ids, s3.ObjectId{Key: v.Key, VersionId: v.VersionId})
+		} else if m := it.DeleteMarker(); m != nil {
+			ids = append(ids, s3.ObjectId{Key: m.Key, VersionId: m.VersionId})
+		}
+	}
+	c.Assert(it.Err(), gocheck.IsNil)
+	_, err = b.DelMulti(ids, true)
+	c.Assert(err, gocheck.IsNil)
+	resp, err := b.Versions("", "", "", "", 0)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(resp.Versions, gocheck.HasLen, 0)
+	c.Assert(resp.DeleteMarkers, gocheck.HasLen, 0)
+	c.Assert(b.DelBucket(), gocheck.IsNil)
+}
+
+func (s *ClientTests) TestCopy(c *gocheck.C) {
+	b := testBucket(s.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, gocheck.IsNil)
+
+	meta := map[string][]string{"name": {"value"}}
+	err = b.Put("source", []byte("content"), "text/plain", s3.Private, s3.Options{Meta: meta})
+	c.Assert(err, gocheck.IsNil)
+
+	result, err := b.Copy("copy", b.Name+"/source", s3.Private, s3.CopyOptions{})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(result.ETag, gocheck.Equals, etag([]byte("content")))
+	info, err := b.Stat("copy", s3.GetOptions{})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(info.ContentType, gocheck.Equals, "text/plain")
+	c.Assert(info.Meta, gocheck.DeepEquals, meta)
+
+	_, err = b.Copy("replaced", b.Name+"/source", s3.Private, s3.CopyOptions{
+		MetadataDirective: s3.ReplaceMetadata,
+		ContentType:       "text/html",
+	})
+	c.Assert(err, gocheck.IsNil)
+	info, err = b.Stat("replaced", s3.GetOptions{})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(info.ContentType, gocheck.Equals, "text/html")
+	c.Assert(info.Meta, gocheck.HasLen, 0)
+
+	_, err = b.Copy("copy", b.Name+"/source", s3.Private, s3.CopyOptions{
+		Source: s3.GetOptions{IfMatch: `"other"`},
+	})
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.(*s3.Error).StatusCode, gocheck.Equals, 412)
+
+	// Copying an object to itself must change it.
+	_, err = b.Copy("source", b.Name+"/source", s3.Private, s3.CopyOptions{})
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.(*s3.Error).Code, gocheck.Equals, "InvalidRequest")
+
+	// Objects larger than the part size are copied in parts.
+	data := make([]byte, 5*1024*1024+3)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	err = b.Put("large", data, "application/octet-stream", s3.Private, s3.Options{Meta: meta})
+	c.Assert(err, gocheck.IsNil)
+	copier := &s3.Copier{Bucket: b, PartSize: 5 * 1024 * 1024}
+	err = copier.Copy("large-copy", b.Name+"/large", s3.Private, s3.CopyOptions{})
+	c.Assert(err, gocheck.IsNil)
+	got, err := b.Get("large-copy")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(bytes.Equal(got, data), gocheck.Equals, true)
+	info, err = b.Stat("large-copy", s3.GetOptions{})
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(info.ETag, gocheck.Matches, `"[0-9a-f]+-2"`)
+	c.Assert(info.ContentType, gocheck.Equals, "application/octet-stream")
+	c.Assert(info.Meta, gocheck.DeepEquals, meta)
+
+	err = copier.Rename("large-renamed", b.Name+"/large-copy", s3.Private, s3.CopyOptions{})
+	c.Assert(err, gocheck.IsNil)
+	got, err = b.Get("large-renamed")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(bytes.Equal(got, data), gocheck.Equals, true)
+	_, err = b.Get("large-copy")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(err.(*s3.Error).StatusCode, gocheck.Equals, 404)
+}
+
 func etag(data []byte) string {
 	sum := md5.New()
 	sum.Write(data)
@@ -0,0 +1,515 @@
+// Package s3sync synchronizes directories of the local filesystem with
+// prefixes of S3 buckets, in either direction:
+//
+//	s := s3sync.New(s3.New(auth, aws.USEast).Bucket("backups"))
+//	s.Delete = true
+//	err := s.Upload("/var/www", "www/")
+//
+// makes the keys under www/ in the backups bucket mirror the files under
+// /var/www, uploading the files that are missing or changed and deleting
+// the keys that no file matches.
+//
+// A file and a key of the same size are taken to hold the same content
+// if the MD5 sum of the file is the ETag of the key. Objects uploaded in
+// several parts have ETags that are not MD5 sums; a file and such a key
+// are taken to be the same unless the source of the sync was modified
+// after the destination.
+package s3sync
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/hailocab/goamz/s3"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultConcurrency is the number of files a Syncer transfers at the
+// same time by default.
+const DefaultConcurrency = 5
+
+// maxDelete is the number of keys deleted by each DelMulti request.
+const maxDelete = 1000
+
+// A Syncer synchronizes directories with prefixes of a bucket. The files
+// under a directory map to the keys made of the prefix followed by their
+// path relative to the directory, with slashes as separators. Only
+// regular files are synchronized; symbolic links, empty directories and
+// keys ending with a slash are ignored.
+//
+// A Syncer may be used for any number of syncs at the same time.
+type Syncer struct {
+	Bucket *s3.Bucket
+
+	// Concurrency is the number of files transferred at the same time.
+	// If zero, DefaultConcurrency is used.
+	Concurrency int
+
+	// Delete makes syncs remove the keys, or files, of the destination
+	// that no file, or key, of the source matches.
+	Delete bool
+
+	// DryRun makes syncs report the operations they would make, without
+	// making them.
+	DryRun bool
+
+	// Report, if set, is called with each operation of a sync as it is
+	// started, or instead of starting it in a dry run. It is not called
+	// by several goroutines at the same time.
+	Report func(op Op)
+
+	// Perm and Options are those keys are uploaded with. If Perm is
+	// empty, s3.Private is used.
+	Perm    s3.ACL
+	Options s3.Options
+
+	mu sync.Mutex
+}
+
+// New returns a Syncer for b with the default concurrency, that neither
+// deletes anything nor makes dry runs.
+func New(b *s3.Bucket) *Syncer {
+	return &Syncer{Bucket: b}
+}
+
+// The kinds of operations made by syncs.
+type OpKind string
+
+const (
+	OpUpload   = OpKind("upload")
+	OpDownload = OpKind("download")
+	OpDelete   = OpKind("delete")
+)
+
+// An Op is an operation made by a sync. Deletions have a Path or a Key,
+// depending on whether they remove a file or a key.
+type Op struct {
+	Kind   OpKind
+	Bucket string
+	Path   string
+	Key    string
+	Size   int64
+}
+
+func (op Op) String() string {
+	url := "s3://" + op.Bucket + "/" + op.Key
+	switch {
+	case op.Kind == OpUpload:
+		return fmt.Sprintf("upload: %s to %s", op.Path, url)
+	case op.Kind == OpDownload:
+		return fmt.Sprintf("download: %s to %s", url, op.Path)
+	case op.Path == "":
+		return fmt.Sprintf("%s: %s", op.Kind, url)
+	}
+	return fmt.Sprintf("%s: %s", op.Kind, op.Path)
+}
+
+// OpError describes the failure of an operation of a sync.
+type OpError struct {
+	Op  Op
+	Err error
+}
+
+func (e *OpError) Error() string {
+	return e.Op.String() + ": " + e.Err.Error()
+}
+
+// Errors is returned by a sync when some of its operations failed. The
+// other operations are made regardless.
+type Errors []*OpError
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", e[0].Error(), len(e)-1)
+}
+
+// err returns e sorted by operation, or nil if it is empty.
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	sort.Slice(e, func(i, j int) bool {
+		return e[i].Op.String() < e[j].Op.String()
+	})
+	return e
+}
+
+// errBadKey is the error of downloads of keys that cannot be stored in a
+// file under the directory synced, such as those holding "..".
+var errBadKey = errors.New("key does not map to a file name")
+
+// job is an operation of a sync, along with the file and key it involves,
+// if they exist. Uploads and downloads are only made if the file and the
+// key differ. If err is set, the operation fails with it.
+type job struct {
+	op   Op
+	file os.FileInfo
+	key  *s3.Key
+	err  error
+}
+
+// Upload synchronizes the keys under prefix with the files under dir.
+// Files that have no matching key, or whose key differs, are uploaded;
+// with Delete, keys that no file matches are deleted, once the uploads
+// are done, with as few requests as possible. A prefix that is not empty
+// names a "directory", and has a slash appended if needed.
+func (s *Syncer) Upload(dir, prefix string) error {
+	prefix = dirPrefix(prefix)
+	files, err := localFiles(dir)
+	if err != nil {
+		return err
+	}
+	keys, err := s.keys(prefix)
+	if err != nil {
+		return err
+	}
+	var jobs []job
+	for _, name := range fileNames(files) {
+		jobs = append(jobs, job{
+			op:   s.op(OpUpload, filepath.Join(dir, filepath.FromSlash(name)), prefix+name, files[name].Size()),
+			file: files[name],
+			key:  keys[name],
+		})
+	}
+	var dels []Op
+	if s.Delete {
+		for _, name := range keyNames(keys) {
+			if files[name] == nil {
+				dels = append(dels, s.op(OpDelete, "", prefix+name, keys[name].Size))
+			}
+		}
+	}
+	errs := s.run(jobs)
+	errs = append(errs, s.deleteKeys(dels)...)
+	return errs.err()
+}
+
+// Download synchronizes the files under dir with the keys under prefix,
+// creating dir if needed. Keys that have no matching file, or whose file
+// differs, are downloaded; with Delete, files that no key matches are
+// removed. Downloaded files get the modification time of their key.
+func (s *Syncer) Download(prefix, dir string) error {
+	prefix = dirPrefix(prefix)
+	keys, err := s.keys(prefix)
+	if err != nil {
+		return err
+	}
+	files, err := localFiles(dir)
+	if os.IsNotExist(err) {
+		files, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	var jobs []job
+	for _, name := range keyNames(keys) {
+		j := job{
+			op:   s.op(OpDownload, filepath.Join(dir, filepath.FromSlash(name)), prefix+name, keys[name].Size),
+			file: files[name],
+			key:  keys[name],
+		}
+		if !validName(name) {
+			j.err = errBadKey
+		}
+		jobs = append(jobs, j)
+	}
+	if s.Delete {
+		for _, name := range fileNames(files) {
+			if keys[name] == nil {
+				jobs = append(jobs, job{op: s.op(OpDelete, filepath.Join(dir, filepath.FromSlash(name)), "", files[name].Size())})
+			}
+		}
+	}
+	return s.run(jobs).err()
+}
+
+func (s *Syncer) op(kind OpKind, path, key string, size int64) Op {
+	return Op{Kind: kind, Bucket: s.Bucket.Name, Path: path, Key: key, Size: size}
+}
+
+// run makes the operations of jobs, Concurrency at a time, and returns
+// the errors of those that failed.
+func (s *Syncer) run(jobs []job) Errors {
+	concurrency := s.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	ch := make(chan job)
+	var mu sync.Mutex
+	var errs Errors
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range ch {
+				if err := s.do(j); err != nil {
+					mu.Lock()
+					errs = append(errs, &OpError{Op: j.op, Err: err})
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, j := range jobs {
+		ch <- j
+	}
+	close(ch)
+	wg.Wait()
+	return errs
+}
+
+// deleteKeys makes the deletions of keys ops, sending a DelMulti request
+// for every maxDelete of them, and returns the errors of those that
+// failed.
+func (s *Syncer) deleteKeys(ops []Op) Errors {
+	for _, op := range ops {
+		s.report(op)
+	}
+	if s.DryRun {
+		return nil
+	}
+	var errs Errors
+	for len(ops) > 0 {
+		batch := ops
+		if len(batch) > maxDelete {
+			batch = batch[:maxDelete]
+		}
+		ops = ops[len(batch):]
+		objects := make([]s3.ObjectId, len(batch))
+		byKey := make(map[string]Op, len(batch))
+		for i, op := range batch {
+			objects[i] = s3.ObjectId{Key: op.Key}
+			byKey[op.Key] = op
+		}
+		resp, err := s.Bucket.DelMulti(objects, true)
+		if err != nil {
+			for _, op := range batch {
+				errs = append(errs, &OpError{Op: op, Err: err})
+			}
+			continue
+		}
+		for i := range resp.Errors {
+			e := &resp.Errors[i]
+			errs = append(errs, &OpError{Op: byKey[e.Key], Err: e})
+		}
+	}
+	return errs
+}
+
+func (s *Syncer) report(op Op) {
+	if s.Report != nil {
+		s.mu.Lock()
+		s.Report(op)
+		s.mu.Unlock()
+	}
+}
+
+// do makes the operation of j, unless it is a transfer between a file and
+// a key that are the same.
+func (s *Syncer) do(j job) error {
+	if j.err != nil {
+		return j.err
+	}
+	if j.file != nil && j.key != nil {
+		changed, err := differ(j.op.Path, j.file, j.key, j.op.Kind == OpUpload)
+		if err != nil || !changed {
+			return err
+		}
+	}
+	s.report(j.op)
+	if s.DryRun {
+		return nil
+	}
+	switch j.op.Kind {
+	case OpUpload:
+		return s.upload(j.op.Path, j.op.Key)
+	case OpDownload:
+		return s.download(j.op.Key, j.op.Path, j.key)
+	}
+	return os.Remove(j.op.Path)
+}
+
+func (s *Syncer) upload(name, key string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	contType := mime.TypeByExtension(filepath.Ext(name))
+	if contType == "" {
+		contType = "application/octet-stream"
+	}
+	perm := s.Perm
+	if perm == "" {
+		perm = s3.Private
+	}
+	return s3.NewUploader(s.Bucket).Upload(key, f, contType, perm, s.Options)
+}
+
+// download writes the object at key to the file at name. The object is
+// first written to a temporary file in the same directory, which then
+// replaces the file, so that the file is never seen partially written.
+func (s *Syncer) download(key, name string, k *s3.Key) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(name); err == nil {
+		mode = fi.Mode().Perm()
+	}
+	f, err := ioutil.TempFile(dir, ".s3sync")
+	if err != nil {
+		return err
+	}
+	_, err = s3.NewDownloader(s.Bucket).Download(f, key)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), mode)
+	}
+	if mtime, perr := time.Parse(time.RFC3339, k.LastModified); err == nil && perr == nil {
+		err = os.Chtimes(f.Name(), mtime, mtime)
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// differ returns whether the file at name, described by fi, and key hold
+// different content. When that can only be told by their modification
+// times, they differ if the source, the file if upload is true, is the
+// newer.
+func differ(name string, fi os.FileInfo, key *s3.Key, upload bool) (bool, error) {
+	if fi.Size() != key.Size {
+		return true, nil
+	}
+	if etag := strings.Trim(key.ETag, `"`); isMD5(etag) {
+		sum, err := fileMD5(name)
+		if err != nil {
+			return false, err
+		}
+		return sum != etag, nil
+	}
+	mtime, err := time.Parse(time.RFC3339, key.LastModified)
+	if err != nil {
+		return true, nil
+	}
+	if upload {
+		return fi.ModTime().After(mtime), nil
+	}
+	return mtime.After(fi.ModTime()), nil
+}
+
+// isMD5 returns whether etag, without its quotes, is an MD5 sum, as it is
+// for objects not uploaded in several parts.
+func isMD5(etag string) bool {
+	if len(etag) != md5.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(etag)
+	return err == nil
+}
+
+func fileMD5(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// keys returns the keys under prefix, by their name relative to it.
+func (s *Syncer) keys(prefix string) (map[string]*s3.Key, error) {
+	keys := make(map[string]*s3.Key)
+	it := s3.NewListIterator(s.Bucket, prefix, "")
+	for it.Next() {
+		key := it.Key()
+		if key == nil || strings.HasSuffix(key.Key, "/") {
+			continue
+		}
+		keys[key.Key[len(prefix):]] = key
+	}
+	return keys, it.Err()
+}
+
+// localFiles returns the regular files under dir, by their path relative
+// to it with slashes as separators.
+func localFiles(dir string) (map[string]os.FileInfo, error) {
+	files := make(map[string]os.FileInfo)
+	err := filepath.Walk(dir, func(name string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = fi
+		return nil
+	})
+	return files, err
+}
+
+// dirPrefix returns prefix with a trailing slash, unless it is empty.
+func dirPrefix(prefix string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix
+}
+
+// validName returns whether name, the name of a key relative to the
+// prefix synced, is a clean relative path, so that the file it maps to is
+// under the directory synced.
+func validName(name string) bool {
+	for _, elem := range strings.Split(name, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func keyNames(keys map[string]*s3.Key) []string {
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func fileNames(files map[string]os.FileInfo) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,272 @@
+package s3sync_test
+
+import (
+	"bytes"
+	"github.com/hailocab/goamz/aws"
+	"github.com/hailocab/goamz/s3"
+	"github.com/hailocab/goamz/s3/s3sync"
+	"github.com/hailocab/goamz/s3/s3test"
+	"io/ioutil"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func Test(t *testing.T) {
+	gocheck.TestingT(t)
+}
+
+type S struct {
+	srv    *s3test.Server
+	bucket *s3.Bucket
+	dir    string
+	syncer *s3sync.Syncer
+	ops    []string
+}
+
+var _ = gocheck.Suite(&S{})
+
+func (s *S) SetUpTest(c *gocheck.C) {
+	srv, err := s3test.NewServer(nil)
+	c.Assert(err, gocheck.IsNil)
+	s.srv = srv
+	region := aws.Region{
+		Name:                 "faux-region-1",
+		S3Endpoint:           srv.URL(),
+		S3LocationConstraint: true,
+	}
+	s.bucket = s3.New(aws.Auth{}, region).Bucket("sync")
+	c.Assert(s.bucket.PutBucket(s3.Private), gocheck.IsNil)
+	s.dir = c.MkDir()
+	s.syncer = s3sync.New(s.bucket)
+	s.syncer.Report = func(op s3sync.Op) {
+		s.ops = append(s.ops, op.String())
+	}
+	s.ops = nil
+}
+
+func (s *S) TearDownTest(c *gocheck.C) {
+	s.srv.Quit()
+}
+
+func (s *S) writeFiles(c *gocheck.C, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(s.dir, filepath.FromSlash(name))
+		c.Assert(os.MkdirAll(filepath.Dir(path), 0777), gocheck.IsNil)
+		c.Assert(ioutil.WriteFile(path, []byte(content), 0644), gocheck.IsNil)
+	}
+}
+
+// takeOps returns the operations reported since the last call, in order.
+func (s *S) takeOps() []string {
+	ops := s.ops
+	s.ops = nil
+	sort.Strings(ops)
+	return ops
+}
+
+func (s *S) keys(c *gocheck.C) map[string]string {
+	keys := make(map[string]string)
+	it := s3.NewListIterator(s.bucket, "", "")
+	for it.Next() {
+		data, err := s.bucket.Get(it.Key().Key)
+		c.Assert(err, gocheck.IsNil)
+		keys[it.Key().Key] = string(data)
+	}
+	c.Assert(it.Err(), gocheck.IsNil)
+	return keys
+}
+
+func (s *S) TestUpload(c *gocheck.C) {
+	s.writeFiles(c, map[string]string{
+		"a.txt":       "alpha",
+		"dir/b.txt":   "bravo",
+		"dir/sub/c":   "charlie",
+		"dir/sub/d.x": "",
+	})
+	c.Assert(s.syncer.Upload(s.dir, "backup"), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.DeepEquals, []string{
+		"upload: " + filepath.Join(s.dir, "a.txt") + " to s3://sync/backup/a.txt",
+		"upload: " + filepath.Join(s.dir, "dir/b.txt") + " to s3://sync/backup/dir/b.txt",
+		"upload: " + filepath.Join(s.dir, "dir/sub/c") + " to s3://sync/backup/dir/sub/c",
+		"upload: " + filepath.Join(s.dir, "dir/sub/d.x") + " to s3://sync/backup/dir/sub/d.x",
+	})
+	c.Assert(s.keys(c), gocheck.DeepEquals, map[string]string{
+		"backup/a.txt":       "alpha",
+		"backup/dir/b.txt":   "bravo",
+		"backup/dir/sub/c":   "charlie",
+		"backup/dir/sub/d.x": "",
+	})
+	resp, err := s.bucket.Head("backup/a.txt", nil)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(resp.Header.Get("Content-Type"), gocheck.Equals, "text/plain; charset=utf-8")
+
+	// Nothing is uploaded again.
+	c.Assert(s.syncer.Upload(s.dir, "backup/"), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.HasLen, 0)
+
+	// Changes of content are found even if the size is the same.
+	s.writeFiles(c, map[string]string{
+		"a.txt":     "ALPHA",
+		"dir/b.txt": "bravo!",
+	})
+	c.Assert(s.syncer.Upload(s.dir, "backup"), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.DeepEquals, []string{
+		"upload: " + filepath.Join(s.dir, "a.txt") + " to s3://sync/backup/a.txt",
+		"upload: " + filepath.Join(s.dir, "dir/b.txt") + " to s3://sync/backup/dir/b.txt",
+	})
+	c.Assert(s.keys(c)["backup/a.txt"], gocheck.Equals, "ALPHA")
+	c.Assert(s.keys(c)["backup/dir/b.txt"], gocheck.Equals, "bravo!")
+}
+
+func (s *S) TestUploadDelete(c *gocheck.C) {
+	s.writeFiles(c, map[string]string{"a": "alpha", "b": "bravo"})
+	c.Assert(s.bucket.Put("other", []byte("kept"), "", s3.Private, s3.Options{}), gocheck.IsNil)
+	c.Assert(s.syncer.Upload(s.dir, ""), gocheck.IsNil)
+	s.takeOps()
+	c.Assert(os.Remove(filepath.Join(s.dir, "b")), gocheck.IsNil)
+
+	// Without Delete, keys are left alone.
+	c.Assert(s.syncer.Upload(s.dir, ""), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.HasLen, 0)
+
+	s.syncer.Delete = true
+	s.syncer.DryRun = true
+	c.Assert(s.syncer.Upload(s.dir, ""), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.DeepEquals, []string{
+		"delete: s3://sync/b",
+		"delete: s3://sync/other",
+	})
+	c.Assert(s.keys(c), gocheck.HasLen, 3)
+
+	s.syncer.DryRun = false
+	c.Assert(s.syncer.Upload(s.dir, ""), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.HasLen, 2)
+	c.Assert(s.keys(c), gocheck.DeepEquals, map[string]string{"a": "alpha"})
+}
+
+func (s *S) TestUploadDeleteErrors(c *gocheck.C) {
+	// Deletions go through a proxy that fails that of "b".
+	target, err := url.Parse(s.srv.URL())
+	c.Assert(err, gocheck.IsNil)
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	deletes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, ok := req.URL.Query()["delete"]; !ok {
+			proxy.ServeHTTP(w, req)
+			return
+		}
+		deletes++
+		w.Write([]byte(`<DeleteResult><Error><Key>b</Key><Code>AccessDenied</Code><Message>Access Denied</Message></Error></DeleteResult>`))
+	}))
+	defer srv.Close()
+	region := aws.Region{Name: "faux-region-1", S3Endpoint: srv.URL, S3LocationConstraint: true}
+	s.syncer.Bucket = s3.New(aws.Auth{}, region).Bucket("sync")
+
+	for _, key := range []string{"b", "c", "d"} {
+		c.Assert(s.bucket.Put(key, []byte(key), "", s3.Private, s3.Options{}), gocheck.IsNil)
+	}
+	s.syncer.Delete = true
+	err = s.syncer.Upload(s.dir, "")
+	c.Assert(deletes, gocheck.Equals, 1)
+	c.Assert(s.takeOps(), gocheck.HasLen, 3)
+	c.Assert(err, gocheck.FitsTypeOf, s3sync.Errors(nil))
+	errs := err.(s3sync.Errors)
+	c.Assert(errs, gocheck.HasLen, 1)
+	c.Assert(errs[0].Op.Key, gocheck.Equals, "b")
+	c.Assert(errs[0], gocheck.ErrorMatches, `delete: s3://sync/b: cannot delete "b": AccessDenied: Access Denied`)
+}
+
+func (s *S) TestDownload(c *gocheck.C) {
+	for key, content := range map[string]string{
+		"backup/a.txt":     "alpha",
+		"backup/dir/b.txt": "bravo",
+		"backup/empty/":    "",
+		"elsewhere":        "echo",
+	} {
+		c.Assert(s.bucket.Put(key, []byte(content), "", s3.Private, s3.Options{}), gocheck.IsNil)
+	}
+	dir := filepath.Join(s.dir, "new")
+	c.Assert(s.syncer.Download("backup", dir), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.DeepEquals, []string{
+		"download: s3://sync/backup/a.txt to " + filepath.Join(dir, "a.txt"),
+		"download: s3://sync/backup/dir/b.txt to " + filepath.Join(dir, "dir/b.txt"),
+	})
+	data, err := ioutil.ReadFile(filepath.Join(dir, "dir/b.txt"))
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Equals, "bravo")
+	_, err = os.Stat(filepath.Join(dir, "empty"))
+	c.Assert(os.IsNotExist(err), gocheck.Equals, true)
+
+	// Files get the modification time of their key.
+	resp, err := s.bucket.List("backup/a.txt", "", "", 1)
+	c.Assert(err, gocheck.IsNil)
+	mtime, err := time.Parse(time.RFC3339, resp.Contents[0].LastModified)
+	c.Assert(err, gocheck.IsNil)
+	fi, err := os.Stat(filepath.Join(dir, "a.txt"))
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(fi.ModTime().Equal(mtime), gocheck.Equals, true)
+
+	c.Assert(s.syncer.Download("backup", dir), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.HasLen, 0)
+
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("ALPHA"), 0644), gocheck.IsNil)
+	c.Assert(ioutil.WriteFile(filepath.Join(dir, "extra"), []byte("x"), 0644), gocheck.IsNil)
+	s.syncer.Delete = true
+	c.Assert(s.syncer.Download("backup", dir), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.DeepEquals, []string{
+		"delete: " + filepath.Join(dir, "extra"),
+		"download: s3://sync/backup/a.txt to " + filepath.Join(dir, "a.txt"),
+	})
+	data, err = ioutil.ReadFile(filepath.Join(dir, "a.txt"))
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Equals, "alpha")
+	_, err = os.Stat(filepath.Join(dir, "extra"))
+	c.Assert(os.IsNotExist(err), gocheck.Equals, true)
+}
+
+func (s *S) TestDownloadBadKey(c *gocheck.C) {
+	c.Assert(s.bucket.Put("backup/../escaped", []byte("x"), "", s3.Private, s3.Options{}), gocheck.IsNil)
+	c.Assert(s.bucket.Put("backup/fine", []byte("y"), "", s3.Private, s3.Options{}), gocheck.IsNil)
+	dir := filepath.Join(s.dir, "new")
+	err := s.syncer.Download("backup", dir)
+	c.Assert(err, gocheck.FitsTypeOf, s3sync.Errors(nil))
+	errs := err.(s3sync.Errors)
+	c.Assert(errs, gocheck.HasLen, 1)
+	c.Assert(errs[0].Op.Key, gocheck.Equals, "backup/../escaped")
+	c.Assert(errs[0], gocheck.ErrorMatches, `download: s3://sync/backup/\.\./escaped to .*: key does not map to a file name`)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "fine"))
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Equals, "y")
+	_, err = os.Stat(filepath.Join(s.dir, "escaped"))
+	c.Assert(os.IsNotExist(err), gocheck.Equals, true)
+}
+
+func (s *S) TestMultipartComparedByTime(c *gocheck.C) {
+	data := bytes.Repeat([]byte("0123456789abcdef"), (s3.MinUploadPartSize+1<<20)/16)
+	c.Assert(s3.NewUploader(s.bucket).Upload("big", bytes.NewReader(data), "", s3.Private, s3.Options{}), gocheck.IsNil)
+	c.Assert(s.syncer.Download("", s.dir), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.HasLen, 1)
+
+	// The ETag is not an MD5 sum, and neither side is newer.
+	c.Assert(s.syncer.Download("", s.dir), gocheck.IsNil)
+	c.Assert(s.syncer.Upload(s.dir, ""), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.HasLen, 0)
+
+	future := time.Now().Add(time.Hour)
+	c.Assert(os.Chtimes(filepath.Join(s.dir, "big"), future, future), gocheck.IsNil)
+	c.Assert(s.syncer.Download("", s.dir), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.HasLen, 0)
+	c.Assert(s.syncer.Upload(s.dir, ""), gocheck.IsNil)
+	c.Assert(s.takeOps(), gocheck.DeepEquals, []string{
+		"upload: " + filepath.Join(s.dir, "big") + " to s3://sync/big",
+	})
+}
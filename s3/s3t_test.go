@@ -1,10 +1,16 @@
 package s3_test
 
 import (
+	"bytes"
 	"github.com/hailocab/goamz/aws"
 	"github.com/hailocab/goamz/s3"
 	"github.com/hailocab/goamz/s3/s3test"
+	"github.com/hailocab/goamz/testutil/faults"
+	"io"
+	"io/ioutil"
 	"launchpad.net/gocheck"
+	"strings"
+	"time"
 )
 
 type LocalServer struct {
@@ -12,9 +18,13 @@ type LocalServer struct {
 	region aws.Region
 	srv    *s3test.Server
 	config *s3test.Config
+	onDisk bool
 }
 
 func (s *LocalServer) SetUp(c *gocheck.C) {
+	if s.onDisk && s.config == nil {
+		s.config = &s3test.Config{Dir: c.MkDir()}
+	}
 	srv, err := s3test.NewServer(s.config)
 	c.Assert(err, gocheck.IsNil)
 	c.Assert(srv, gocheck.NotNil)
@@ -38,7 +48,8 @@ type LocalServerSuite struct {
 }
 
 var (
-	// run tests twice, once in us-east-1 mode, once not.
+	// run tests twice, once in us-east-1 mode, once not,
+	// and once more with the server keeping objects on disk.
 	_ = gocheck.Suite(&LocalServerSuite{})
 	_ = gocheck.Suite(&LocalServerSuite{
 		srv: LocalServer{
@@ -47,14 +58,30 @@ var (
 			},
 		},
 	})
+	_ = gocheck.Suite(&LocalServerSuite{
+		srv: LocalServer{
+			onDisk: true,
+		},
+	})
+	// and once checking signatures.
+	_ = gocheck.Suite(&LocalServerSuite{
+		srv: LocalServer{
+			auth: localAuth,
+			config: &s3test.Config{
+				Auth: []aws.Auth{localAuth},
+			},
+		},
+	})
 )
 
+var localAuth = aws.Auth{AccessKey: "s3testkey", SecretKey: "s3test-secret"}
+
 func (s *LocalServerSuite) SetUpSuite(c *gocheck.C) {
 	s.srv.SetUp(c)
 	s.clientTests.s3 = s3.New(s.srv.auth, s.srv.region)
 
-	// TODO Sadly the fake server ignores auth completely right now. :-(
-	s.clientTests.authIsBroken = true
+	// The fake server only checks signatures when given credentials.
+	s.clientTests.authIsBroken = s.srv.config == nil || s.srv.config.Auth == nil
 	s.clientTests.Cleanup()
 }
 
@@ -66,6 +93,10 @@ func (s *LocalServerSuite) TestBasicFunctionality(c *gocheck.C) {
 	s.clientTests.TestBasicFunctionality(c)
 }
 
+func (s *LocalServerSuite) TestDownloader(c *gocheck.C) {
+	s.clientTests.TestDownloader(c)
+}
+
 func (s *LocalServerSuite) TestGetNotFound(c *gocheck.C) {
 	s.clientTests.TestGetNotFound(c)
 }
@@ -74,6 +105,236 @@ func (s *LocalServerSuite) TestBucketList(c *gocheck.C) {
 	s.clientTests.TestBucketList(c)
 }
 
+func (s *LocalServerSuite) TestListIterator(c *gocheck.C) {
+	s.clientTests.TestListIterator(c)
+}
+
+func (s *LocalServerSuite) TestDelMulti(c *gocheck.C) {
+	s.clientTests.TestDelMulti(c)
+}
+
+func (s *LocalServerSuite) TestBucketConfig(c *gocheck.C) {
+	s.clientTests.TestBucketConfig(c)
+}
+
+func (s *LocalServerSuite) TestACL(c *gocheck.C) {
+	s.clientTests.TestACL(c)
+}
+
+func (s *LocalServerSuite) TestObjectOptions(c *gocheck.C) {
+	s.clientTests.TestObjectOptions(c)
+}
+
 func (s *LocalServerSuite) TestDoublePutBucket(c *gocheck.C) {
 	s.clientTests.TestDoublePutBucket(c)
 }
+
+func (s *LocalServerSuite) TestVersions(c *gocheck.C) {
+	s.clientTests.TestVersions(c)
+}
+
+func (s *LocalServerSuite) TestVersionIterator(c *gocheck.C) {
+	s.clientTests.TestVersionIterator(c)
+}
+
+func (s *LocalServerSuite) TestCopy(c *gocheck.C) {
+	s.clientTests.TestCopy(c)
+}
+
+func (s *LocalServerSuite) TestMultiInitPutList(c *gocheck.C) {
+	s.clientTests.TestMultiInitPutList(c)
+}
+
+func (s *LocalServerSuite) TestMultiComplete(c *gocheck.C) {
+	s.clientTests.TestMultiComplete(c)
+}
+
+func (s *LocalServerSuite) TestListMulti(c *gocheck.C) {
+	s.clientTests.TestListMulti(c)
+}
+
+func (s *LocalServerSuite) TestMultiPutAllZeroLength(c *gocheck.C) {
+	s.clientTests.TestMultiPutAllZeroLength(c)
+}
+
+func (s *LocalServerSuite) TestFaults(c *gocheck.C) {
+	inj := faults.New()
+	s.srv.srv.SetFaults(inj)
+	defer s.srv.srv.SetFaults(nil)
+
+	client := s3.New(s.srv.auth, s.srv.region)
+	client.Config = &aws.Config{
+		Retryer: aws.DefaultRetryer{
+			Attempts:      3,
+			BaseDelay:     time.Millisecond,
+			ThrottleDelay: time.Millisecond,
+		},
+	}
+	b := testBucket(client)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, gocheck.IsNil)
+	err = b.Put("name", []byte("content"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, gocheck.IsNil)
+
+	// Throttled requests are retried.
+	inj.Add(faults.Rule{Nth: 1, Count: 2, StatusCode: 503, Code: "SlowDown"})
+	data, err := b.Get("name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Equals, "content")
+	c.Assert(inj.Injected(), gocheck.Equals, 2)
+
+	// Until the attempts run out.
+	inj.Reset()
+	inj.Add(faults.Rule{Code: "InternalError"})
+	_, err = b.Get("name")
+	c.Assert(err, gocheck.FitsTypeOf, &s3.Error{})
+	c.Assert(err.(*s3.Error).Code, gocheck.Equals, "InternalError")
+	c.Assert(inj.Injected(), gocheck.Equals, 3)
+
+	// Broken connections are retried too.
+	inj.Reset()
+	inj.Add(faults.Rule{Nth: 1, Reset: true})
+	data, err = b.Get("name")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Equals, "content")
+	c.Assert(inj.Injected(), gocheck.Equals, 1)
+
+	// A body cut short is reported.
+	inj.Reset()
+	inj.Add(faults.Rule{Nth: 1, Truncate: true, TruncateAfter: 3})
+	_, err = b.Get("name")
+	c.Assert(err, gocheck.Equals, io.ErrUnexpectedEOF)
+}
+
+func (s *LocalServerSuite) TestRestart(c *gocheck.C) {
+	if !s.srv.onDisk {
+		c.Skip("server keeps objects in memory")
+	}
+	b := testBucket(s.clientTests.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, gocheck.IsNil)
+	err = b.PutBucketVersioning(s3.VersioningEnabled)
+	c.Assert(err, gocheck.IsNil)
+	err = b.Put("a/b", []byte("old"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, gocheck.IsNil)
+	err = b.Put("a/b", []byte("content"), "text/plain", s3.PublicRead, s3.Options{
+		Meta: map[string][]string{"foo": {"bar"}},
+	})
+	c.Assert(err, gocheck.IsNil)
+	err = b.Put("gone", []byte("x"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, gocheck.IsNil)
+	err = b.Del("gone")
+	c.Assert(err, gocheck.IsNil)
+
+	// A new server started with the same directory finds it all again.
+	s.srv.srv.Quit()
+	s.srv.SetUp(c)
+	s.clientTests.s3 = s3.New(s.srv.auth, s.srv.region)
+	b = testBucket(s.clientTests.s3)
+
+	resp, err := b.GetResponse("a/b")
+	c.Assert(err, gocheck.IsNil)
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Equals, "content")
+	c.Assert(resp.Header.Get("Content-Type"), gocheck.Equals, "text/plain")
+	c.Assert(resp.Header.Get("X-Amz-Meta-Foo"), gocheck.Equals, "bar")
+
+	versions, err := b.Versions("", "", "", "", 0)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(versions.Versions, gocheck.HasLen, 3)
+	c.Assert(versions.DeleteMarkers, gocheck.HasLen, 1)
+	c.Assert(versions.Versions[0].Key, gocheck.Equals, "a/b")
+	c.Assert(versions.Versions[0].IsLatest, gocheck.Equals, true)
+	c.Assert(versions.Versions[0].Size, gocheck.Equals, int64(len("content")))
+
+	_, err = b.Get("gone")
+	c.Assert(err, gocheck.FitsTypeOf, &s3.Error{})
+	c.Assert(err.(*s3.Error).Code, gocheck.Equals, "NoSuchKey")
+
+	// New versions sort after those found on disk.
+	err = b.Put("a/b", []byte("new"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, gocheck.IsNil)
+	data, err = b.Get("a/b")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Equals, "new")
+}
+
+func (s *LocalServerSuite) TestSignatures(c *gocheck.C) {
+	if s.clientTests.authIsBroken {
+		c.Skip("server does not check signatures")
+	}
+	b := testBucket(s.clientTests.s3)
+	err := b.PutBucket(s3.Private)
+	c.Assert(err, gocheck.IsNil)
+
+	// Keys needing to be escaped are signed as they are sent, with
+	// either signature version, in headers or in the URL.
+	for _, version := range []int{2, 4} {
+		client := s3.New(s.srv.auth, s.srv.region)
+		client.SignatureVersion = version
+		b := client.Bucket(b.Name)
+		for _, name := range []string{"name", "with space", "a/b+c=d&e?f", "ünïcode"} {
+			err = b.Put(name, []byte("content"), "text/plain", s3.Private, s3.Options{
+				Meta: map[string][]string{"foo": {"bar"}},
+			})
+			c.Assert(err, gocheck.IsNil, gocheck.Commentf("v%d %q", version, name))
+			data, err := b.Get(name)
+			c.Assert(err, gocheck.IsNil, gocheck.Commentf("v%d %q", version, name))
+			c.Assert(string(data), gocheck.Equals, "content")
+		}
+		data, err := get(b.SignedURL("with space", time.Now().Add(time.Hour)))
+		c.Assert(err, gocheck.IsNil)
+		c.Assert(string(data), gocheck.Equals, "content")
+	}
+	url, err := b.PresignedGetURL("with space", time.Hour)
+	c.Assert(err, gocheck.IsNil)
+	data, err := get(url)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Equals, "content")
+
+	// Streamed payloads have each chunk signed.
+	big := bytes.Repeat([]byte("0123456789"), 200<<10)
+	err = b.PutReader("big", bytes.NewBuffer(big), int64(len(big)), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, gocheck.IsNil)
+	data, err = b.Get("big")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(bytes.Equal(data, big), gocheck.Equals, true)
+
+	// Anonymous requests are only allowed by ACLs.
+	data, err = get(b.URL("name"))
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Matches, "(?s).*AccessDenied.*")
+
+	// Wrong secrets and unknown keys are rejected.
+	wrong := s.srv.auth
+	wrong.SecretKey = "wrong"
+	_, err = s3.New(wrong, s.srv.region).Bucket(b.Name).Get("name")
+	c.Assert(err, gocheck.FitsTypeOf, &s3.Error{})
+	c.Assert(err.(*s3.Error).Code, gocheck.Equals, "SignatureDoesNotMatch")
+	c.Assert(err.(*s3.Error).StatusCode, gocheck.Equals, 403)
+
+	wrong.AccessKey = "unknown"
+	_, err = s3.New(wrong, s.srv.region).Bucket(b.Name).Get("name")
+	c.Assert(err, gocheck.FitsTypeOf, &s3.Error{})
+	c.Assert(err.(*s3.Error).Code, gocheck.Equals, "InvalidAccessKeyId")
+
+	// As are expired presigned URLs.
+	data, err = get(b.SignedURL("name", time.Now().Add(-time.Hour)))
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Matches, "(?s).*<Code>AccessDenied</Code>.*expired.*")
+	url, err = b.PresignedGetURL("name", time.Second)
+	c.Assert(err, gocheck.IsNil)
+	time.Sleep(1100 * time.Millisecond)
+	data, err = get(url)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Matches, "(?s).*<Code>AccessDenied</Code>.*expired.*")
+
+	// Tampering with a presigned URL breaks its signature.
+	url, err = b.PresignedGetURL("name", time.Hour)
+	c.Assert(err, gocheck.IsNil)
+	data, err = get(strings.Replace(url, "/name?", "/with%20space?", 1))
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(string(data), gocheck.Matches, "(?s).*<Code>SignatureDoesNotMatch</Code>.*<CanonicalRequest>.*")
+}
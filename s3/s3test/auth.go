@@ -0,0 +1,463 @@
+package s3test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/hailocab/goamz/aws"
+	"github.com/hailocab/goamz/s3"
+	"hash"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSkew is how far from the time they are received requests may have
+// been signed.
+const maxSkew = 15 * time.Minute
+
+const (
+	v4Algorithm   = "AWS4-HMAC-SHA256"
+	v4DateFormat  = "20060102T150405Z"
+	emptyHash     = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	streamingHash = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+)
+
+// v2SignedParams holds the query parameters that are part of the
+// resource signed with signature version 2.
+// http://docs.aws.amazon.com/AmazonS3/latest/dev/RESTAuthentication.html
+var v2SignedParams = map[string]bool{
+	"acl":                          true,
+	"cors":                         true,
+	"delete":                       true,
+	"lifecycle":                    true,
+	"location":                     true,
+	"logging":                      true,
+	"notification":                 true,
+	"partNumber":                   true,
+	"policy":                       true,
+	"requestPayment":               true,
+	"tagging":                      true,
+	"torrent":                      true,
+	"uploadId":                     true,
+	"uploads":                      true,
+	"versionId":                    true,
+	"versioning":                   true,
+	"versions":                     true,
+	"website":                      true,
+	"response-content-type":        true,
+	"response-content-language":    true,
+	"response-expires":             true,
+	"response-cache-control":       true,
+	"response-content-disposition": true,
+	"response-content-encoding":    true,
+}
+
+// authenticate checks the signature of the request of a against the
+// credentials of the server, if it has any. It returns false if the
+// request is anonymous.
+func (srv *Server) authenticate(a *action) bool {
+	if srv.config == nil || len(srv.config.Auth) == 0 {
+		return true
+	}
+	req := a.req
+	q := req.URL.Query()
+	authz := req.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(authz, v4Algorithm+" "):
+		srv.checkV4(a, parseV4Authorization(authz), false)
+	case strings.HasPrefix(authz, "AWS "):
+		i := strings.LastIndex(authz, ":")
+		if i < 0 {
+			fatalf(400, "InvalidArgument", "AWS authorization header is invalid.  Expected AwsAccessKeyId:signature")
+		}
+		date := req.Header.Get("Date")
+		if req.Header.Get("X-Amz-Date") != "" {
+			date = ""
+		}
+		checkSkew(req.Header.Get("X-Amz-Date"), req.Header.Get("Date"))
+		srv.checkV2(a, authz[len("AWS "):i], authz[i+1:], date, req.Header.Get("X-Amz-Security-Token"))
+	case q.Get("X-Amz-Algorithm") != "":
+		if q.Get("X-Amz-Algorithm") != v4Algorithm {
+			fatalf(400, "AuthorizationQueryParametersError", "X-Amz-Algorithm only supports \"%s\"", v4Algorithm)
+		}
+		srv.checkV4(a, map[string]string{
+			"Credential":    q.Get("X-Amz-Credential"),
+			"SignedHeaders": q.Get("X-Amz-SignedHeaders"),
+			"Signature":     q.Get("X-Amz-Signature"),
+		}, true)
+	case q.Get("Signature") != "":
+		expires, err := strconv.ParseInt(q.Get("Expires"), 10, 64)
+		if err != nil {
+			fatalf(403, "AccessDenied", "Query-string authentication requires the Signature, Expires and AWSAccessKeyId parameters")
+		}
+		srv.checkV2(a, q.Get("AWSAccessKeyId"), q.Get("Signature"), q.Get("Expires"), q.Get("x-amz-security-token"))
+		if time.Now().Unix() > expires {
+			fatalf(403, "AccessDenied", "Request has expired")
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// credentials returns the credentials of the server with the given
+// access key.
+func (srv *Server) credentials(accessKey string) aws.Auth {
+	for _, auth := range srv.config.Auth {
+		if auth.AccessKey == accessKey {
+			return auth
+		}
+	}
+	panic(&s3Error{
+		statusCode:     403,
+		Code:           "InvalidAccessKeyId",
+		Message:        "The AWS Access Key Id you provided does not exist in our records.",
+		AWSAccessKeyId: accessKey,
+	})
+}
+
+// checkToken checks the session token sent with a request signed with
+// auth.
+func checkToken(auth aws.Auth, token string) {
+	if token != auth.Token() {
+		fatalf(403, "InvalidToken", "The provided token is malformed or otherwise invalid.")
+	}
+}
+
+// checkSkew fails requests signed too long ago or too far in the future,
+// given the values of their X-Amz-Date and Date headers.
+func checkSkew(amzDate, date string) {
+	if amzDate != "" {
+		date = amzDate
+	}
+	var t time.Time
+	var err error
+	for _, layout := range []string{v4DateFormat, http.TimeFormat, time.RFC1123, time.RFC1123Z} {
+		if t, err = time.Parse(layout, date); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		fatalf(403, "AccessDenied", "AWS authentication requires a valid Date or x-amz-date header")
+	}
+	if d := time.Since(t); d > maxSkew || d < -maxSkew {
+		fatalf(403, "RequestTimeTooSkewed", "The difference between the request time and the current time is too large.")
+	}
+}
+
+// checkV2 checks a request signed with signature version 2, in its
+// headers or, if date is the Expires parameter, in its URL.
+// http://docs.aws.amazon.com/AmazonS3/latest/dev/RESTAuthentication.html
+func (srv *Server) checkV2(a *action, accessKey, signature, date, token string) {
+	auth := srv.credentials(accessKey)
+	req := a.req
+	var amz []string
+	for _, key := range sortedKeys(req.Header) {
+		if name := strings.ToLower(key); strings.HasPrefix(name, "x-amz-") {
+			amz = append(amz, name+":"+strings.Join(req.Header[key], ","))
+		}
+	}
+	var params []string
+	q := req.URL.Query()
+	for _, key := range sortedKeys(q) {
+		if !v2SignedParams[key] {
+			continue
+		}
+		for _, v := range q[key] {
+			if v == "" {
+				params = append(params, key)
+			} else {
+				params = append(params, key+"="+v)
+			}
+		}
+	}
+	resource := req.URL.EscapedPath()
+	if len(params) > 0 {
+		resource += "?" + strings.Join(params, "&")
+	}
+	var sts string
+	sts += req.Method + "\n"
+	sts += req.Header.Get("Content-MD5") + "\n"
+	sts += req.Header.Get("Content-Type") + "\n"
+	sts += date + "\n"
+	for _, h := range amz {
+		sts += h + "\n"
+	}
+	sts += resource
+
+	mac := hmac.New(sha1.New, []byte(auth.SecretKey))
+	mac.Write([]byte(sts))
+	if !hmac.Equal([]byte(signature), []byte(base64.StdEncoding.EncodeToString(mac.Sum(nil)))) {
+		signatureMismatch(accessKey, signature, sts, "")
+	}
+	checkToken(auth, token)
+}
+
+// parseV4Authorization returns the fields of the Authorization header
+// of a request signed with signature version 4.
+func parseV4Authorization(authz string) map[string]string {
+	fields := make(map[string]string)
+	for _, f := range strings.Split(authz[len(v4Algorithm)+1:], ",") {
+		f = strings.TrimSpace(f)
+		if i := strings.Index(f, "="); i > 0 {
+			fields[f[:i]] = f[i+1:]
+		}
+	}
+	return fields
+}
+
+// checkV4 checks a request signed with signature version 4, in its
+// headers or, if presigned, in its URL. The payload is checked as it is
+// read.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-authenticating-requests.html
+func (srv *Server) checkV4(a *action, fields map[string]string, presigned bool) {
+	req := a.req
+	q := req.URL.Query()
+	scope := strings.Split(fields["Credential"], "/")
+	if len(scope) != 5 || scope[4] != "aws4_request" || fields["SignedHeaders"] == "" || fields["Signature"] == "" {
+		fatalf(400, "AuthorizationHeaderMalformed", "The authorization header is malformed; the Credential, SignedHeaders and Signature are required.")
+	}
+	auth := srv.credentials(scope[0])
+	date := req.Header.Get("X-Amz-Date")
+	token := req.Header.Get("X-Amz-Security-Token")
+	if presigned {
+		date = q.Get("X-Amz-Date")
+		token = q.Get("X-Amz-Security-Token")
+	}
+	t, err := time.Parse(v4DateFormat, date)
+	if err != nil || t.Format("20060102") != scope[1] {
+		fatalf(403, "AccessDenied", "AWS authentication requires a valid x-amz-date matching the date of the credential")
+	}
+
+	payload := req.Header.Get("X-Amz-Content-Sha256")
+	if presigned {
+		if payload == "" {
+			payload = "UNSIGNED-PAYLOAD"
+		}
+		expires, err := strconv.Atoi(q.Get("X-Amz-Expires"))
+		if err != nil || expires < 0 || expires > 7*24*3600 {
+			fatalf(400, "AuthorizationQueryParametersError", "X-Amz-Expires must be a number of seconds between 0 and 604800")
+		}
+		if time.Since(t) > time.Duration(expires)*time.Second {
+			fatalf(403, "AccessDenied", "Request has expired")
+		}
+	} else {
+		if payload == "" {
+			fatalf(400, "InvalidRequest", "Missing required header for this request: x-amz-content-sha256")
+		}
+		checkSkew(date, "")
+	}
+
+	var params []string
+	for _, key := range sortedKeys(q) {
+		if presigned && key == "X-Amz-Signature" {
+			continue
+		}
+		values := append([]string(nil), q[key]...)
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, uriEncode(key, true)+"="+uriEncode(v, true))
+		}
+	}
+	var headers string
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	for _, name := range signed {
+		var values []string
+		if name == "host" {
+			values = []string{req.Host}
+		} else {
+			values = append(values, req.Header[http.CanonicalHeaderKey(name)]...)
+		}
+		for i, v := range values {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers += name + ":" + strings.Join(values, ",") + "\n"
+	}
+	creq := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		strings.Join(params, "&"),
+		headers,
+		fields["SignedHeaders"],
+		payload,
+	}, "\n")
+
+	s := &v4Signature{
+		key:   v4Key(auth.SecretKey, scope[1], scope[2], scope[3]),
+		date:  date,
+		scope: strings.Join(scope[1:], "/"),
+	}
+	sts := s.stringToSign(v4Algorithm, hashHex([]byte(creq)))
+	if !s.check(sts, fields["Signature"]) {
+		signatureMismatch(scope[0], fields["Signature"], sts, creq)
+	}
+	checkToken(auth, token)
+
+	switch {
+	case payload == streamingHash:
+		a.chunks = s
+	case len(payload) == sha256.Size*2:
+		req.Body = &payloadChecker{
+			ReadCloser: req.Body,
+			hash:       sha256.New(),
+			want:       payload,
+		}
+	}
+}
+
+// v4Signature computes signatures with signature version 4, and holds
+// the last one checked, which the signature of the next chunk of a
+// streamed payload derives from.
+type v4Signature struct {
+	key   []byte
+	date  string
+	scope string
+	last  string
+}
+
+func (s *v4Signature) stringToSign(algorithm, hash string) string {
+	return algorithm + "\n" + s.date + "\n" + s.scope + "\n" + hash
+}
+
+// check returns whether signature is that of the string to sign sts.
+func (s *v4Signature) check(sts, signature string) bool {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(sts))
+	if !hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+		return false
+	}
+	s.last = signature
+	return true
+}
+
+// checkChunk returns whether signature is that of the next chunk of a
+// streamed payload, holding data.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming.html
+func (s *v4Signature) checkChunk(data []byte, signature string) bool {
+	return s.check(s.stringToSign(v4Algorithm+"-PAYLOAD", s.last+"\n"+emptyHash+"\n"+hashHex(data)), signature)
+}
+
+// v4Key derives the key requests are signed with on the given date, in
+// the given region and for the given service.
+func v4Key(secret, date, region, service string) []byte {
+	key := []byte("AWS4" + secret)
+	for _, s := range []string{date, region, service, "aws4_request"} {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(s))
+		key = mac.Sum(nil)
+	}
+	return key
+}
+
+// sortedKeys returns the keys of the header or query parameters m, in
+// order. Canonical requests list them so, and not by their lines, which
+// would put x-amz-meta-a-b before x-amz-meta-a.
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// uriEncode encodes s as the canonical requests of signature version 4
+// require: all bytes but the unreserved characters of RFC 3986 are
+// percent-encoded, and so are slashes if encodeSlash is true.
+func uriEncode(s string, encodeSlash bool) string {
+	var buf []byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			buf = append(buf, c)
+		default:
+			buf = append(buf, fmt.Sprintf("%%%02X", c)...)
+		}
+	}
+	return string(buf)
+}
+
+// signatureMismatch fails a request whose signature is not the one
+// expected, giving the string to sign and, for signature version 4, the
+// canonical request the server computed.
+func signatureMismatch(accessKey, signature, sts, creq string) {
+	panic(&s3Error{
+		statusCode:        403,
+		Code:              "SignatureDoesNotMatch",
+		Message:           "The request signature we calculated does not match the signature you provided. Check your key and signing method.",
+		AWSAccessKeyId:    accessKey,
+		StringToSign:      sts,
+		SignatureProvided: signature,
+		CanonicalRequest:  creq,
+	})
+}
+
+// errChunkSignature is returned when a chunk of a streamed payload does
+// not have the signature expected.
+var errChunkSignature = errors.New("chunk signature does not match")
+
+// payloadChecker checks that the body it reads has the SHA-256 sum sent
+// in the x-amz-content-sha256 header, failing the request at the end of
+// the body if not.
+type payloadChecker struct {
+	io.ReadCloser
+	hash hash.Hash
+	want string
+}
+
+func (r *payloadChecker) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(r.hash.Sum(nil)) != r.want {
+		fatalf(400, "XAmzContentSHA256Mismatch", "The provided 'x-amz-content-sha256' header does not match what was computed.")
+	}
+	return n, err
+}
+
+// checkAnonymous fails anonymous requests, unless the ACL of what they
+// are for grants all users the permission they need.
+func checkAnonymous(a *action, r resource) {
+	read := a.req.Method == "GET" || a.req.Method == "HEAD"
+	var acl *s3.AccessControlPolicy
+	perm := s3.PermRead
+	switch r := r.(type) {
+	case objectResource:
+		switch {
+		case !read:
+			acl, perm = &r.bucket.acl, s3.PermWrite
+		case r.object != nil && !r.object.deleteMarker:
+			acl = &r.object.acl
+		default:
+			// Whether the object exists is only told to those who may
+			// list the bucket.
+			acl = &r.bucket.acl
+		}
+	case bucketResource:
+		if read && r.bucket != nil {
+			acl = &r.bucket.acl
+		}
+	}
+	if acl != nil {
+		for _, g := range acl.Grants {
+			if g.Grantee.Type == s3.GranteeGroup && g.Grantee.URI == s3.AllUsersGroup && (g.Permission == perm || g.Permission == s3.PermFullControl) {
+				return
+			}
+		}
+	}
+	fatalf(403, "AccessDenied", "Access Denied")
+}
@@ -0,0 +1,156 @@
+package s3test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"github.com/hailocab/goamz/s3"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// copiedHeaders holds the metadata headers a copy takes from its source
+// unless the metadata directive is REPLACE, along with x-amz-meta-*.
+var copiedHeaders = map[string]bool{
+	"Content-Type":        true,
+	"Content-Encoding":    true,
+	"Content-Disposition": true,
+	"Cache-Control":       true,
+	"Expires":             true,
+}
+
+// copySource returns the object named by the x-amz-copy-source header of
+// a request, checking the conditions and encryption key given for it in
+// the x-amz-copy-source-* headers.
+func (srv *Server) copySource(a *action) *object {
+	source := a.req.Header.Get("X-Amz-Copy-Source")
+	var versionId string
+	if i := strings.Index(source, "?"); i >= 0 {
+		q, err := url.ParseQuery(source[i+1:])
+		if err != nil {
+			fatalf(400, "InvalidArgument", "Invalid copy source")
+		}
+		versionId = q.Get("versionId")
+		source = source[:i]
+	}
+	path, err := url.PathUnescape(source)
+	if err != nil {
+		fatalf(400, "InvalidArgument", "Invalid copy source encoding")
+	}
+	path = strings.TrimPrefix(path, "/")
+	i := strings.Index(path, "/")
+	if i <= 0 || i == len(path)-1 {
+		fatalf(400, "InvalidArgument", "Copy Source must mention the source bucket and key: sourcebucket/sourcekey")
+	}
+	b := srv.buckets[path[:i]]
+	if b == nil {
+		fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
+	}
+	name := path[i+1:]
+	var obj *object
+	if versionId != "" {
+		obj = b.version(name, versionId)
+		if obj == nil {
+			fatalf(404, "NoSuchVersion", "The specified version does not exist.")
+		}
+		if obj.deleteMarker {
+			fatalf(400, "InvalidRequest", "The source of a copy request may not specifically refer to a delete marker by version id.")
+		}
+	} else {
+		obj = b.objects[name]
+		if obj == nil {
+			fatalf(404, "NoSuchKey", "The specified key does not exist.")
+		}
+	}
+	if keyMD5 := obj.meta.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5"); keyMD5 != "" {
+		if sseCustomerKeyMD5(a, "X-Amz-Copy-Source-") != keyMD5 {
+			fatalf(400, "InvalidRequest", "The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.")
+		}
+	}
+	if !checkConditions(a, "X-Amz-Copy-Source-", obj.etag, obj.mtime) {
+		// Copies fail where reads would not be modified.
+		fatalf(412, "PreconditionFailed", "At least one of the preconditions you specified did not hold.")
+	}
+	if b.versioning() != "" {
+		a.w.Header().Set("x-amz-copy-source-version-id", obj.versionId)
+	}
+	return obj
+}
+
+// PUT on an object with the x-amz-copy-source header copies another
+// object to it. The copy keeps the metadata and tags of its source unless
+// the x-amz-metadata-directive and x-amz-tagging-directive headers are
+// REPLACE, but takes its ACL, storage class and encryption from the
+// request.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectCOPY.html
+func (objr objectResource) copy(a *action) interface{} {
+	src := a.srv.copySource(a)
+	meta := objectMeta(a)
+	acl := cannedACL(s3.ACL(a.req.Header.Get("x-amz-acl")))
+	directive := a.req.Header.Get("X-Amz-Metadata-Directive")
+	switch directive {
+	case "", s3.CopyMetadata:
+		if src == objr.object && !changesObject(a) {
+			fatalf(400, "InvalidRequest", "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes.")
+		}
+		for key := range meta {
+			if copiedHeaders[key] || strings.HasPrefix(key, "X-Amz-Meta-") {
+				delete(meta, key)
+			}
+		}
+		for key, values := range src.meta {
+			if copiedHeaders[key] || strings.HasPrefix(key, "X-Amz-Meta-") {
+				meta[key] = values
+			}
+		}
+	case s3.ReplaceMetadata:
+	default:
+		fatalf(400, "InvalidArgument", "Unknown metadata directive.")
+	}
+	switch a.req.Header.Get("X-Amz-Tagging-Directive") {
+	case "", "COPY":
+		meta.Del("X-Amz-Tagging-Count")
+		if count := src.meta.Get("X-Amz-Tagging-Count"); count != "" {
+			meta.Set("X-Amz-Tagging-Count", count)
+		}
+	case "REPLACE":
+	default:
+		fatalf(400, "InvalidArgument", "Unknown tagging directive.")
+	}
+
+	data := src.content()
+	sum := md5.Sum(data)
+	obj := &object{
+		name:     objr.name,
+		mtime:    time.Now(),
+		meta:     meta,
+		checksum: sum[:],
+		etag:     hex.EncodeToString(sum[:]),
+		data:     data,
+		size:     int64(len(data)),
+		acl:      acl,
+	}
+	objr.bucket.addVersion(obj)
+	objr.bucket.setVersionHeader(a, obj)
+	return &s3.CopyObjectResult{
+		ETag:         `"` + obj.etag + `"`,
+		LastModified: obj.mtime.Format(timeFormat),
+	}
+}
+
+// changesObject returns whether a copy request sets attributes of the
+// object other than its metadata, so that it may copy an object to
+// itself.
+func changesObject(a *action) bool {
+	for _, name := range []string{
+		"X-Amz-Storage-Class",
+		"X-Amz-Website-Redirect-Location",
+		"X-Amz-Server-Side-Encryption",
+		"X-Amz-Server-Side-Encryption-Customer-Algorithm",
+	} {
+		if a.req.Header.Get(name) != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,308 @@
+package s3test
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"github.com/hailocab/goamz/s3"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A diskStore keeps the buckets and objects of a server in a directory,
+// so that they outlive it. Each bucket has a directory holding its
+// settings in bucket.json and, under objects, a directory for each key.
+// Each version of an object has a data file named after its version id,
+// and a sidecar metadata file with the .json extension. Multipart
+// uploads in progress are only held in memory.
+//
+// A nil *diskStore keeps nothing, as for a server held in memory. The
+// methods saving and removing things fail the request they are called
+// for if the disk cannot be written to.
+type diskStore struct {
+	dir string
+}
+
+// bucketInfo is the content of the bucket.json file of a bucket.
+type bucketInfo struct {
+	Created time.Time
+	ACL     s3.AccessControlPolicy
+	Config  map[string]string `json:",omitempty"`
+}
+
+// objectInfo is the content of the sidecar metadata file of an object
+// version.
+type objectInfo struct {
+	Key          string
+	VersionId    string
+	LastModified time.Time
+	Metadata     http.Header `json:",omitempty"`
+	Checksum     []byte      `json:",omitempty"`
+	ETag         string      `json:",omitempty"`
+	Size         int64
+	ACL          s3.AccessControlPolicy
+	DeleteMarker bool `json:",omitempty"`
+}
+
+func (st *diskStore) bucketDir(b *bucket) string {
+	return filepath.Join(st.dir, b.name)
+}
+
+// objectDir returns the directory holding the versions of the object
+// called name. Keys are escaped to make up a single file name, unless
+// they are too long for one, in which case their SHA-1 sum is used.
+func (st *diskStore) objectDir(b *bucket, name string) string {
+	s := url.PathEscape(name)
+	if strings.HasPrefix(s, ".") {
+		s = "%2E" + s[1:]
+	}
+	if len(s) > 200 {
+		s = fmt.Sprintf("#%x", sha1.Sum([]byte(name)))
+	}
+	return filepath.Join(st.bucketDir(b), "objects", s)
+}
+
+// saveBucket writes the settings of b, creating its directory if needed.
+func (st *diskStore) saveBucket(b *bucket) {
+	if st == nil {
+		return
+	}
+	info := bucketInfo{
+		Created: b.ctime,
+		ACL:     b.acl,
+	}
+	if len(b.config) > 0 {
+		info.Config = make(map[string]string)
+		for name, doc := range b.config {
+			info.Config[name] = string(doc)
+		}
+	}
+	check(writeJSON(filepath.Join(st.bucketDir(b), "bucket.json"), &info))
+}
+
+// removeBucket removes the directory of b, which must hold no objects.
+func (st *diskStore) removeBucket(b *bucket) {
+	if st == nil {
+		return
+	}
+	check(os.RemoveAll(st.bucketDir(b)))
+}
+
+// saveObject writes the metadata of obj, a version of an object in b,
+// along with its data if it is new. The data is then dropped from
+// memory, to be read from disk when needed.
+func (st *diskStore) saveObject(b *bucket, obj *object) {
+	if st == nil {
+		return
+	}
+	dir := st.objectDir(b, obj.name)
+	if !obj.deleteMarker && obj.path == "" {
+		path := filepath.Join(dir, obj.versionId)
+		check(writeFile(path, obj.data))
+		obj.path = path
+		obj.data = nil
+	}
+	info := objectInfo{
+		Key:          obj.name,
+		VersionId:    obj.versionId,
+		LastModified: obj.mtime,
+		Metadata:     obj.meta,
+		Checksum:     obj.checksum,
+		ETag:         obj.etag,
+		Size:         obj.size,
+		ACL:          obj.acl,
+		DeleteMarker: obj.deleteMarker,
+	}
+	check(writeJSON(filepath.Join(dir, obj.versionId+".json"), &info))
+}
+
+// removeObject removes the files of obj, a version of an object in b.
+func (st *diskStore) removeObject(b *bucket, obj *object) {
+	if st == nil {
+		return
+	}
+	dir := st.objectDir(b, obj.name)
+	for _, path := range []string{filepath.Join(dir, obj.versionId+".json"), filepath.Join(dir, obj.versionId)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			check(err)
+		}
+	}
+	// The directory is only removed once it holds no other version.
+	os.Remove(dir)
+}
+
+// load reads all the buckets kept in the store.
+func (st *diskStore) load() (map[string]*bucket, error) {
+	buckets := make(map[string]*bucket)
+	if err := os.MkdirAll(st.dir, 0777); err != nil {
+		return nil, err
+	}
+	entries, err := ioutil.ReadDir(st.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		b, err := st.loadBucket(e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("cannot load bucket %q: %v", e.Name(), err)
+		}
+		buckets[b.name] = b
+	}
+	return buckets, nil
+}
+
+func (st *diskStore) loadBucket(name string) (*bucket, error) {
+	b := &bucket{
+		name:     name,
+		objects:  make(map[string]*object),
+		versions: make(map[string][]*object),
+		uploads:  make(map[string]*multipartUpload),
+		store:    st,
+	}
+	var info bucketInfo
+	if err := readJSON(filepath.Join(st.bucketDir(b), "bucket.json"), &info); err != nil {
+		return nil, err
+	}
+	b.ctime = info.Created
+	b.acl = info.ACL
+	if len(info.Config) > 0 {
+		b.config = make(map[string][]byte)
+		for name, doc := range info.Config {
+			b.config[name] = []byte(doc)
+		}
+	}
+	paths, err := filepath.Glob(filepath.Join(st.bucketDir(b), "objects", "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		var info objectInfo
+		if err := readJSON(path, &info); err != nil {
+			return nil, err
+		}
+		obj := &object{
+			name:         info.Key,
+			mtime:        info.LastModified,
+			meta:         info.Metadata,
+			checksum:     info.Checksum,
+			etag:         info.ETag,
+			size:         info.Size,
+			acl:          info.ACL,
+			versionId:    info.VersionId,
+			deleteMarker: info.DeleteMarker,
+		}
+		if obj.meta == nil {
+			obj.meta = make(http.Header)
+		}
+		if !obj.deleteMarker {
+			obj.path = strings.TrimSuffix(path, ".json")
+		}
+		b.versions[obj.name] = append(b.versions[obj.name], obj)
+		// New version ids must sort after those already used.
+		if id, err := strconv.ParseInt(obj.versionId, 16, 64); err == nil && int(id) > b.lastId {
+			b.lastId = int(id)
+		}
+	}
+	for name, vs := range b.versions {
+		sort.Sort(orderedVersions(vs))
+		b.setLatest(name)
+	}
+	return b, nil
+}
+
+// orderedVersions holds the versions of an object, which can be sorted
+// from the oldest.
+type orderedVersions []*object
+
+func (s orderedVersions) Len() int      { return len(s) }
+func (s orderedVersions) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s orderedVersions) Less(i, j int) bool {
+	if !s[i].mtime.Equal(s[j].mtime) {
+		return s[i].mtime.Before(s[j].mtime)
+	}
+	return s[i].versionId < s[j].versionId
+}
+
+// check fails the current request if err is not nil.
+func check(err error) {
+	if err != nil {
+		fatalf(500, "InternalError", "%v", err)
+	}
+}
+
+// writeFile writes data to the file at path, creating its directory if
+// needed. The file is replaced at once, so that it is never seen
+// partially written.
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, append(data, '\n'))
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// content returns the data of obj, reading it from disk if needed.
+func (obj *object) content() []byte {
+	if obj.path == "" {
+		return obj.data
+	}
+	data, err := ioutil.ReadFile(obj.path)
+	check(err)
+	return data
+}
+
+// writeRange writes n bytes of the data of obj, from offset start, to w.
+func (obj *object) writeRange(w io.Writer, start, n int64) error {
+	if obj.path == "" {
+		_, err := w.Write(obj.data[start : start+n])
+		return err
+	}
+	f, err := os.Open(obj.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, io.NewSectionReader(f, start, n))
+	return err
+}
@@ -0,0 +1,374 @@
+package s3test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"github.com/hailocab/goamz/s3"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// minPartSize is the size S3 requires of all the parts of a multipart
+// upload but the last.
+const minPartSize = 5 << 20
+
+type multipartUpload struct {
+	id        string
+	name      string
+	initiated time.Time
+	meta      http.Header // metadata of the object, from the initiate request.
+	acl       s3.AccessControlPolicy
+	parts     map[int]*part
+}
+
+type part struct {
+	data     []byte
+	checksum []byte
+	mtime    time.Time
+}
+
+func (p *part) etag() string {
+	return fmt.Sprintf(`"%x"`, p.checksum)
+}
+
+type initiateMultipartUploadResult struct {
+	XMLName  struct{} `xml:"InitiateMultipartUploadResult"`
+	Bucket   string
+	Key      string
+	UploadId string
+}
+
+type listMultipartUploadsResult struct {
+	XMLName            struct{} `xml:"ListMultipartUploadsResult"`
+	Bucket             string
+	KeyMarker          string
+	UploadIdMarker     string
+	NextKeyMarker      string
+	NextUploadIdMarker string
+	Prefix             string
+	Delimiter          string
+	MaxUploads         int
+	IsTruncated        bool
+	Uploads            []listedUpload `xml:"Upload"`
+	CommonPrefixes     []string       `xml:"CommonPrefixes>Prefix"`
+}
+
+type listedUpload struct {
+	Key          string
+	UploadId     string
+	Initiator    s3.Owner
+	Owner        s3.Owner
+	StorageClass string
+	Initiated    string
+}
+
+type listPartsResult struct {
+	XMLName              struct{} `xml:"ListPartsResult"`
+	Bucket               string
+	Key                  string
+	UploadId             string
+	PartNumberMarker     int
+	NextPartNumberMarker int
+	MaxParts             int
+	IsTruncated          bool
+	Parts                []listedPart `xml:"Part"`
+}
+
+type listedPart struct {
+	PartNumber   int
+	LastModified string
+	ETag         string
+	Size         int64
+}
+
+type copyPartResult struct {
+	XMLName      struct{} `xml:"CopyPartResult"`
+	ETag         string
+	LastModified string
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  struct{} `xml:"CompleteMultipartUploadResult"`
+	Location string
+	Bucket   string
+	Key      string
+	ETag     string
+}
+
+// orderedUploads holds a slice of multipart uploads that can be sorted
+// by key, and then from the earliest initiated.
+type orderedUploads []*multipartUpload
+
+func (s orderedUploads) Len() int      { return len(s) }
+func (s orderedUploads) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s orderedUploads) Less(i, j int) bool {
+	if s[i].name != s[j].name {
+		return s[i].name < s[j].name
+	}
+	return s[i].id < s[j].id
+}
+
+// GET on a bucket with the uploads parameter lists the multipart uploads
+// in progress.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/mpUploadListMPUpload.html
+func (r bucketResource) listUploads(a *action) interface{} {
+	prefix := a.req.Form.Get("prefix")
+	delimiter := a.req.Form.Get("delimiter")
+	keyMarker := a.req.Form.Get("key-marker")
+	idMarker := a.req.Form.Get("upload-id-marker")
+	resp := &listMultipartUploadsResult{
+		Bucket:         r.bucket.name,
+		KeyMarker:      keyMarker,
+		UploadIdMarker: idMarker,
+		Prefix:         prefix,
+		Delimiter:      delimiter,
+		MaxUploads:     maxKeysParam(a, "max-uploads"),
+	}
+	a.w.Header().Set("Content-Type", "application/xml")
+
+	var uploads orderedUploads
+	for _, u := range r.bucket.uploads {
+		if strings.HasPrefix(u.name, prefix) {
+			uploads = append(uploads, u)
+		}
+	}
+	sort.Sort(uploads)
+
+	n := 0
+	for _, u := range uploads {
+		if u.name < keyMarker || u.name == keyMarker && (idMarker == "" || u.id <= idMarker) {
+			continue
+		}
+		name, isPrefix := u.name, false
+		if delimiter != "" {
+			if i := strings.Index(u.name[len(prefix):], delimiter); i >= 0 {
+				name = u.name[:len(prefix)+i+len(delimiter)]
+				if name <= keyMarker || len(resp.CommonPrefixes) > 0 && resp.CommonPrefixes[len(resp.CommonPrefixes)-1] == name {
+					continue
+				}
+				isPrefix = true
+			}
+		}
+		if n == resp.MaxUploads {
+			resp.IsTruncated = true
+			break
+		}
+		n++
+		if isPrefix {
+			resp.CommonPrefixes = append(resp.CommonPrefixes, name)
+			resp.NextKeyMarker, resp.NextUploadIdMarker = name, ""
+			continue
+		}
+		resp.Uploads = append(resp.Uploads, listedUpload{
+			Key:          u.name,
+			UploadId:     u.id,
+			Initiator:    owner,
+			Owner:        owner,
+			StorageClass: storageClass(u.meta),
+			Initiated:    u.initiated.Format(timeFormat),
+		})
+		resp.NextKeyMarker, resp.NextUploadIdMarker = u.name, u.id
+	}
+	if !resp.IsTruncated {
+		resp.NextKeyMarker, resp.NextUploadIdMarker = "", ""
+	}
+	return resp
+}
+
+// POST on an object with the uploads parameter initiates a multipart
+// upload of it.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/mpUploadInitiate.html
+func (objr objectResource) initiateUpload(a *action) interface{} {
+	meta := objectMeta(a)
+	acl := cannedACL(s3.ACL(a.req.Header.Get("x-amz-acl")))
+	u := &multipartUpload{
+		id:        objr.bucket.newId(),
+		name:      objr.name,
+		initiated: time.Now(),
+		meta:      meta,
+		acl:       acl,
+		parts:     make(map[int]*part),
+	}
+	objr.bucket.uploads[u.id] = u
+	return &initiateMultipartUploadResult{
+		Bucket:   objr.bucket.name,
+		Key:      objr.name,
+		UploadId: u.id,
+	}
+}
+
+// upload returns the multipart upload of the object named by the uploadId
+// parameter of the request.
+func (objr objectResource) upload(a *action) *multipartUpload {
+	u := objr.bucket.uploads[a.req.Form.Get("uploadId")]
+	if u == nil || u.name != objr.name {
+		fatalf(404, "NoSuchUpload", "The specified upload does not exist. The upload ID may be invalid, or the upload may have been aborted or completed.")
+	}
+	return u
+}
+
+// PUT on an object with the uploadId and partNumber parameters uploads
+// a part of a multipart upload, or copies it from another object if
+// x-amz-copy-source is set.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/mpUploadUploadPart.html
+func (objr objectResource) putPart(a *action) interface{} {
+	u := objr.upload(a)
+	n, err := strconv.Atoi(a.req.Form.Get("partNumber"))
+	if err != nil || n < 1 || n > s3.MaxUploadParts {
+		fatalf(400, "InvalidArgument", "Part number must be an integer between 1 and %d, inclusive", s3.MaxUploadParts)
+	}
+	if sseCustomerKeyMD5(a, "X-Amz-") != u.meta.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5") {
+		fatalf(400, "InvalidRequest", "The multipart upload initiate requested encryption. Subsequent part requests must include the appropriate encryption parameters.")
+	}
+	copied := a.req.Header.Get("X-Amz-Copy-Source") != ""
+	var data []byte
+	if copied {
+		data = a.srv.copySource(a).content()
+		if r := a.req.Header.Get("X-Amz-Copy-Source-Range"); r != "" {
+			start, end, ok := parseRange(r, int64(len(data)))
+			if !ok {
+				fatalf(400, "InvalidArgument", "The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy")
+			}
+			data = data[start : end+1]
+		}
+	} else {
+		// TODO avoid holding lock while reading data.
+		data = readBody(a)
+	}
+	sum := md5.Sum(data)
+	p := &part{
+		data:     data,
+		checksum: sum[:],
+		mtime:    time.Now(),
+	}
+	u.parts[n] = p
+	if copied {
+		return &copyPartResult{
+			ETag:         p.etag(),
+			LastModified: p.mtime.Format(timeFormat),
+		}
+	}
+	a.w.Header().Set("ETag", p.etag())
+	return nil
+}
+
+// GET on an object with the uploadId parameter lists the parts of a
+// multipart upload.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/mpUploadListParts.html
+func (objr objectResource) listParts(a *action) interface{} {
+	u := objr.upload(a)
+	marker := 0
+	if s := a.req.Form.Get("part-number-marker"); s != "" {
+		var err error
+		marker, err = strconv.Atoi(s)
+		if err != nil || marker < 0 {
+			fatalf(400, "InvalidArgument", "invalid value for part-number-marker: %q", s)
+		}
+	}
+	resp := &listPartsResult{
+		Bucket:           objr.bucket.name,
+		Key:              objr.name,
+		UploadId:         u.id,
+		PartNumberMarker: marker,
+		MaxParts:         maxKeysParam(a, "max-parts"),
+	}
+	a.w.Header().Set("Content-Type", "application/xml")
+
+	var numbers []int
+	for n := range u.parts {
+		if n > marker {
+			numbers = append(numbers, n)
+		}
+	}
+	sort.Ints(numbers)
+	for _, n := range numbers {
+		if len(resp.Parts) == resp.MaxParts {
+			resp.IsTruncated = true
+			break
+		}
+		p := u.parts[n]
+		resp.Parts = append(resp.Parts, listedPart{
+			PartNumber:   n,
+			LastModified: p.mtime.Format(timeFormat),
+			ETag:         p.etag(),
+			Size:         int64(len(p.data)),
+		})
+		resp.NextPartNumberMarker = n
+	}
+	return resp
+}
+
+// POST on an object with the uploadId parameter completes a multipart
+// upload, storing the object made of the parts listed in the body, which
+// must all have been uploaded with the ETags given.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/mpUploadComplete.html
+func (objr objectResource) completeUpload(a *action) interface{} {
+	u := objr.upload(a)
+	body, err := ioutil.ReadAll(a.req.Body)
+	if err != nil {
+		fatalf(400, "TODO", "read error")
+	}
+	var req struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+	if err := xml.Unmarshal(body, &req); err != nil || len(req.Parts) == 0 {
+		fatalf(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	var data []byte
+	sums := md5.New()
+	for i, cp := range req.Parts {
+		if i > 0 && cp.PartNumber <= req.Parts[i-1].PartNumber {
+			fatalf(400, "InvalidPartOrder", "The list of parts was not in ascending order. The parts list must be specified in order by part number.")
+		}
+		p := u.parts[cp.PartNumber]
+		if p == nil || strings.Trim(cp.ETag, `"`) != hex.EncodeToString(p.checksum) {
+			fatalf(400, "InvalidPart", "One or more of the specified parts could not be found. The part might not have been uploaded, or the specified entity tag might not have matched the part's entity tag.")
+		}
+		if i < len(req.Parts)-1 && len(p.data) < minPartSize {
+			fatalf(400, "EntityTooSmall", "Your proposed upload is smaller than the minimum allowed object size.")
+		}
+		data = append(data, p.data...)
+		sums.Write(p.checksum)
+	}
+	sum := md5.Sum(data)
+	obj := &object{
+		name:     objr.name,
+		mtime:    time.Now(),
+		meta:     u.meta,
+		checksum: sum[:],
+		// The ETag of an object uploaded in parts is that of the MD5
+		// sums of its parts, followed by their number.
+		etag: fmt.Sprintf("%x-%d", sums.Sum(nil), len(req.Parts)),
+		data: data,
+		size: int64(len(data)),
+		acl:  u.acl,
+	}
+	objr.bucket.addVersion(obj)
+	delete(objr.bucket.uploads, u.id)
+	objr.bucket.setVersionHeader(a, obj)
+	return &completeMultipartUploadResult{
+		Location: a.srv.URL() + "/" + objr.bucket.name + "/" + objr.name,
+		Bucket:   objr.bucket.name,
+		Key:      objr.name,
+		ETag:     `"` + obj.etag + `"`,
+	}
+}
+
+// DELETE on an object with the uploadId parameter aborts a multipart
+// upload, discarding its parts.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/mpUploadAbort.html
+func (objr objectResource) abortUpload(a *action) interface{} {
+	u := objr.upload(a)
+	delete(objr.bucket.uploads, u.id)
+	a.w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -3,10 +3,14 @@ package s3test
 import (
 	"bytes"
 	"crypto/md5"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"github.com/hailocab/goamz/aws"
 	"github.com/hailocab/goamz/s3"
+	"github.com/hailocab/goamz/testutil/faults"
 	"io"
 	"io/ioutil"
 	"log"
@@ -31,6 +35,12 @@ type s3Error struct {
 	BucketName string
 	RequestId  string
 	HostId     string
+
+	// Sent when a request is not signed as expected.
+	AWSAccessKeyId    string `xml:",omitempty"`
+	StringToSign      string `xml:",omitempty"`
+	SignatureProvided string `xml:",omitempty"`
+	CanonicalRequest  string `xml:",omitempty"`
 }
 
 type action struct {
@@ -38,6 +48,10 @@ type action struct {
 	w     http.ResponseWriter
 	req   *http.Request
 	reqId string
+
+	// chunks checks the signatures of the chunks of a payload streamed
+	// with signature version 4.
+	chunks *v4Signature
 }
 
 // Config controls the internal behaviour of the Server. A nil config is the default
@@ -50,6 +64,26 @@ type Config struct {
 	// all other regions.
 	// http://docs.amazonwebservices.com/AmazonS3/latest/API/ErrorResponses.html
 	Send409Conflict bool
+
+	// Faults, if set, injects faults into the responses of the Server.
+	// It may be changed later with SetFaults.
+	Faults *faults.Injector
+
+	// Dir, if set, is a directory in which the Server keeps its buckets
+	// and objects, instead of memory, so that they are found again by
+	// the next Server started with it. The data of objects is read from
+	// disk as it is requested.
+	Dir string
+
+	// Addr is the address the Server listens on. The default is a free
+	// port on localhost.
+	Addr string
+
+	// Auth, if set, holds the credentials requests must be signed with,
+	// using signature version 2 or 4, in their headers or their URL.
+	// Anonymous requests are then only allowed where ACLs grant all
+	// users access. By default, signatures are not checked.
+	Auth []aws.Auth
 }
 
 func (c *Config) send409Conflict() bool {
@@ -59,8 +93,30 @@ func (c *Config) send409Conflict() bool {
 	return false
 }
 
+func (c *Config) faults() *faults.Injector {
+	if c != nil {
+		return c.Faults
+	}
+	return nil
+}
+
+func (c *Config) store() *diskStore {
+	if c != nil && c.Dir != "" {
+		return &diskStore{dir: c.Dir}
+	}
+	return nil
+}
+
+func (c *Config) addr() string {
+	if c != nil && c.Addr != "" {
+		return c.Addr
+	}
+	return "localhost:0"
+}
+
 // Server is a fake S3 server for testing purposes.
-// All of the data for the server is kept in memory.
+// All of the data for the server is kept in memory,
+// unless a directory is given in its Config.
 type Server struct {
 	url      string
 	reqId    int
@@ -68,13 +124,32 @@ type Server struct {
 	mu       sync.Mutex
 	buckets  map[string]*bucket
 	config   *Config
+	faults   *faults.Injector
+	store    *diskStore
 }
 
 type bucket struct {
 	name    string
-	acl     s3.ACL
+	acl     s3.AccessControlPolicy
 	ctime   time.Time
 	objects map[string]*object
+
+	// versions holds the versions of each object, oldest first. The
+	// latest one may be a delete marker, in which case the object is
+	// missing from objects. Objects stored while versioning was never
+	// enabled have the single version "null".
+	versions map[string][]*object
+	lastId   int
+
+	// uploads holds the multipart uploads in progress, by upload ID.
+	uploads map[string]*multipartUpload
+
+	// config holds the configuration documents set with PUT on the
+	// subresources in bucketConfigs, by subresource name.
+	config map[string][]byte
+
+	// store keeps the bucket on disk, if it is not only held in memory.
+	store *diskStore
 }
 
 type object struct {
@@ -82,7 +157,14 @@ type object struct {
 	mtime    time.Time
 	meta     http.Header // metadata to return with requests.
 	checksum []byte      // also held as Content-MD5 in meta.
-	data     []byte
+	etag     string      // hex-encoded checksum, or that of the parts.
+	data     []byte      // nil if kept on disk.
+	size     int64
+	path     string // file holding the data, if kept on disk.
+	acl      s3.AccessControlPolicy
+
+	versionId    string
+	deleteMarker bool
 }
 
 // A resource encapsulates the subject of an HTTP request.
@@ -96,22 +178,44 @@ type resource interface {
 }
 
 func NewServer(config *Config) (*Server, error) {
-	l, err := net.Listen("tcp", "localhost:0")
+	buckets := make(map[string]*bucket)
+	store := config.store()
+	if store != nil {
+		var err error
+		buckets, err = store.load()
+		if err != nil {
+			return nil, fmt.Errorf("cannot load buckets from %s: %v", store.dir, err)
+		}
+	}
+	l, err := net.Listen("tcp", config.addr())
 	if err != nil {
-		return nil, fmt.Errorf("cannot listen on localhost: %v", err)
+		return nil, fmt.Errorf("cannot listen on %s: %v", config.addr(), err)
 	}
 	srv := &Server{
 		listener: l,
 		url:      "http://" + l.Addr().String(),
-		buckets:  make(map[string]*bucket),
+		buckets:  buckets,
 		config:   config,
+		faults:   config.faults(),
+		store:    store,
 	}
 	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		srv.serveHTTP(w, req)
+		srv.mu.Lock()
+		inj := srv.faults
+		srv.mu.Unlock()
+		inj.Handler(http.HandlerFunc(srv.serveHTTP), writeFault).ServeHTTP(w, req)
 	}))
 	return srv, nil
 }
 
+// SetFaults makes the server inject the faults of inj into its
+// responses. If inj is nil, no faults are injected.
+func (srv *Server) SetFaults(inj *faults.Injector) {
+	srv.mu.Lock()
+	srv.faults = inj
+	srv.mu.Unlock()
+}
+
 // Quit closes down the server.
 func (srv *Server) Quit() {
 	srv.listener.Close()
@@ -161,16 +265,18 @@ func (srv *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
 			}
 			err.RequestId = a.reqId
 			// TODO HostId
-			w.Header().Set("Content-Type", `xml version="1.0" encoding="UTF-8"`)
-			w.WriteHeader(err.statusCode)
-			xmlMarshal(w, err)
+			writeError(w, err)
 		case nil:
 		default:
 			panic(err)
 		}
 	}()
 
+	signed := srv.authenticate(a)
 	r = srv.resourceForURL(req.URL)
+	if !signed {
+		checkAnonymous(a, r)
+	}
 
 	var resp interface{}
 	switch req.Method {
@@ -190,6 +296,21 @@ func (srv *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+func writeError(w http.ResponseWriter, err *s3Error) {
+	w.Header().Set("Content-Type", `xml version="1.0" encoding="UTF-8"`)
+	w.WriteHeader(err.statusCode)
+	xmlMarshal(w, err)
+}
+
+// writeFault writes an error injected by the faults of the server.
+func writeFault(w http.ResponseWriter, status int, code, message string) {
+	writeError(w, &s3Error{
+		statusCode: status,
+		Code:       code,
+		Message:    message,
+	})
+}
+
 // xmlMarshal is the same as xml.Marshal except that
 // it panics on error. The marshalling should not fail,
 // but we want to know if it does.
@@ -202,24 +323,15 @@ func xmlMarshal(w io.Writer, x interface{}) {
 // In a fully implemented test server, each of these would have
 // its own resource type.
 var unimplementedBucketResourceNames = map[string]bool{
-	"acl":            true,
-	"lifecycle":      true,
-	"policy":         true,
 	"location":       true,
 	"logging":        true,
 	"notification":   true,
-	"versions":       true,
 	"requestPayment": true,
-	"versioning":     true,
 	"website":        true,
-	"uploads":        true,
 }
 
 var unimplementedObjectResourceNames = map[string]bool{
-	"uploadId": true,
-	"acl":      true,
-	"torrent":  true,
-	"uploads":  true,
+	"torrent": true,
 }
 
 var pathRegexp = regexp.MustCompile("/(([^/]+)(/(.*))?)?")
@@ -240,7 +352,22 @@ func (srv *Server) resourceForURL(u *url.URL) (r resource) {
 		bucket: srv.buckets[bucketName],
 	}
 	q := u.Query()
+	_, acl := q["acl"]
 	if objectName == "" {
+		if acl {
+			if b.bucket == nil {
+				fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
+			}
+			return aclResource{acl: &b.bucket.acl, bucket: b.bucket}
+		}
+		for name := range q {
+			if _, ok := bucketConfigs[name]; ok {
+				if b.bucket == nil {
+					fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
+				}
+				return bucketConfigResource{b.bucket, name}
+			}
+		}
 		for name := range q {
 			if unimplementedBucketResourceNames[name] {
 				return nullResource{}
@@ -262,9 +389,17 @@ func (srv *Server) resourceForURL(u *url.URL) (r resource) {
 			return nullResource{}
 		}
 	}
-	if obj := objr.bucket.objects[objr.name]; obj != nil {
+	if objr.version != "" {
+		objr.object = objr.bucket.version(objr.name, objr.version)
+	} else if obj := objr.bucket.objects[objr.name]; obj != nil {
 		objr.object = obj
 	}
+	if acl {
+		if objr.object == nil || objr.object.deleteMarker {
+			fatalf(404, "NoSuchKey", "The specified key does not exist.")
+		}
+		return aclResource{acl: &objr.object.acl, bucket: b.bucket, object: objr.object}
+	}
 	return objr
 }
 
@@ -294,17 +429,30 @@ func (r bucketResource) get(a *action) interface{} {
 	if r.bucket == nil {
 		fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
 	}
+	if _, ok := a.req.Form["versions"]; ok {
+		return r.listVersions(a)
+	}
+	if _, ok := a.req.Form["uploads"]; ok {
+		return r.listUploads(a)
+	}
 	delimiter := a.req.Form.Get("delimiter")
 	marker := a.req.Form.Get("marker")
-	maxKeys := -1
-	if s := a.req.Form.Get("max-keys"); s != "" {
-		i, err := strconv.Atoi(s)
-		if err != nil || i < 0 {
-			fatalf(400, "invalid value for max-keys: %q", s)
+	maxKeys := maxKeysParam(a, "max-keys")
+	prefix := a.req.Form.Get("prefix")
+	v2 := a.req.Form.Get("list-type") == "2"
+	token := a.req.Form.Get("continuation-token")
+	if v2 {
+		marker = a.req.Form.Get("start-after")
+		if token != "" {
+			// Continuation tokens are opaque to clients; ours simply
+			// hold the last name listed.
+			m, err := base64.StdEncoding.DecodeString(token)
+			if err != nil {
+				fatalf(400, "InvalidArgument", "The continuation token provided is incorrect")
+			}
+			marker = string(m)
 		}
-		maxKeys = i
 	}
-	prefix := a.req.Form.Get("prefix")
 	a.w.Header().Set("Content-Type", "application/xml")
 
 	if a.req.Method == "HEAD" {
@@ -321,9 +469,6 @@ func (r bucketResource) get(a *action) interface{} {
 	}
 	sort.Sort(objs)
 
-	if maxKeys <= 0 {
-		maxKeys = 1000
-	}
 	resp := &s3.ListResp{
 		Name:      r.bucket.name,
 		Prefix:    prefix,
@@ -333,6 +478,7 @@ func (r bucketResource) get(a *action) interface{} {
 	}
 
 	var prefixes []string
+	var last string
 	for _, obj := range objs {
 		if !strings.HasPrefix(obj.name, prefix) {
 			continue
@@ -361,8 +507,30 @@ func (r bucketResource) get(a *action) interface{} {
 			// Contents contains only keys not found in CommonPrefixes
 			resp.Contents = append(resp.Contents, obj.s3Key())
 		}
+		last = name
 	}
 	resp.CommonPrefixes = prefixes
+	if v2 {
+		resp2 := &s3.ListV2Resp{
+			Name:              resp.Name,
+			Prefix:            prefix,
+			Delimiter:         delimiter,
+			StartAfter:        a.req.Form.Get("start-after"),
+			MaxKeys:           maxKeys,
+			KeyCount:          len(resp.Contents) + len(prefixes),
+			ContinuationToken: token,
+			IsTruncated:       resp.IsTruncated,
+			Contents:          resp.Contents,
+			CommonPrefixes:    prefixes,
+		}
+		if resp.IsTruncated {
+			resp2.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(last))
+		}
+		return resp2
+	}
+	if resp.IsTruncated && delimiter != "" {
+		resp.NextMarker = last
+	}
 	return resp
 }
 
@@ -384,29 +552,40 @@ func (obj *object) s3Key() s3.Key {
 	return s3.Key{
 		Key:          obj.name,
 		LastModified: obj.mtime.Format(timeFormat),
-		Size:         int64(len(obj.data)),
-		ETag:         fmt.Sprintf(`"%x"`, obj.checksum),
-		// TODO StorageClass
+		Size:         obj.size,
+		ETag:         `"` + obj.etag + `"`,
+		StorageClass: storageClass(obj.meta),
 		// TODO Owner
 	}
 }
 
+// storageClass returns the storage class of an object with the given
+// metadata.
+func storageClass(meta http.Header) string {
+	if class := meta.Get("X-Amz-Storage-Class"); class != "" {
+		return class
+	}
+	return "STANDARD"
+}
+
 // DELETE on a bucket deletes the bucket if it's not empty.
 func (r bucketResource) delete(a *action) interface{} {
 	b := r.bucket
 	if b == nil {
 		fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
 	}
-	if len(b.objects) > 0 {
+	if len(b.versions) > 0 {
 		fatalf(400, "BucketNotEmpty", "The bucket you tried to delete is not empty")
 	}
 	delete(a.srv.buckets, b.name)
+	b.store.removeBucket(b)
 	return nil
 }
 
 // PUT on a bucket creates the bucket.
 // http://docs.amazonwebservices.com/AmazonS3/latest/API/RESTBucketPUT.html
 func (r bucketResource) put(a *action) interface{} {
+	acl := cannedACL(s3.ACL(a.req.Header.Get("x-amz-acl")))
 	var created bool
 	if r.bucket == nil {
 		if !validBucketName(r.name) {
@@ -415,11 +594,13 @@ func (r bucketResource) put(a *action) interface{} {
 		if loc := locationConstraint(a); loc == "" {
 			fatalf(400, "InvalidRequets", "The unspecified location constraint is incompatible for the region specific endpoint this request was sent to.")
 		}
-		// TODO validate acl
 		r.bucket = &bucket{
-			name: r.name,
-			// TODO default acl
-			objects: make(map[string]*object),
+			name:     r.name,
+			ctime:    time.Now(),
+			objects:  make(map[string]*object),
+			versions: make(map[string][]*object),
+			uploads:  make(map[string]*multipartUpload),
+			store:    a.srv.store,
 		}
 		a.srv.buckets[r.name] = r.bucket
 		created = true
@@ -427,15 +608,290 @@ func (r bucketResource) put(a *action) interface{} {
 	if !created && a.srv.config.send409Conflict() {
 		fatalf(409, "BucketAlreadyOwnedByYou", "Your previous request to create the named bucket succeeded and you already own it.")
 	}
-	r.bucket.acl = s3.ACL(a.req.Header.Get("x-amz-acl"))
+	r.bucket.acl = acl
+	r.bucket.store.saveBucket(r.bucket)
 	return nil
 }
 
-func (bucketResource) post(a *action) interface{} {
+func (r bucketResource) post(a *action) interface{} {
+	if _, ok := a.req.Form["delete"]; ok {
+		return r.deleteObjects(a)
+	}
 	fatalf(400, "Method", "bucket POST method not available")
 	return nil
 }
 
+// POST on a bucket with the delete parameter deletes multiple objects.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/multiobjectdeleteapi.html
+func (r bucketResource) deleteObjects(a *action) interface{} {
+	if r.bucket == nil {
+		fatalf(404, "NoSuchBucket", "The specified bucket does not exist")
+	}
+	data, err := ioutil.ReadAll(a.req.Body)
+	if err != nil {
+		fatalf(400, "TODO", "read error")
+	}
+	if c := a.req.Header.Get("Content-MD5"); c != "" {
+		sum := md5.Sum(data)
+		if c != base64.StdEncoding.EncodeToString(sum[:]) {
+			fatalf(400, "BadDigest", "The Content-MD5 you specified did not match what we received")
+		}
+	} else {
+		fatalf(400, "InvalidRequest", "Missing required header for this request: Content-MD5")
+	}
+	var req struct {
+		Quiet   bool
+		Objects []s3.ObjectId `xml:"Object"`
+	}
+	if err := xml.Unmarshal(data, &req); err != nil {
+		fatalf(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	if len(req.Objects) > 1000 {
+		fatalf(400, "MalformedXML", "The request must not contain more than 1000 objects")
+	}
+	resp := &s3.DeleteResp{}
+	for _, id := range req.Objects {
+		deleted := s3.DeletedObject{Key: id.Key, VersionId: id.VersionId}
+		if id.VersionId != "" {
+			if obj := r.bucket.deleteVersion(id.Key, id.VersionId); obj != nil && obj.deleteMarker {
+				deleted.DeleteMarker = true
+				deleted.DeleteMarkerVersionId = obj.versionId
+			}
+		} else if marker := r.bucket.deleteObject(id.Key); marker != nil {
+			deleted.DeleteMarker = true
+			deleted.DeleteMarkerVersionId = marker.versionId
+		}
+		if !req.Quiet {
+			resp.Deleted = append(resp.Deleted, deleted)
+		}
+	}
+	return resp
+}
+
+// maxKeysParam returns the value of the request parameter name, which
+// limits the number of entries listed, or 1000 if it is not set.
+func maxKeysParam(a *action, name string) int {
+	s := a.req.Form.Get(name)
+	if s == "" {
+		return 1000
+	}
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 {
+		fatalf(400, "InvalidArgument", "invalid value for %s: %q", name, s)
+	}
+	if i == 0 {
+		return 1000
+	}
+	return i
+}
+
+// bucketConfig describes a bucket subresource holding a configuration
+// document.
+type bucketConfig struct {
+	// errCode and errMessage make up the error returned by GET when no
+	// document has been set. If errCode is empty, emptyDoc is returned
+	// instead.
+	errCode    string
+	errMessage string
+	emptyDoc   string
+	json       bool
+}
+
+var bucketConfigs = map[string]bucketConfig{
+	"cors":       {errCode: "NoSuchCORSConfiguration", errMessage: "The CORS configuration does not exist"},
+	"lifecycle":  {errCode: "NoSuchLifecycleConfiguration", errMessage: "The lifecycle configuration does not exist"},
+	"policy":     {errCode: "NoSuchBucketPolicy", errMessage: "The bucket policy does not exist", json: true},
+	"tagging":    {errCode: "NoSuchTagSet", errMessage: "The TagSet does not exist"},
+	"versioning": {emptyDoc: `<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"/>`},
+}
+
+// bucketConfigResource is a configuration subresource of a bucket, such
+// as ?lifecycle. The documents set are checked to be well formed, and
+// returned as they are.
+type bucketConfigResource struct {
+	bucket *bucket
+	name   string
+}
+
+func (r bucketConfigResource) get(a *action) interface{} {
+	config := bucketConfigs[r.name]
+	doc, ok := r.bucket.config[r.name]
+	if !ok {
+		if config.errCode != "" {
+			fatalf(404, config.errCode, "%s", config.errMessage)
+		}
+		doc = []byte(config.emptyDoc)
+	}
+	if config.json {
+		a.w.Header().Set("Content-Type", "application/json")
+	} else {
+		a.w.Header().Set("Content-Type", "application/xml")
+	}
+	a.w.Write(doc)
+	return nil
+}
+
+func (r bucketConfigResource) put(a *action) interface{} {
+	doc, err := ioutil.ReadAll(a.req.Body)
+	if err != nil {
+		fatalf(400, "TODO", "read error")
+	}
+	if c := a.req.Header.Get("Content-MD5"); c != "" {
+		sum := md5.Sum(doc)
+		if c != base64.StdEncoding.EncodeToString(sum[:]) {
+			fatalf(400, "BadDigest", "The Content-MD5 you specified did not match what we received")
+		}
+	} else if r.name != "policy" && r.name != "versioning" {
+		fatalf(400, "InvalidRequest", "Missing required header for this request: Content-MD5")
+	}
+	if bucketConfigs[r.name].json {
+		if !json.Valid(doc) {
+			fatalf(400, "MalformedPolicy", "Policies must be valid JSON")
+		}
+	} else {
+		var v struct {
+			Status string
+		}
+		if err := xml.Unmarshal(doc, &v); err != nil {
+			fatalf(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+		}
+		if r.name == "versioning" && v.Status != s3.VersioningEnabled && v.Status != s3.VersioningSuspended {
+			fatalf(400, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema")
+		}
+	}
+	if r.bucket.config == nil {
+		r.bucket.config = make(map[string][]byte)
+	}
+	r.bucket.config[r.name] = doc
+	r.bucket.store.saveBucket(r.bucket)
+	return nil
+}
+
+func (r bucketConfigResource) delete(a *action) interface{} {
+	if r.name == "versioning" {
+		return notAllowed()
+	}
+	delete(r.bucket.config, r.name)
+	r.bucket.store.saveBucket(r.bucket)
+	a.w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (r bucketConfigResource) post(a *action) interface{} {
+	return notAllowed()
+}
+
+// owner owns all the buckets and objects in the server.
+var owner = s3.Owner{
+	ID:          "bcaf1ffd86f41161ca5fb16fd081034f",
+	DisplayName: "s3test",
+}
+
+// cannedACL returns the access control policy described by acl, as sent
+// in the x-amz-acl header. As everything has the same owner, the
+// bucket-owner-* ACLs are the same as the private one.
+// http://docs.aws.amazon.com/AmazonS3/latest/dev/acl-overview.html#canned-acl
+func cannedACL(acl s3.ACL) s3.AccessControlPolicy {
+	policy := s3.AccessControlPolicy{Owner: owner}
+	ownerGrantee := s3.CanonicalUser(owner.ID)
+	ownerGrantee.DisplayName = owner.DisplayName
+	policy.AddGrant(ownerGrantee, s3.PermFullControl)
+	switch acl {
+	case "", s3.Private, s3.BucketOwnerRead, s3.BucketOwnerFull:
+	case s3.PublicRead:
+		policy.AddGrant(s3.Group(s3.AllUsersGroup), s3.PermRead)
+	case s3.PublicReadWrite:
+		policy.AddGrant(s3.Group(s3.AllUsersGroup), s3.PermRead)
+		policy.AddGrant(s3.Group(s3.AllUsersGroup), s3.PermWrite)
+	case s3.AuthenticatedRead:
+		policy.AddGrant(s3.Group(s3.AuthenticatedUsersGroup), s3.PermRead)
+	default:
+		fatalf(400, "InvalidArgument", "Invalid canned ACL %q", acl)
+	}
+	return policy
+}
+
+// aclResource is the ?acl subresource of a bucket or object.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectGETacl.html
+type aclResource struct {
+	acl    *s3.AccessControlPolicy
+	bucket *bucket // always non-nil.
+	object *object // nil for the ACL of the bucket.
+}
+
+// save saves the ACL along with the bucket or object it belongs to.
+func (r aclResource) save() {
+	if r.object != nil {
+		r.bucket.store.saveObject(r.bucket, r.object)
+	} else {
+		r.bucket.store.saveBucket(r.bucket)
+	}
+}
+
+func (r aclResource) get(a *action) interface{} {
+	return r.acl
+}
+
+// PUT on an ACL replaces it with the canned ACL in the x-amz-acl header
+// or, failing that, with the access control policy in the body.
+func (r aclResource) put(a *action) interface{} {
+	if canned := a.req.Header.Get("x-amz-acl"); canned != "" {
+		*r.acl = cannedACL(s3.ACL(canned))
+		r.save()
+		return nil
+	}
+	data, err := ioutil.ReadAll(a.req.Body)
+	if err != nil {
+		fatalf(400, "TODO", "read error")
+	}
+	var policy s3.AccessControlPolicy
+	if err := xml.Unmarshal(data, &policy); err != nil {
+		fatalf(400, "MalformedACLError", "The XML you provided was not well-formed or did not validate against our published schema")
+	}
+	if policy.Owner.ID != owner.ID {
+		fatalf(403, "AccessDenied", "Access Denied")
+	}
+	for _, g := range policy.Grants {
+		if !validGrant(g) {
+			fatalf(400, "MalformedACLError", "The XML you provided was not well-formed or did not validate against our published schema")
+		}
+	}
+	policy.Owner = owner
+	*r.acl = policy
+	r.save()
+	return nil
+}
+
+func (r aclResource) delete(a *action) interface{} {
+	return notAllowed()
+}
+
+func (r aclResource) post(a *action) interface{} {
+	return notAllowed()
+}
+
+// validGrant returns whether g names a grantee of its type and a known
+// permission.
+func validGrant(g s3.Grant) bool {
+	switch g.Permission {
+	case s3.PermFullControl, s3.PermRead, s3.PermWrite, s3.PermReadACP, s3.PermWriteACP:
+	default:
+		return false
+	}
+	switch g.Grantee.Type {
+	case s3.GranteeCanonicalUser:
+		return g.Grantee.ID != ""
+	case s3.GranteeEmail:
+		return g.Grantee.EmailAddress != ""
+	case s3.GranteeGroup:
+		switch g.Grantee.URI {
+		case s3.AllUsersGroup, s3.AuthenticatedUsersGroup, s3.LogDeliveryGroup:
+			return true
+		}
+	}
+	return false
+}
+
 // validBucketName returns whether name is a valid bucket name.
 // Here are the rules, from:
 // http://docs.amazonwebservices.com/AmazonS3/2006-03-01/dev/BucketRestrictions.html
@@ -492,11 +948,27 @@ type objectResource struct {
 // GET on an object gets the contents of the object.
 // http://docs.amazonwebservices.com/AmazonS3/latest/API/RESTObjectGET.html
 func (objr objectResource) get(a *action) interface{} {
+	if _, ok := a.req.Form["uploadId"]; ok {
+		return objr.listParts(a)
+	}
 	obj := objr.object
+	h := a.w.Header()
 	if obj == nil {
+		if objr.version != "" {
+			fatalf(404, "NoSuchVersion", "The specified version does not exist.")
+		}
+		if vs := objr.bucket.versions[objr.name]; len(vs) > 0 {
+			// The latest version is a delete marker.
+			h.Set("x-amz-delete-marker", "true")
+			objr.bucket.setVersionHeader(a, vs[len(vs)-1])
+		}
 		fatalf(404, "NoSuchKey", "The specified key does not exist.")
 	}
-	h := a.w.Header()
+	if obj.deleteMarker {
+		h.Set("x-amz-delete-marker", "true")
+		objr.bucket.setVersionHeader(a, obj)
+		fatalf(405, "MethodNotAllowed", "The specified method is not allowed against this resource.")
+	}
 	// add metadata
 	for name, d := range obj.meta {
 		h[name] = d
@@ -511,24 +983,39 @@ func (objr objectResource) get(a *action) interface{} {
 			h.Set(name, vals[0])
 		}
 	}
-	if r := a.req.Header.Get("Range"); r != "" {
-		fatalf(400, "NotImplemented", "range unimplemented")
+	if keyMD5 := obj.meta.Get("X-Amz-Server-Side-Encryption-Customer-Key-Md5"); keyMD5 != "" {
+		if sseCustomerKeyMD5(a, "X-Amz-") != keyMD5 {
+			fatalf(400, "InvalidRequest", "The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.")
+		}
+	}
+	h.Set("ETag", `"`+obj.etag+`"`)
+	h.Set("Last-Modified", obj.mtime.UTC().Format(http.TimeFormat))
+	objr.bucket.setVersionHeader(a, obj)
+	if !checkConditions(a, "", obj.etag, obj.mtime) {
+		// A 304 response has no body.
+		a.w.WriteHeader(http.StatusNotModified)
+		return nil
 	}
-	// TODO Last-Modified-Since
-	// TODO If-Modified-Since
-	// TODO If-Unmodified-Since
-	// TODO If-Match
-	// TODO If-None-Match
 	// TODO Connection: close ??
 	// TODO x-amz-request-id
-	h.Set("Content-Length", fmt.Sprint(len(obj.data)))
-	h.Set("ETag", hex.EncodeToString(obj.checksum))
-	h.Set("Last-Modified", obj.mtime.Format(time.RFC1123))
+	start, size := int64(0), obj.size
+	status := http.StatusOK
+	if r := a.req.Header.Get("Range"); r != "" {
+		first, last, ok := parseRange(r, obj.size)
+		if !ok {
+			fatalf(416, "InvalidRange", "The requested range is not satisfiable")
+		}
+		h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", first, last, obj.size))
+		start, size = first, last-first+1
+		status = http.StatusPartialContent
+	}
+	h.Set("Content-Length", fmt.Sprint(size))
 	if a.req.Method == "HEAD" {
 		return nil
 	}
+	a.w.WriteHeader(status)
 	// TODO avoid holding the lock when writing data.
-	_, err := a.w.Write(obj.data)
+	err := obj.writeRange(a.w, start, size)
 	if err != nil {
 		// we can't do much except just log the fact.
 		log.Printf("error writing data: %v", err)
@@ -536,72 +1023,311 @@ func (objr objectResource) get(a *action) interface{} {
 	return nil
 }
 
+// checkConditions checks the conditional headers of a request for an
+// object with the given ETag and modification time, failing with 412 if
+// If-Match or If-Unmodified-Since do not hold. It returns false if the
+// request should get a 304 response instead, as If-None-Match or
+// If-Modified-Since do not hold. The names of the headers checked start
+// with prefix, which is "x-amz-copy-source-" for the source of a copy.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectGET.html
+func checkConditions(a *action, prefix, etag string, mtime time.Time) bool {
+	// Last-Modified has a resolution of a second.
+	mtime = mtime.Truncate(time.Second)
+	since := func(name string) (time.Time, bool) {
+		t, err := http.ParseTime(a.req.Header.Get(prefix + name))
+		return t, err == nil
+	}
+	match := a.req.Header.Get(prefix + "If-Match")
+	if match != "" && strings.Trim(match, `"`) != etag {
+		fatalf(412, "PreconditionFailed", "At least one of the preconditions you specified did not hold.")
+	}
+	if t, ok := since("If-Unmodified-Since"); ok && match == "" && mtime.After(t) {
+		fatalf(412, "PreconditionFailed", "At least one of the preconditions you specified did not hold.")
+	}
+	noneMatch := a.req.Header.Get(prefix + "If-None-Match")
+	if noneMatch != "" && strings.Trim(noneMatch, `"`) == etag {
+		return false
+	}
+	if t, ok := since("If-Modified-Since"); ok && noneMatch == "" && !mtime.After(t) {
+		return false
+	}
+	return true
+}
+
+// sseCustomerKeyMD5 returns the MD5 sum of the customer-provided key for
+// server-side encryption sent with a request, checking it against the
+// one sent along with it. It returns "" if no key was sent. The names of
+// the headers checked start with prefix, which is "x-amz-" or, for the
+// source of a copy, "x-amz-copy-source-".
+func sseCustomerKeyMD5(a *action, prefix string) string {
+	key := a.req.Header.Get(prefix + "Server-Side-Encryption-Customer-Key")
+	if key == "" {
+		return ""
+	}
+	data, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(data) != 32 {
+		fatalf(400, "InvalidArgument", "The secret key was invalid for the specified algorithm.")
+	}
+	sum := md5.Sum(data)
+	keyMD5 := base64.StdEncoding.EncodeToString(sum[:])
+	if a.req.Header.Get(prefix+"Server-Side-Encryption-Customer-Key-Md5") != keyMD5 {
+		fatalf(400, "InvalidArgument", "The calculated MD5 hash of the key did not match the hash that was provided.")
+	}
+	return keyMD5
+}
+
+// parseRange parses a Range header holding a single byte range of an
+// object of the given size, and returns the first and last byte of the
+// range.
+func parseRange(r string, size int64) (start, end int64, ok bool) {
+	if !strings.HasPrefix(r, "bytes=") || strings.Contains(r, ",") {
+		return 0, 0, false
+	}
+	i := strings.Index(r, "-")
+	if i < 0 {
+		return 0, 0, false
+	}
+	first, last := r[len("bytes="):i], r[i+1:]
+	var err error
+	switch {
+	case first == "":
+		// The last bytes of the object.
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		start, end = size-n, size-1
+	case last == "":
+		start, err = strconv.ParseInt(first, 10, 64)
+		end = size - 1
+	default:
+		start, err = strconv.ParseInt(first, 10, 64)
+		if err == nil {
+			end, err = strconv.ParseInt(last, 10, 64)
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	if err != nil || start < 0 || start > end || start >= size {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
 var metaHeaders = map[string]bool{
-	"Content-MD5":         true,
-	"x-amz-acl":           true,
-	"Content-Type":        true,
-	"Content-Encoding":    true,
-	"Content-Disposition": true,
+	"Content-MD5":                     true,
+	"x-amz-acl":                       true,
+	"Content-Type":                    true,
+	"Content-Encoding":                true,
+	"Content-Disposition":             true,
+	"Cache-Control":                   true,
+	"Expires":                         true,
+	"X-Amz-Storage-Class":             true,
+	"X-Amz-Website-Redirect-Location": true,
+	"X-Amz-Server-Side-Encryption":    true,
+	"X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id":     true,
+	"X-Amz-Server-Side-Encryption-Customer-Algorithm": true,
+	"X-Amz-Server-Side-Encryption-Customer-Key-Md5":   true,
+}
+
+var storageClasses = map[string]bool{
+	"STANDARD":           true,
+	"STANDARD_IA":        true,
+	"REDUCED_REDUNDANCY": true,
+	"GLACIER":            true,
 }
 
-// PUT on an object creates the object.
+// PUT on an object creates the object, or copies another object to it
+// if x-amz-copy-source is set.
 func (objr objectResource) put(a *action) interface{} {
-	// TODO Cache-Control header
-	// TODO Expires header
-	// TODO x-amz-server-side-encryption
-	// TODO x-amz-storage-class
+	if _, ok := a.req.Form["uploadId"]; ok {
+		return objr.putPart(a)
+	}
+	if a.req.Header.Get("X-Amz-Copy-Source") != "" {
+		return objr.copy(a)
+	}
+	// A PUT replaces the object along with all its metadata.
+	meta := objectMeta(a)
+	acl := cannedACL(s3.ACL(a.req.Header.Get("x-amz-acl")))
+	// TODO avoid holding lock while reading data.
+	data := readBody(a)
 
-	// TODO is this correct, or should we erase all previous metadata?
-	obj := objr.object
-	if obj == nil {
-		obj = &object{
-			name: objr.name,
-			meta: make(http.Header),
+	// PUT request has been successful - save data and metadata
+	sum := md5.Sum(data)
+	obj := &object{
+		name:     objr.name,
+		mtime:    time.Now(),
+		meta:     meta,
+		checksum: sum[:],
+		etag:     hex.EncodeToString(sum[:]),
+		data:     data,
+		size:     int64(len(data)),
+		acl:      acl,
+	}
+	objr.bucket.addVersion(obj)
+	a.w.Header().Set("ETag", `"`+obj.etag+`"`)
+	objr.bucket.setVersionHeader(a, obj)
+	return nil
+}
+
+// objectMeta returns the metadata sent with a request storing an object,
+// checking that its storage class, tags and encryption key are valid.
+func objectMeta(a *action) http.Header {
+	class := a.req.Header.Get("X-Amz-Storage-Class")
+	if class != "" && !storageClasses[class] {
+		fatalf(400, "InvalidStorageClass", "The storage class you specified is not valid")
+	}
+	sseCustomerKeyMD5(a, "X-Amz-")
+	var tags url.Values
+	if t := a.req.Header.Get("X-Amz-Tagging"); t != "" {
+		var err error
+		tags, err = url.ParseQuery(t)
+		if err != nil {
+			fatalf(400, "InvalidArgument", "The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+		}
+	}
+	meta := make(http.Header)
+	for key, values := range a.req.Header {
+		key = http.CanonicalHeaderKey(key)
+		if metaHeaders[key] || strings.HasPrefix(key, "X-Amz-Meta-") {
+			meta[key] = values
+		}
+	}
+	if class == "STANDARD" {
+		// S3 leaves out the storage class of STANDARD objects.
+		meta.Del("X-Amz-Storage-Class")
+	}
+	if len(tags) > 0 {
+		meta.Set("X-Amz-Tagging-Count", strconv.Itoa(len(tags)))
+	}
+	if isChunked(a) {
+		// aws-chunked describes how the body was sent, not the object.
+		enc := strings.TrimPrefix(meta.Get("Content-Encoding"), "aws-chunked")
+		enc = strings.TrimPrefix(enc, ",")
+		if enc == "" {
+			meta.Del("Content-Encoding")
+		} else {
+			meta.Set("Content-Encoding", enc)
 		}
 	}
+	return meta
+}
+
+// isChunked returns whether the body of a request is sent with the
+// aws-chunked content encoding.
+func isChunked(a *action) bool {
+	return strings.HasPrefix(a.req.Header.Get("X-Amz-Content-Sha256"), "STREAMING-")
+}
 
+// readBody returns the content sent with a request storing an object or
+// part, checking it against the Content-MD5 and length headers.
+func readBody(a *action) []byte {
 	var expectHash []byte
 	if c := a.req.Header.Get("Content-MD5"); c != "" {
 		var err error
-		expectHash, err = hex.DecodeString(c)
+		expectHash, err = base64.StdEncoding.DecodeString(c)
 		if err != nil || len(expectHash) != md5.Size {
 			fatalf(400, "InvalidDigest", "The Content-MD5 you specified was invalid")
 		}
 	}
-	sum := md5.New()
-	// TODO avoid holding lock while reading data.
-	data, err := ioutil.ReadAll(io.TeeReader(a.req.Body, sum))
+	data, err := ioutil.ReadAll(a.req.Body)
 	if err != nil {
 		fatalf(400, "TODO", "read error")
 	}
-	gotHash := sum.Sum(nil)
-	if expectHash != nil && bytes.Compare(gotHash, expectHash) != 0 {
+	length := a.req.ContentLength
+	if isChunked(a) {
+		var check func(chunk []byte, signature string) bool
+		if a.chunks != nil {
+			check = a.chunks.checkChunk
+		}
+		data, err = decodeChunked(data, check)
+		if err == errChunkSignature {
+			fatalf(403, "SignatureDoesNotMatch", "The request signature we calculated does not match the signature you provided. Check your key and signing method.")
+		}
+		if err != nil {
+			fatalf(400, "IncompleteBody", "%v", err)
+		}
+		length, err = strconv.ParseInt(a.req.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64)
+		if err != nil {
+			fatalf(400, "MissingContentLength", "You must provide the X-Amz-Decoded-Content-Length HTTP header")
+		}
+	}
+	sum := md5.Sum(data)
+	if expectHash != nil && !bytes.Equal(sum[:], expectHash) {
 		fatalf(400, "BadDigest", "The Content-MD5 you specified did not match what we received")
 	}
-	if a.req.ContentLength >= 0 && int64(len(data)) != a.req.ContentLength {
+	if length >= 0 && int64(len(data)) != length {
 		fatalf(400, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header")
 	}
+	return data
+}
 
-	// PUT request has been successful - save data and metadata
-	for key, values := range a.req.Header {
-		key = http.CanonicalHeaderKey(key)
-		if metaHeaders[key] || strings.HasPrefix(key, "X-Amz-Meta-") {
-			obj.meta[key] = values
+// decodeChunked returns the payload carried by a body sent with the
+// aws-chunked content encoding. Chunk signatures are checked with check,
+// unless it is nil.
+func decodeChunked(body []byte, check func(chunk []byte, signature string) bool) ([]byte, error) {
+	var data []byte
+	for {
+		i := bytes.Index(body, []byte("\r\n"))
+		if i < 0 {
+			return nil, fmt.Errorf("malformed chunk header")
+		}
+		header, signature := string(body[:i]), ""
+		if j := strings.Index(header, ";"); j >= 0 {
+			header, signature = header[:j], strings.TrimPrefix(header[j+1:], "chunk-signature=")
+		}
+		size, err := strconv.ParseInt(header, 16, 64)
+		if err != nil || size < 0 || int64(len(body)-i-2) < size+2 {
+			return nil, fmt.Errorf("malformed chunk of size %q", header)
+		}
+		chunk := body[i+2 : i+2+int(size)]
+		if string(body[i+2+int(size):i+4+int(size)]) != "\r\n" {
+			return nil, fmt.Errorf("chunk is not terminated by CRLF")
+		}
+		if check != nil && !check(chunk, signature) {
+			return nil, errChunkSignature
+		}
+		if size == 0 {
+			return data, nil
 		}
+		data = append(data, chunk...)
+		body = body[i+4+int(size):]
 	}
-	obj.data = data
-	obj.checksum = gotHash
-	obj.mtime = time.Now()
-	objr.bucket.objects[objr.name] = obj
-	return nil
 }
 
+// DELETE on an object deletes it or, in a bucket where versioning is
+// configured, hides it behind a delete marker. With a versionId, it
+// deletes that version for good.
 func (objr objectResource) delete(a *action) interface{} {
-	delete(objr.bucket.objects, objr.name)
+	if _, ok := a.req.Form["uploadId"]; ok {
+		return objr.abortUpload(a)
+	}
+	h := a.w.Header()
+	if objr.version != "" {
+		if obj := objr.bucket.deleteVersion(objr.name, objr.version); obj != nil && obj.deleteMarker {
+			h.Set("x-amz-delete-marker", "true")
+		}
+		h.Set("x-amz-version-id", objr.version)
+	} else if marker := objr.bucket.deleteObject(objr.name); marker != nil {
+		h.Set("x-amz-delete-marker", "true")
+		h.Set("x-amz-version-id", marker.versionId)
+	}
+	a.w.WriteHeader(http.StatusNoContent)
 	return nil
 }
 
+// POST on an object initiates or completes a multipart upload of it.
 func (objr objectResource) post(a *action) interface{} {
+	if _, ok := a.req.Form["uploads"]; ok {
+		return objr.initiateUpload(a)
+	}
+	if _, ok := a.req.Form["uploadId"]; ok {
+		return objr.completeUpload(a)
+	}
 	fatalf(400, "MethodNotAllowed", "The specified method is not allowed against this resource")
 	return nil
 }
@@ -0,0 +1,216 @@
+package s3test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"github.com/hailocab/goamz/s3"
+	"sort"
+	"strings"
+	"time"
+)
+
+// versioning returns the versioning status of b: s3.VersioningEnabled,
+// s3.VersioningSuspended, or "" if versioning was never configured.
+func (b *bucket) versioning() string {
+	var v struct {
+		Status string
+	}
+	if doc, ok := b.config["versioning"]; ok {
+		xml.Unmarshal(doc, &v)
+	}
+	return v.Status
+}
+
+// newId returns a new identifier for an object version or multipart
+// upload in b. Later identifiers sort after earlier ones.
+func (b *bucket) newId() string {
+	b.lastId++
+	return fmt.Sprintf("%032X", b.lastId)
+}
+
+// addVersion makes obj, which may be a delete marker, the latest version
+// of the object it names. Unless versioning is enabled, it replaces the
+// version "null".
+func (b *bucket) addVersion(obj *object) {
+	vs := b.versions[obj.name]
+	if b.versioning() == s3.VersioningEnabled {
+		obj.versionId = b.newId()
+	} else {
+		obj.versionId = "null"
+		for i, v := range vs {
+			if v.versionId == "null" {
+				vs = append(vs[:i:i], vs[i+1:]...)
+				b.store.removeObject(b, v)
+				break
+			}
+		}
+	}
+	b.store.saveObject(b, obj)
+	b.versions[obj.name] = append(vs, obj)
+	b.setLatest(obj.name)
+}
+
+// setLatest updates objects with the latest version of the object called
+// name.
+func (b *bucket) setLatest(name string) {
+	vs := b.versions[name]
+	switch {
+	case len(vs) == 0:
+		delete(b.versions, name)
+		delete(b.objects, name)
+	case vs[len(vs)-1].deleteMarker:
+		delete(b.objects, name)
+	default:
+		b.objects[name] = vs[len(vs)-1]
+	}
+}
+
+// version returns the version id of the object called name, or nil if
+// there is none.
+func (b *bucket) version(name, id string) *object {
+	for _, v := range b.versions[name] {
+		if v.versionId == id {
+			return v
+		}
+	}
+	return nil
+}
+
+// deleteObject deletes the object called name, as a DELETE request
+// without a version does. If versioning was ever configured on b, the
+// object is hidden behind a delete marker, which is returned.
+func (b *bucket) deleteObject(name string) (marker *object) {
+	if b.versioning() == "" {
+		for _, v := range b.versions[name] {
+			b.store.removeObject(b, v)
+		}
+		delete(b.versions, name)
+		delete(b.objects, name)
+		return nil
+	}
+	marker = &object{
+		name:         name,
+		mtime:        time.Now(),
+		deleteMarker: true,
+	}
+	b.addVersion(marker)
+	return marker
+}
+
+// deleteVersion deletes the version id of the object called name for
+// good, and returns it. It returns nil if there is no such version.
+func (b *bucket) deleteVersion(name, id string) *object {
+	vs := b.versions[name]
+	for i, v := range vs {
+		if v.versionId == id {
+			b.store.removeObject(b, v)
+			b.versions[name] = append(vs[:i:i], vs[i+1:]...)
+			b.setLatest(name)
+			return v
+		}
+	}
+	return nil
+}
+
+// setVersionHeader sets the x-amz-version-id header of the response to
+// a request about obj, if versioning was ever configured on b.
+func (b *bucket) setVersionHeader(a *action, obj *object) {
+	if b.versioning() != "" {
+		a.w.Header().Set("x-amz-version-id", obj.versionId)
+	}
+}
+
+// GET on a bucket with the versions parameter lists the versions of the
+// objects in the bucket, ordered by key and then from the latest.
+// http://docs.aws.amazon.com/AmazonS3/latest/API/RESTBucketGETVersion.html
+func (r bucketResource) listVersions(a *action) interface{} {
+	prefix := a.req.Form.Get("prefix")
+	delimiter := a.req.Form.Get("delimiter")
+	keyMarker := a.req.Form.Get("key-marker")
+	versionMarker := a.req.Form.Get("version-id-marker")
+	resp := &s3.VersionsResp{
+		Name:            r.bucket.name,
+		Prefix:          prefix,
+		KeyMarker:       keyMarker,
+		VersionIdMarker: versionMarker,
+		MaxKeys:         maxKeysParam(a, "max-keys"),
+		Delimiter:       delimiter,
+	}
+	a.w.Header().Set("Content-Type", "application/xml")
+
+	var names []string
+	for name := range r.bucket.versions {
+		if strings.HasPrefix(name, prefix) && name >= keyMarker {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	n := 0
+	full := func() bool {
+		if n == resp.MaxKeys {
+			resp.IsTruncated = true
+			return true
+		}
+		n++
+		return false
+	}
+List:
+	for _, name := range names {
+		if delimiter != "" {
+			if i := strings.Index(name[len(prefix):], delimiter); i >= 0 {
+				p := name[:len(prefix)+i+len(delimiter)]
+				if p <= keyMarker || len(resp.CommonPrefixes) > 0 && resp.CommonPrefixes[len(resp.CommonPrefixes)-1] == p {
+					continue
+				}
+				if full() {
+					break List
+				}
+				resp.CommonPrefixes = append(resp.CommonPrefixes, p)
+				resp.NextKeyMarker, resp.NextVersionIdMarker = p, ""
+				continue
+			}
+		}
+		skip := name == keyMarker
+		if skip && versionMarker == "" {
+			continue
+		}
+		vs := r.bucket.versions[name]
+		for i := len(vs) - 1; i >= 0; i-- {
+			v := vs[i]
+			if skip {
+				skip = v.versionId != versionMarker
+				continue
+			}
+			if full() {
+				break List
+			}
+			mtime := v.mtime.Format(timeFormat)
+			if v.deleteMarker {
+				resp.DeleteMarkers = append(resp.DeleteMarkers, s3.DeleteMarker{
+					Key:          name,
+					VersionId:    v.versionId,
+					IsLatest:     i == len(vs)-1,
+					LastModified: mtime,
+					Owner:        owner,
+				})
+			} else {
+				resp.Versions = append(resp.Versions, s3.Version{
+					Key:          name,
+					VersionId:    v.versionId,
+					IsLatest:     i == len(vs)-1,
+					LastModified: mtime,
+					ETag:         `"` + v.etag + `"`,
+					Size:         v.size,
+					Owner:        owner,
+					StorageClass: storageClass(v.meta),
+				})
+			}
+			resp.NextKeyMarker, resp.NextVersionIdMarker = name, v.versionId
+		}
+	}
+	if !resp.IsTruncated {
+		resp.NextKeyMarker, resp.NextVersionIdMarker = "", ""
+	}
+	return resp
+}
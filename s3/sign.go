@@ -17,12 +17,16 @@ var b64 = base64.StdEncoding
 
 var s3ParamsToSign = map[string]bool{
 	"acl":                          true,
+	"cors":                         true,
+	"delete":                       true,
+	"lifecycle":                    true,
 	"location":                     true,
 	"logging":                      true,
 	"notification":                 true,
 	"partNumber":                   true,
 	"policy":                       true,
 	"requestPayment":               true,
+	"tagging":                      true,
 	"torrent":                      true,
 	"uploadId":                     true,
 	"uploads":                      true,
@@ -42,7 +46,15 @@ func sign(auth aws.Auth, method, canonicalPath string, params, headers map[strin
 	var md5, ctype, date, xamz string
 	var xamzDate bool
 	var sarray []string
-	for k, v := range headers {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	// Sort by name rather than by line, which would put a header before
+	// those whose name it extends.
+	sort.Slice(keys, func(i, j int) bool { return strings.ToLower(keys[i]) < strings.ToLower(keys[j]) })
+	for _, k := range keys {
+		v := headers[k]
 		k = strings.ToLower(k)
 		switch k {
 		case "content-md5":
@@ -65,7 +77,6 @@ func sign(auth aws.Auth, method, canonicalPath string, params, headers map[strin
 		}
 	}
 	if len(sarray) > 0 {
-		sort.StringSlice(sarray).Sort()
 		xamz = strings.Join(sarray, "\n") + "\n"
 	}
 
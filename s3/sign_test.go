@@ -36,6 +36,22 @@ func (s *S) TestSignExampleObjectPut(c *gocheck.C) {
 	c.Assert(headers["Authorization"], gocheck.DeepEquals, []string{expected})
 }
 
+func (s *S) TestSignHeaderNamePrefix(c *gocheck.C) {
+	// Headers are sorted by name, so x-amz-copy-source goes before the
+	// headers whose names extend it.
+	method := "PUT"
+	path := "/johnsmith/photos/copy.jpg"
+	headers := map[string][]string{
+		"Host":                       {"johnsmith.s3.amazonaws.com"},
+		"Date":                       {"Tue, 27 Mar 2007 21:15:45 +0000"},
+		"x-amz-copy-source-if-match": {`"etag"`},
+		"x-amz-copy-source":          {"/johnsmith/photos/puppy.jpg"},
+	}
+	s3.Sign(testAuth, method, path, nil, headers)
+	expected := "AWS 0PN5J17HBGZHT7JJ3X82:Nm0O2fks4LKNxt985HnAjktJk7g="
+	c.Assert(headers["Authorization"], gocheck.DeepEquals, []string{expected})
+}
+
 func (s *S) TestSignExampleList(c *gocheck.C) {
 	method := "GET"
 	path := "/johnsmith/"
@@ -0,0 +1,259 @@
+package s3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Part sizes and concurrency used by Uploader.
+const (
+	MinUploadPartSize        = 5 << 20
+	DefaultUploadPartSize    = MinUploadPartSize
+	DefaultUploadConcurrency = 5
+
+	// MaxUploadParts is the largest number of parts a multipart upload
+	// may have.
+	MaxUploadParts = 10000
+)
+
+// Here just for testing.
+var minUploadPartSize int64 = MinUploadPartSize
+
+// An Uploader stores the content of arbitrary readers, of unknown length,
+// in a bucket. Objects that fit in a single part are sent with one PUT
+// request; larger ones are sent as a multipart upload whose parts are
+// uploaded concurrently.
+//
+// An Uploader may be used for any number of uploads at the same time.
+type Uploader struct {
+	Bucket *Bucket
+
+	// PartSize is the size of the parts objects are split into. It must
+	// be at least MinUploadPartSize. If zero, DefaultUploadPartSize is
+	// used. Objects are limited to MaxUploadParts parts.
+	PartSize int64
+
+	// Concurrency is the number of parts uploaded at the same time. If
+	// zero, DefaultUploadConcurrency is used.
+	Concurrency int
+}
+
+// NewUploader returns an Uploader for b with the default part size and
+// concurrency.
+func NewUploader(b *Bucket) *Uploader {
+	return &Uploader{Bucket: b}
+}
+
+// PartError describes the failure to upload part N of a multipart upload.
+type PartError struct {
+	N   int
+	Err error
+}
+
+// UploadError is returned by Uploader.Upload when a multipart upload
+// fails. The upload is aborted before the error is returned.
+type UploadError struct {
+	Key      string
+	UploadId string
+
+	// Parts holds the parts that failed to upload, by part number.
+	Parts []PartError
+
+	// Err is the error that is not specific to a part, if any, such as
+	// a failure to read the content or to complete the upload.
+	Err error
+
+	// AbortErr is the error aborting the upload failed with, if any.
+	AbortErr error
+}
+
+func (e *UploadError) Error() string {
+	msg := fmt.Sprintf("multipart upload %s of %q failed", e.UploadId, e.Key)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	for _, p := range e.Parts {
+		msg += fmt.Sprintf("; part %d: %v", p.N, p.Err)
+	}
+	if e.AbortErr != nil {
+		msg += fmt.Sprintf(" (abort failed: %v)", e.AbortErr)
+	}
+	return msg
+}
+
+// Upload stores the content of r, read until EOF, at path in the
+// uploader's bucket. At most Concurrency+2 parts are held in memory at any
+// time. If a multipart upload fails, it is aborted and the error returned
+// is an *UploadError.
+func (u *Uploader) Upload(path string, r io.Reader, contType string, perm ACL, options Options) error {
+	partSize, concurrency := u.PartSize, u.Concurrency
+	if partSize == 0 {
+		partSize = DefaultUploadPartSize
+	}
+	if partSize < minUploadPartSize {
+		return fmt.Errorf("upload part size %d is below the minimum of %d bytes", partSize, minUploadPartSize)
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultUploadConcurrency
+	}
+
+	// Read up to two parts ahead to learn whether the object needs a
+	// multipart upload at all.
+	first, err := readPart(r, partSize)
+	if err != nil {
+		return err
+	}
+	var next []byte
+	if int64(len(first)) == partSize {
+		next, err = readPart(r, partSize)
+		if err != nil {
+			return err
+		}
+	}
+	if len(next) == 0 {
+		return u.Bucket.PutReader(path, bytes.NewReader(first), int64(len(first)), contType, perm, options)
+	}
+
+	m, err := u.Bucket.initMulti(path, contType, perm, options)
+	if err != nil {
+		return err
+	}
+
+	run := runParts(concurrency)
+	var readErr error
+	pending := [][]byte{first, next}
+	for n := 1; ; n++ {
+		var data []byte
+		if len(pending) > 0 {
+			data, pending = pending[0], pending[1:]
+		} else {
+			data, readErr = readPart(r, partSize)
+			if readErr != nil || len(data) == 0 {
+				break
+			}
+		}
+		if n > MaxUploadParts {
+			readErr = fmt.Errorf("object is larger than %d parts of %d bytes", MaxUploadParts, partSize)
+			break
+		}
+		n, data := n, data
+		if !run.add(n, func() (Part, error) { return m.putBytes(n, data) }) {
+			break
+		}
+	}
+	parts, failed := run.wait()
+
+	if readErr != nil || len(failed) > 0 {
+		return abortUpload(m, readErr, failed)
+	}
+	if err := m.Complete(parts); err != nil {
+		return abortUpload(m, err, nil)
+	}
+	return nil
+}
+
+// readPart reads from r until size bytes or EOF are reached.
+func readPart(r io.Reader, size int64) ([]byte, error) {
+	buf := make([]byte, size)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return buf[:n], err
+}
+
+// putBytes sends data as part n of m.
+func (m *Multi) putBytes(n int, data []byte) (Part, error) {
+	sum := md5.Sum(data)
+	md5b64 := base64.StdEncoding.EncodeToString(sum[:])
+	return m.putPart(n, bytes.NewReader(data), int64(len(data)), md5b64)
+}
+
+// abortUpload aborts m, which failed with err or because of the failed
+// parts, and returns the resulting *UploadError.
+func abortUpload(m *Multi, err error, failed []PartError) error {
+	sort.Slice(failed, func(i, j int) bool { return failed[i].N < failed[j].N })
+	return &UploadError{
+		Key:      m.Key,
+		UploadId: m.UploadId,
+		Parts:    failed,
+		Err:      err,
+		AbortErr: m.Abort(),
+	}
+}
+
+// A partRunner runs the jobs transferring the numbered parts of an object
+// on a number of workers, and collects the parts transferred and the
+// errors of those that failed. Once a part has failed, the jobs not yet
+// started are skipped, as the transfer is going to fail anyway.
+type partRunner struct {
+	jobs   chan partJob
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	parts  partSlice
+	failed []PartError
+}
+
+type partJob struct {
+	n   int
+	put func() (Part, error)
+}
+
+// runParts returns a partRunner running jobs on the given number of
+// workers.
+func runParts(workers int) *partRunner {
+	r := &partRunner{jobs: make(chan partJob)}
+	for i := 0; i < workers; i++ {
+		r.wg.Add(1)
+		go r.work()
+	}
+	return r
+}
+
+func (r *partRunner) work() {
+	defer r.wg.Done()
+	for j := range r.jobs {
+		if r.hasFailed() {
+			continue
+		}
+		part, err := j.put()
+		r.mu.Lock()
+		if err != nil {
+			r.failed = append(r.failed, PartError{j.n, err})
+		} else {
+			r.parts = append(r.parts, part)
+		}
+		r.mu.Unlock()
+	}
+}
+
+func (r *partRunner) hasFailed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.failed) > 0
+}
+
+// add hands put, the job transferring part n, to the next free worker. It
+// returns false without doing so once a part has failed.
+func (r *partRunner) add(n int, put func() (Part, error)) bool {
+	if r.hasFailed() {
+		return false
+	}
+	r.jobs <- partJob{n, put}
+	return true
+}
+
+// wait waits for the jobs added to finish, and returns the parts
+// transferred, sorted by part number, and those that failed, in the order
+// they failed.
+func (r *partRunner) wait() ([]Part, []PartError) {
+	close(r.jobs)
+	r.wg.Wait()
+	sort.Sort(r.parts)
+	return r.parts, r.failed
+}
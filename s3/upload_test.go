@@ -0,0 +1,109 @@
+package s3_test
+
+import (
+	"encoding/xml"
+	"github.com/hailocab/goamz/s3"
+	"io"
+	"launchpad.net/gocheck"
+	"strings"
+)
+
+// onlyReader hides all methods but Read of the reader it wraps.
+type onlyReader struct {
+	io.Reader
+}
+
+func (s *S) TestUploadSinglePart(c *gocheck.C) {
+	testServer.Response(200, nil, "")
+
+	u := s3.NewUploader(s.s3.Bucket("sample"))
+	err := u.Upload("small", onlyReader{strings.NewReader("content")}, "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, gocheck.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, gocheck.Equals, "PUT")
+	c.Assert(req.URL.Path, gocheck.Equals, "/sample/small")
+	c.Assert(req.Form["uploadId"], gocheck.IsNil)
+	c.Assert(req.Header["Content-Length"], gocheck.DeepEquals, []string{"7"})
+	c.Assert(readAll(req.Body), gocheck.Equals, "content")
+}
+
+func (s *S) TestUploadMultipart(c *gocheck.C) {
+	s3.SetMinUploadPartSize(5)
+	defer s3.SetMinUploadPartSize(0)
+
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Responses(3, 200, map[string]string{"ETag": `"etag"`}, "")
+	testServer.Response(200, nil, "")
+
+	u := &s3.Uploader{Bucket: s.s3.Bucket("sample"), PartSize: 5, Concurrency: 3}
+	options := s3.Options{Meta: map[string][]string{"name": {"value"}}}
+	err := u.Upload("multi", onlyReader{strings.NewReader("part1part2par")}, "text/plain", s3.Private, options)
+	c.Assert(err, gocheck.IsNil)
+
+	req := testServer.WaitRequest()
+	c.Assert(req.Method, gocheck.Equals, "POST")
+	c.Assert(req.Form["uploads"], gocheck.DeepEquals, []string{""})
+	c.Assert(req.Header["X-Amz-Meta-Name"], gocheck.DeepEquals, []string{"value"})
+
+	// Parts are sent concurrently, so in no particular order.
+	bodies := make(map[string]string)
+	for _, req := range testServer.WaitRequests(3) {
+		c.Assert(req.Method, gocheck.Equals, "PUT")
+		c.Assert(req.Form.Get("uploadId"), gocheck.Matches, "JNbR_.*")
+		bodies[req.Form.Get("partNumber")] = readAll(req.Body)
+	}
+	c.Assert(bodies, gocheck.DeepEquals, map[string]string{"1": "part1", "2": "part2", "3": "par"})
+
+	req = testServer.WaitRequest()
+	c.Assert(req.Method, gocheck.Equals, "POST")
+	c.Assert(req.Form.Get("uploadId"), gocheck.Matches, "JNbR_.*")
+	var payload struct {
+		Part []struct {
+			PartNumber int
+			ETag       string
+		}
+	}
+	err = xml.Unmarshal([]byte(readAll(req.Body)), &payload)
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(payload.Part, gocheck.HasLen, 3)
+	for i, part := range payload.Part {
+		c.Assert(part.PartNumber, gocheck.Equals, i+1)
+		c.Assert(part.ETag, gocheck.Equals, `"etag"`)
+	}
+}
+
+func (s *S) TestUploadPartFailure(c *gocheck.C) {
+	s.DisableRetries()
+	s3.SetMinUploadPartSize(5)
+	defer s3.SetMinUploadPartSize(0)
+
+	testServer.Response(200, nil, InitMultiResultDump)
+	testServer.Response(200, map[string]string{"ETag": `"etag1"`}, "")
+	testServer.Response(500, nil, InternalErrorDump)
+	testServer.Response(204, nil, "")
+
+	u := &s3.Uploader{Bucket: s.s3.Bucket("sample"), PartSize: 5, Concurrency: 1}
+	err := u.Upload("multi", strings.NewReader("part1part2part3"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, gocheck.FitsTypeOf, &s3.UploadError{})
+	uerr := err.(*s3.UploadError)
+	c.Assert(uerr.Key, gocheck.Equals, "multi")
+	c.Assert(uerr.UploadId, gocheck.Matches, "JNbR_.*")
+	c.Assert(uerr.Parts, gocheck.HasLen, 1)
+	c.Assert(uerr.Parts[0].N, gocheck.Equals, 2)
+	c.Assert(uerr.Parts[0].Err, gocheck.ErrorMatches, ".*Not relevant")
+	c.Assert(uerr.Err, gocheck.IsNil)
+	c.Assert(uerr.AbortErr, gocheck.IsNil)
+
+	reqs := testServer.WaitRequests(4)
+	c.Assert(reqs[1].Form["partNumber"], gocheck.DeepEquals, []string{"1"})
+	c.Assert(reqs[2].Form["partNumber"], gocheck.DeepEquals, []string{"2"})
+	c.Assert(reqs[3].Method, gocheck.Equals, "DELETE")
+	c.Assert(reqs[3].Form.Get("uploadId"), gocheck.Matches, "JNbR_.*")
+}
+
+func (s *S) TestUploadPartSizeTooSmall(c *gocheck.C) {
+	u := &s3.Uploader{Bucket: s.s3.Bucket("sample"), PartSize: 1024}
+	err := u.Upload("name", strings.NewReader("content"), "text/plain", s3.Private, s3.Options{})
+	c.Assert(err, gocheck.ErrorMatches, "upload part size 1024 is below the minimum of 5242880 bytes")
+}
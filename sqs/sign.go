@@ -12,6 +12,7 @@ import (
 var b64 = base64.StdEncoding
 
 func sign(auth aws.Auth, method, path string, params map[string]string, host string) {
+	delete(params, "Signature") // Left over from a previous attempt.
 	params["AWSAccessKeyId"] = auth.AccessKey
 	if auth.Token() != "" {
 		params["SecurityToken"] = auth.Token()
@@ -10,6 +10,7 @@
 package sqs
 
 import (
+	"context"
 	"encoding/xml"
 	"errors"
 	"fmt"
@@ -28,6 +29,10 @@ const debug = false
 type SQS struct {
 	aws.Auth
 	aws.Region
+	// Config holds the HTTP settings used to send requests. If nil,
+	// aws.DefaultConfig is used.
+	Config  *aws.Config
+	ctx     context.Context
 	private byte // Reserve the right of using private data.
 }
 
@@ -55,7 +60,24 @@ func NewFrom(accessKey, secretKey, region string) (*SQS, error) {
 
 // NewFrom Create A new SQS Client from an exisisting aws.Auth
 func New(auth aws.Auth, region aws.Region) *SQS {
-	return &SQS{auth, region, 0}
+	return NewWithConfig(auth, region, nil)
+}
+
+// NewWithConfig creates a new SQS client that sends requests using the
+// HTTP settings in config.
+func NewWithConfig(auth aws.Auth, region aws.Region, config *aws.Config) *SQS {
+	return &SQS{Auth: auth, Region: region, Config: config}
+}
+
+// WithContext returns a shallow copy of s whose requests are made with
+// ctx, so that cancelling ctx or reaching its deadline aborts them.
+func (s *SQS) WithContext(ctx context.Context) *SQS {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c := *s
+	c.ctx = ctx
+	return &c
 }
 
 // Queue Reference to a Queue
@@ -64,6 +86,11 @@ type Queue struct {
 	Url string
 }
 
+// WithContext returns a copy of q whose requests are made with ctx.
+func (q *Queue) WithContext(ctx context.Context) *Queue {
+	return &Queue{q.SQS.WithContext(ctx), q.Url}
+}
+
 type CreateQueueResponse struct {
 	QueueUrl         string `xml:"CreateQueueResult>QueueUrl"`
 	ResponseMetadata ResponseMetadata
@@ -139,6 +166,31 @@ func (err *Error) Error() string {
 	return fmt.Sprintf("%s (%s)", err.Message, err.Code)
 }
 
+// errorCodes holds the error codes SQS uses for throttled requests and
+// transient failures, beyond those all services share.
+var errorCodes = aws.ErrorCodes{
+	Throttle: []string{"RequestThrottled", "KmsThrottled"},
+}
+
+// Retryable reports whether the failed request may be sent again.
+func (err *Error) Retryable() bool {
+	return errorCodes.Retryable(err.StatusCode, err.Code)
+}
+
+// Throttled reports whether the request was rejected for exceeding a rate
+// limit.
+func (err *Error) Throttled() bool {
+	return errorCodes.Throttled(err.StatusCode, err.Code)
+}
+
+// The following methods implement aws.APIError.
+
+func (err *Error) Service() string      { return "sqs" }
+func (err *Error) HTTPStatus() int      { return err.StatusCode }
+func (err *Error) ErrorCode() string    { return err.Code }
+func (err *Error) ErrorMessage() string { return err.Message }
+func (err *Error) RequestID() string    { return err.RequestId }
+
 func (err *Error) String() string {
 	return err.Message
 }
@@ -394,37 +446,62 @@ func (q *Queue) DeleteMessageBatch(msgList []Message) (resp *DeleteMessageBatchR
 	return
 }
 
-func (s *SQS) query(queueUrl string, params map[string]string, resp interface{}) (err error) {
+// query sends a request, retrying it as dictated by the Retryer in
+// s.Config, and decodes the response into resp.
+func (s *SQS) query(queueUrl string, params map[string]string, resp interface{}) error {
+	req := aws.NewRequest(s.ctx, s.Config, "sqs", params["Action"])
+	return req.Retry(func() error {
+		return s.queryOnce(req, queueUrl, params, resp)
+	})
+}
+
+func (s *SQS) queryOnce(req *aws.Request, queueUrl string, params map[string]string, resp interface{}) (err error) {
 	params["Version"] = "2011-10-01"
 	params["Timestamp"] = time.Now().In(time.UTC).Format(time.RFC3339)
 	var url_ *url.URL
 
+	endpoint, err := s.Config.Endpoint("sqs", s.Region)
+	if err != nil {
+		return err
+	}
 	var path string
-	if queueUrl != "" && len(queueUrl) > len(s.Region.SQSEndpoint) {
+	if queueUrl != "" && len(queueUrl) > len(endpoint) {
 		url_, err = url.Parse(queueUrl)
-		path = queueUrl[len(s.Region.SQSEndpoint):]
+		path = queueUrl[len(endpoint):]
 	} else {
-		url_, err = url.Parse(s.Region.SQSEndpoint)
+		url_, err = url.Parse(endpoint)
 		path = "/"
 	}
 	if err != nil {
 		return err
 	}
 
-	//url_, err := url.Parse(s.Region.SQSEndpoint)
-	//if err != nil {
-	//	return err
-	//}
-
-	sign(s.Auth, "GET", path, params, url_.Host)
-
+	auth, err := s.Auth.Current()
+	if err != nil {
+		return err
+	}
 	url_.RawQuery = multimap(params).Encode()
 
+	hreq, err := http.NewRequest("GET", url_.String(), nil)
+	if err != nil {
+		return err
+	}
+	if err := req.Build(hreq); err != nil {
+		return err
+	}
+	// Sign the query as left by the build handlers.
+	signed := flatten(hreq.URL.Query())
+	sign(auth, "GET", path, signed, hreq.URL.Host)
+	hreq.URL.RawQuery = multimap(signed).Encode()
+	if err := req.Sign(); err != nil {
+		return err
+	}
+
 	if debug {
-		log.Printf("GET ", url_.String())
+		log.Printf("GET ", hreq.URL.String())
 	}
 
-	r, err := http.Get(url_.String())
+	r, err := req.Send()
 	if err != nil {
 		return err
 	}
@@ -437,10 +514,10 @@ func (s *SQS) query(queueUrl string, params map[string]string, resp interface{})
 	}
 
 	if r.StatusCode != 200 {
-		return buildError(r)
+		return req.Unmarshal(resp, buildError(r))
 	}
 	err = xml.NewDecoder(r.Body).Decode(resp)
-	return err
+	return req.Unmarshal(resp, err)
 }
 
 func buildError(r *http.Response) error {
@@ -473,3 +550,11 @@ func multimap(p map[string]string) url.Values {
 	}
 	return q
 }
+
+func flatten(q url.Values) map[string]string {
+	p := make(map[string]string, len(q))
+	for k, v := range q {
+		p[k] = v[0]
+	}
+	return p
+}
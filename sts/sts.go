@@ -1,6 +1,7 @@
 package sts
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"github.com/hailocab/goamz/aws"
@@ -17,13 +18,34 @@ import (
 type STS struct {
 	aws.Auth
 	aws.Region
+	// Config holds the HTTP settings used to send requests. If nil,
+	// aws.DefaultConfig is used.
+	Config  *aws.Config
+	ctx     context.Context
 	private byte // Reserve the right of using private data.
 }
 
 // New creates a new STS Client.
 // We can only use us-east for region because AWS..
 func New(auth aws.Auth, region aws.Region) *STS {
-	return &STS{auth, aws.Regions["us-east-1"], 0}
+	return NewWithConfig(auth, region, nil)
+}
+
+// NewWithConfig creates a new STS Client that sends requests using the
+// HTTP settings in config.
+func NewWithConfig(auth aws.Auth, region aws.Region, config *aws.Config) *STS {
+	return &STS{Auth: auth, Region: aws.Regions["us-east-1"], Config: config}
+}
+
+// WithContext returns a shallow copy of sts whose requests are made with
+// ctx, so that cancelling ctx or reaching its deadline aborts them.
+func (sts *STS) WithContext(ctx context.Context) *STS {
+	if ctx == nil {
+		panic("nil context")
+	}
+	c := *sts
+	c.ctx = ctx
+	return &c
 }
 
 const debug = false
@@ -52,35 +74,75 @@ func (err *Error) Error() string {
 	return fmt.Sprintf("%s (%s)", err.Message, err.Code)
 }
 
+// errorCodes holds the error codes STS uses for throttled requests and
+// transient failures, beyond those all services share.
+var errorCodes = aws.ErrorCodes{
+	Transient: []string{"IDPCommunicationError"},
+}
+
+// Retryable reports whether the failed request may be sent again.
+func (err *Error) Retryable() bool {
+	return errorCodes.Retryable(err.StatusCode, err.Code)
+}
+
+// Throttled reports whether the request was rejected for exceeding a rate
+// limit.
+func (err *Error) Throttled() bool {
+	return errorCodes.Throttled(err.StatusCode, err.Code)
+}
+
+// The following methods implement aws.APIError.
+
+func (err *Error) Service() string      { return "sts" }
+func (err *Error) HTTPStatus() int      { return err.StatusCode }
+func (err *Error) ErrorCode() string    { return err.Code }
+func (err *Error) ErrorMessage() string { return err.Message }
+func (err *Error) RequestID() string    { return err.RequestId }
+
 type xmlErrors struct {
 	RequestId string  `xml:"RequestId"`
 	Errors    []Error `xml:"Error"`
 }
 
+// query sends a request, retrying it as dictated by the Retryer in
+// sts.Config, and decodes the response into resp.
 func (sts *STS) query(params map[string]string, resp interface{}) error {
+	req := aws.NewRequest(sts.ctx, sts.Config, "sts", params["Action"])
+	return req.Retry(func() error {
+		return sts.queryOnce(req, params, resp)
+	})
+}
+
+func (sts *STS) queryOnce(req *aws.Request, params map[string]string, resp interface{}) error {
 	params["Version"] = "2011-06-15"
 
+	endpoint, err := sts.Config.Endpoint("sts", sts.Region)
+	if err != nil {
+		return err
+	}
 	data := strings.NewReader(prepareParams(params))
 
-	hreq, err := http.NewRequest("POST", sts.Region.STSEndpoint+"/", data)
+	hreq, err := http.NewRequest("POST", endpoint+"/", data)
 	if err != nil {
 		return err
 	}
 
 	hreq.Header.Set("Content-Type", "application/x-www-form-urlencoded; param=value")
 
-	token := sts.Auth.Token()
-	if token != "" {
-		hreq.Header.Set("X-Amz-Security-Token", token)
+	if err := req.Build(hreq); err != nil {
+		return err
 	}
 
 	signer := aws.NewV4Signer(sts.Auth, "sts", sts.Region)
 	signer.Sign(hreq)
+	if err := req.Sign(); err != nil {
+		return err
+	}
 
 	if debug {
 		log.Printf("%v -> {\n", hreq)
 	}
-	r, err := http.DefaultClient.Do(hreq)
+	r, err := req.Send()
 
 	if err != nil {
 		log.Printf("Error calling Amazon")
@@ -95,10 +157,10 @@ func (sts *STS) query(params map[string]string, resp interface{}) error {
 		log.Printf("%v\n}\n", string(dump))
 	}
 	if r.StatusCode != 200 {
-		return buildError(r)
+		return req.Unmarshal(resp, buildError(r))
 	}
 	err = xml.NewDecoder(r.Body).Decode(resp)
-	return err
+	return req.Unmarshal(resp, err)
 }
 
 func buildError(r *http.Response) error {
@@ -195,3 +257,32 @@ func (sts *STS) AssumeRole(options *AssumeRoleOptions) (resp *AssumeRoleResp, er
 	}
 	return resp, nil
 }
+
+// AssumeRoleProvider is an aws.CredentialsProvider that retrieves temporary
+// credentials by assuming a role.
+type AssumeRoleProvider struct {
+	STS     *STS
+	Options AssumeRoleOptions
+}
+
+// NewAssumeRoleProvider returns a provider that assumes roleArn using the
+// credentials of s, naming the resulting session sessionName.
+func NewAssumeRoleProvider(s *STS, roleArn, sessionName string) *AssumeRoleProvider {
+	return &AssumeRoleProvider{
+		STS: s,
+		Options: AssumeRoleOptions{
+			RoleArn:         roleArn,
+			RoleSessionName: sessionName,
+		},
+	}
+}
+
+func (p *AssumeRoleProvider) Retrieve() (aws.Auth, error) {
+	options := p.Options
+	resp, err := p.STS.AssumeRole(&options)
+	if err != nil {
+		return aws.Auth{}, err
+	}
+	c := resp.Credentials
+	return *aws.NewAuth(c.AccessKeyId, c.SecretAccessKey, c.SessionToken, c.Expiration), nil
+}
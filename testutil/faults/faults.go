@@ -0,0 +1,227 @@
+// Package faults injects failures into the responses of the fake AWS
+// servers, such as s3test and ec2test, so that clients can be tested
+// against errors, throttling, slow responses and broken connections:
+//
+//	inj := faults.New(faults.Rule{Nth: 1, Count: 2, StatusCode: 503, Code: "SlowDown"})
+//	srv, err := s3test.NewServer(&s3test.Config{Faults: inj})
+//
+// makes the first two requests to srv fail with SlowDown.
+package faults
+
+import (
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// A Rule describes a fault and the requests it is injected into.
+//
+// A rule applies to all the requests it matches, unless Nth or Percent
+// are set. Every rule that applies to a request adds its Latency to the
+// response; the first of them that resets the connection, returns an
+// error or truncates the body decides the response.
+type Rule struct {
+	// Match, if set, restricts the rule to the requests it returns
+	// true for.
+	Match func(req *http.Request) bool
+
+	// Nth makes the rule apply to the Nth request it matches, counting
+	// from 1, and to the Count-1 following ones.
+	Nth   int
+	Count int
+
+	// Percent makes the rule apply to that percentage of the requests
+	// it matches, chosen at random.
+	Percent float64
+
+	// Latency delays the response.
+	Latency time.Duration
+
+	// Reset makes the server close the connection without responding,
+	// so that the client gets a connection reset.
+	Reset bool
+
+	// Code makes the server respond with an error of that code, in the
+	// format of the service, with StatusCode and Message. StatusCode
+	// defaults to 500.
+	Code       string
+	StatusCode int
+	Message    string
+
+	// Truncate makes the server close the connection after sending the
+	// headers of its response and the first TruncateAfter bytes of the
+	// body.
+	Truncate      bool
+	TruncateAfter int
+}
+
+// terminal returns whether r decides the response to the requests it
+// applies to.
+func (r *Rule) terminal() bool {
+	return r.Reset || r.Code != "" || r.Truncate
+}
+
+// An ErrorWriter writes an error response in the format of a service.
+type ErrorWriter func(w http.ResponseWriter, status int, code, message string)
+
+// An Injector injects faults into the responses of a server according
+// to its rules. It may be changed while the server is running.
+type Injector struct {
+	mu       sync.Mutex
+	rules    []*rule
+	rand     *rand.Rand
+	injected int
+}
+
+type rule struct {
+	Rule
+	matched int
+}
+
+// New returns an Injector with the given rules. The requests picked by
+// rules with Percent are the same from one run to the next, unless Seed
+// is called.
+func New(rules ...Rule) *Injector {
+	inj := &Injector{rand: rand.New(rand.NewSource(1))}
+	inj.Add(rules...)
+	return inj
+}
+
+// Add adds rules after those of inj.
+func (inj *Injector) Add(rules ...Rule) {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	for _, r := range rules {
+		inj.rules = append(inj.rules, &rule{Rule: r})
+	}
+}
+
+// Reset removes all the rules of inj, and sets the number of faults
+// injected back to zero.
+func (inj *Injector) Reset() {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	inj.rules = nil
+	inj.injected = 0
+}
+
+// Seed seeds the random choice of the requests rules with Percent apply
+// to.
+func (inj *Injector) Seed(seed int64) {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	inj.rand.Seed(seed)
+}
+
+// Injected returns the number of requests inj made fail, by resetting
+// the connection, returning an error or truncating the body.
+func (inj *Injector) Injected() int {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	return inj.injected
+}
+
+// fault returns the latency to add to the response to req, and the rule
+// deciding it, if any.
+func (inj *Injector) fault(req *http.Request) (latency time.Duration, fault *Rule) {
+	inj.mu.Lock()
+	defer inj.mu.Unlock()
+	for _, r := range inj.rules {
+		if r.Match != nil && !r.Match(req) {
+			continue
+		}
+		r.matched++
+		switch {
+		case r.Nth > 0:
+			count := r.Count
+			if count < 1 {
+				count = 1
+			}
+			if r.matched < r.Nth || r.matched >= r.Nth+count {
+				continue
+			}
+		case r.Percent > 0:
+			if inj.rand.Float64()*100 >= r.Percent {
+				continue
+			}
+		}
+		latency += r.Latency
+		if fault == nil && r.terminal() {
+			fault = &r.Rule
+			inj.injected++
+		}
+	}
+	return latency, fault
+}
+
+// Handler returns a handler that serves requests with h, injecting the
+// faults of inj into its responses. Errors are written with writeError.
+// If inj is nil, h is returned.
+func (inj *Injector) Handler(h http.Handler, writeError ErrorWriter) http.Handler {
+	if inj == nil {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		latency, fault := inj.fault(req)
+		time.Sleep(latency)
+		switch {
+		case fault == nil:
+			h.ServeHTTP(w, req)
+		case fault.Reset:
+			closeConn(w, true)
+		case fault.Code != "":
+			status := fault.StatusCode
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+			writeError(w, status, fault.Code, fault.Message)
+		case fault.Truncate:
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			body := rec.Body.Bytes()
+			for k, v := range rec.Header() {
+				w.Header()[k] = v
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(rec.Code)
+			if n := fault.TruncateAfter; n < len(body) {
+				body = body[:n]
+			}
+			w.Write(body)
+			w.(http.Flusher).Flush()
+			closeConn(w, false)
+		}
+	})
+}
+
+// closeConn closes the connection w writes to. If reset is true, the
+// peer gets a connection reset where possible, and may lose what was
+// sent before.
+func closeConn(w http.ResponseWriter, reset bool) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok && reset {
+		tcp.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// Action returns a function for Rule.Match that matches the requests to
+// services with a query API, such as EC2, for one of the given actions.
+func Action(actions ...string) func(req *http.Request) bool {
+	return func(req *http.Request) bool {
+		action := req.FormValue("Action")
+		for _, a := range actions {
+			if a == action {
+				return true
+			}
+		}
+		return false
+	}
+}
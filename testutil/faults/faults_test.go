@@ -0,0 +1,149 @@
+package faults_test
+
+import (
+	"fmt"
+	"github.com/hailocab/goamz/testutil/faults"
+	"io/ioutil"
+	"launchpad.net/gocheck"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test(t *testing.T) {
+	gocheck.TestingT(t)
+}
+
+type S struct {
+	inj *faults.Injector
+	srv *httptest.Server
+}
+
+var _ = gocheck.Suite(&S{})
+
+func (s *S) SetUpTest(c *gocheck.C) {
+	s.inj = faults.New()
+	h := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "content")
+	})
+	s.srv = httptest.NewServer(s.inj.Handler(h, func(w http.ResponseWriter, status int, code, message string) {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, "%s: %s", code, message)
+	}))
+}
+
+func (s *S) TearDownTest(c *gocheck.C) {
+	s.srv.Close()
+}
+
+// get sends a request for action and returns the status and body of the
+// response.
+func (s *S) get(action string) (int, string, error) {
+	resp, err := http.Get(s.srv.URL + "/?Action=" + action)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, string(data), err
+}
+
+func (s *S) TestNoFaults(c *gocheck.C) {
+	status, body, err := s.get("Describe")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(status, gocheck.Equals, 200)
+	c.Assert(body, gocheck.Equals, "content")
+	c.Assert(s.inj.Injected(), gocheck.Equals, 0)
+}
+
+func (s *S) TestNth(c *gocheck.C) {
+	s.inj.Add(faults.Rule{Nth: 2, Count: 2, Code: "InternalError", Message: "oops"})
+	var got []string
+	for i := 0; i < 5; i++ {
+		status, body, err := s.get("Describe")
+		c.Assert(err, gocheck.IsNil)
+		got = append(got, fmt.Sprintf("%d %s", status, body))
+	}
+	c.Assert(got, gocheck.DeepEquals, []string{
+		"200 content",
+		"500 InternalError: oops",
+		"500 InternalError: oops",
+		"200 content",
+		"200 content",
+	})
+	c.Assert(s.inj.Injected(), gocheck.Equals, 2)
+}
+
+func (s *S) TestPercent(c *gocheck.C) {
+	s.inj.Add(faults.Rule{Percent: 50, StatusCode: 503, Code: "SlowDown"})
+	failed := 0
+	for i := 0; i < 100; i++ {
+		status, _, err := s.get("Describe")
+		c.Assert(err, gocheck.IsNil)
+		if status == 503 {
+			failed++
+		}
+	}
+	c.Assert(failed > 25 && failed < 75, gocheck.Equals, true, gocheck.Commentf("%d failed", failed))
+	c.Assert(s.inj.Injected(), gocheck.Equals, failed)
+}
+
+func (s *S) TestMatch(c *gocheck.C) {
+	s.inj.Add(faults.Rule{Match: faults.Action("Create", "Delete"), StatusCode: 400, Code: "Throttling"})
+	status, _, err := s.get("Describe")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(status, gocheck.Equals, 200)
+	status, body, err := s.get("Delete")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(status, gocheck.Equals, 400)
+	c.Assert(body, gocheck.Equals, "Throttling: ")
+}
+
+func (s *S) TestLatency(c *gocheck.C) {
+	s.inj.Add(
+		faults.Rule{Latency: 50 * time.Millisecond},
+		faults.Rule{Nth: 1, Latency: 50 * time.Millisecond, Code: "InternalError"},
+	)
+	start := time.Now()
+	status, _, err := s.get("Describe")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(status, gocheck.Equals, 500)
+	c.Assert(time.Since(start) >= 100*time.Millisecond, gocheck.Equals, true)
+
+	start = time.Now()
+	status, _, err = s.get("Describe")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(status, gocheck.Equals, 200)
+	c.Assert(time.Since(start) >= 50*time.Millisecond, gocheck.Equals, true)
+	c.Assert(s.inj.Injected(), gocheck.Equals, 1)
+}
+
+func (s *S) TestTruncate(c *gocheck.C) {
+	s.inj.Add(faults.Rule{Nth: 1, Truncate: true, TruncateAfter: 3})
+	status, body, err := s.get("Describe")
+	c.Assert(err, gocheck.NotNil)
+	c.Assert(status, gocheck.Equals, 200)
+	c.Assert(body, gocheck.Equals, "con")
+}
+
+func (s *S) TestReset(c *gocheck.C) {
+	s.inj.Add(faults.Rule{Nth: 1, Reset: true})
+	_, _, err := s.get("Describe")
+	c.Assert(err, gocheck.NotNil)
+	status, _, err := s.get("Describe")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(status, gocheck.Equals, 200)
+}
+
+func (s *S) TestRemoveRules(c *gocheck.C) {
+	s.inj.Add(faults.Rule{Code: "InternalError"})
+	status, _, err := s.get("Describe")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(status, gocheck.Equals, 500)
+	s.inj.Reset()
+	c.Assert(s.inj.Injected(), gocheck.Equals, 0)
+	status, _, err = s.get("Describe")
+	c.Assert(err, gocheck.IsNil)
+	c.Assert(status, gocheck.Equals, 200)
+}